@@ -0,0 +1,832 @@
+// Package ethtest provides an in-memory Ethereum JSON-RPC node for tests.
+//
+// The backend speaks enough of the eth namespace for ethclient to send
+// transactions, mine them into blocks and read blocks, receipts and
+// transactions back. Tests drive the chain explicitly with Mine, AddBlock
+// and Rewind, and can inject failures per RPC method.
+package ethtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	DefaultChainID  = 1337
+	DefaultGasLimit = uint64(30_000_000)
+	BlockTime       = uint64(12)
+	DefaultTip      = 1_000_000_000
+	DefaultBaseFee  = 1_000_000_000
+)
+
+// Backend is a mock Ethereum node served over HTTP, and over WebSocket for
+// newHeads subscriptions.
+type Backend struct {
+	URL   string
+	WSURL string
+
+	chainID *big.Int
+	signer  types.Signer
+	server  *httptest.Server
+	ws      *httptest.Server
+	wsConns *connTracker
+
+	mu       sync.Mutex
+	blocks   []*types.Block
+	receipts map[common.Hash]*types.Receipt
+	lookup   map[common.Hash]txLookup
+	pool     []*types.Transaction
+	nonces   map[common.Address]uint64
+	balances map[common.Address]*big.Int
+	tip      *big.Int
+	baseFee  *big.Int
+	history  *ethereum.FeeHistory
+	rewards  func(percentile float64) *big.Int
+	bodies   map[common.Hash]int
+	aliases  map[common.Hash]*types.Block
+	faults   map[string]error
+	hooks    map[string]func(call int)
+	calls    map[string]int
+	heads    map[rpc.ID]*rpc.Notifier
+	traces   map[uint64]json.RawMessage
+	requests atomic.Int64
+
+	// finalityLag is how many blocks the safe and finalized tags trail the
+	// head by
+	finalityLag uint64
+}
+
+type txLookup struct {
+	block uint64
+	index int
+}
+
+// NewBackend starts a mock node with an empty genesis block. The server is
+// shut down when the test finishes.
+func NewBackend(t testing.TB) *Backend {
+	t.Helper()
+	return NewBackendWithChainID(t, DefaultChainID)
+}
+
+// NewBackendWithChainID starts a mock node reporting the given chain ID.
+func NewBackendWithChainID(t testing.TB, id int64) *Backend {
+	t.Helper()
+
+	chainID := big.NewInt(id)
+	b := &Backend{
+		chainID:  chainID,
+		signer:   types.LatestSignerForChainID(chainID),
+		receipts: make(map[common.Hash]*types.Receipt),
+		lookup:   make(map[common.Hash]txLookup),
+		nonces:   make(map[common.Address]uint64),
+		balances: make(map[common.Address]*big.Int),
+		tip:      big.NewInt(DefaultTip),
+		baseFee:  big.NewInt(DefaultBaseFee),
+		bodies:   make(map[common.Hash]int),
+		aliases:  make(map[common.Hash]*types.Block),
+		faults:   make(map[string]error),
+		hooks:    make(map[string]func(int)),
+		calls:    make(map[string]int),
+		heads:    make(map[rpc.ID]*rpc.Notifier),
+		traces:   make(map[uint64]json.RawMessage),
+	}
+	b.blocks = append(b.blocks, b.makeBlock(common.Hash{}, 0, nil))
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("eth", &ethAPI{b}); err != nil {
+		t.Fatalf("failed to register eth API: %v", err)
+	}
+	if err := srv.RegisterName("txpool", &txpoolAPI{b}); err != nil {
+		t.Fatalf("failed to register txpool API: %v", err)
+	}
+	if err := srv.RegisterName("debug", &debugAPI{b}); err != nil {
+		t.Fatalf("failed to register debug API: %v", err)
+	}
+
+	b.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.requests.Add(1)
+		srv.ServeHTTP(w, r)
+	}))
+	b.URL = b.server.URL
+
+	b.ws, b.wsConns = newWSServer(srv)
+	b.WSURL = "ws" + strings.TrimPrefix(b.ws.URL, "http")
+
+	t.Cleanup(func() {
+		b.server.Close()
+		b.wsConns.closeAll()
+		b.ws.Close()
+		srv.Stop()
+	})
+	return b
+}
+
+// ChainID returns the chain ID reported by the backend.
+func (b *Backend) ChainID() *big.Int {
+	return new(big.Int).Set(b.chainID)
+}
+
+// Signer returns the signer matching the backend's chain ID.
+func (b *Backend) Signer() types.Signer {
+	return b.signer
+}
+
+// SetError makes every call to method (e.g. "eth_sendRawTransaction") fail
+// with err. A nil err clears the fault.
+func (b *Backend) SetError(method string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.faults, method)
+		return
+	}
+	b.faults[method] = err
+}
+
+// OnCall registers fn to run at the start of every call to method, before
+// the call reads any state. fn receives the 1-based call count and may use
+// the Backend's methods.
+func (b *Backend) OnCall(method string, fn func(call int)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hooks[method] = fn
+}
+
+// Calls returns how many times method has been invoked.
+func (b *Backend) Calls(method string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls[method]
+}
+
+// Requests returns the number of HTTP round-trips served. A JSON-RPC batch
+// counts as a single request.
+func (b *Backend) Requests() int64 {
+	return b.requests.Load()
+}
+
+// SetTip sets the value returned by eth_maxPriorityFeePerGas.
+func (b *Backend) SetTip(tip *big.Int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tip = new(big.Int).Set(tip)
+}
+
+// SetBaseFee sets the base fee used for blocks mined from now on.
+func (b *Backend) SetBaseFee(baseFee *big.Int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.baseFee = new(big.Int).Set(baseFee)
+}
+
+// SetFeeHistory sets the result returned by eth_feeHistory. Without one,
+// the history is derived from the mined blocks with every reward equal to
+// the current tip, or as set by SetPercentileRewards.
+func (b *Backend) SetFeeHistory(history *ethereum.FeeHistory) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.history = history
+}
+
+// SetPercentileRewards makes derived fee histories report reward(p) for
+// percentile p in every block instead of the current tip.
+func (b *Backend) SetPercentileRewards(reward func(percentile float64) *big.Int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rewards = reward
+}
+
+// SetFinalityLag makes the safe and finalized block tags resolve to the
+// block lag blocks behind the head. By default they resolve to the head.
+func (b *Backend) SetFinalityLag(lag uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.finalityLag = lag
+}
+
+// SetNonce overrides the confirmed nonce of addr.
+func (b *Backend) SetNonce(addr common.Address, nonce uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nonces[addr] = nonce
+}
+
+// SetBalance sets the balance reported for addr. Unknown accounts report
+// one ether.
+func (b *Backend) SetBalance(addr common.Address, balance *big.Int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.balances[addr] = new(big.Int).Set(balance)
+}
+
+// Pending returns the transactions waiting in the mempool.
+func (b *Backend) Pending() []*types.Transaction {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*types.Transaction(nil), b.pool...)
+}
+
+// FlushPool drops every pending transaction, as a restarting node would.
+func (b *Backend) FlushPool() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pool = nil
+}
+
+// Head returns the latest block.
+func (b *Backend) Head() *types.Block {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.blocks[len(b.blocks)-1]
+}
+
+// BlockByNumber returns the canonical block at number, or nil.
+func (b *Backend) BlockByNumber(number uint64) *types.Block {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if number >= uint64(len(b.blocks)) {
+		return nil
+	}
+	return b.blocks[number]
+}
+
+// Receipt returns the receipt of a mined transaction, or nil.
+func (b *Backend) Receipt(txHash common.Hash) *types.Receipt {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.receipts[txHash]
+}
+
+// Mine includes every pending transaction in a new block and returns it.
+func (b *Backend) Mine() *types.Block {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	txs := b.pool
+	b.pool = nil
+	return b.appendBlock(txs)
+}
+
+// AddBlock mines a new block containing txs, bypassing the mempool.
+func (b *Backend) AddBlock(txs ...*types.Transaction) *types.Block {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.appendBlock(txs)
+}
+
+// SetReceiptStatus overrides the status of a mined transaction's receipt.
+func (b *Backend) SetReceiptStatus(txHash common.Hash, status uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r, ok := b.receipts[txHash]; ok {
+		r.Status = status
+	}
+}
+
+// SetBlockTrace sets the debug_traceBlockByNumber response for block number,
+// whatever tracer is requested. Blocks without one trace as an empty list.
+func (b *Backend) SetBlockTrace(number uint64, trace json.RawMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.traces[number] = trace
+}
+
+// SetReceiptLogs replaces the logs of a mined transaction's receipt, filling
+// in their block and transaction fields and the receipt's bloom
+func (b *Backend) SetReceiptLogs(txHash common.Hash, logs ...*types.Log) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, ok := b.receipts[txHash]
+	if !ok {
+		return
+	}
+	for i, log := range logs {
+		log.TxHash = txHash
+		log.TxIndex = r.TransactionIndex
+		log.BlockHash = r.BlockHash
+		log.BlockNumber = r.BlockNumber.Uint64()
+		log.Index = uint(i)
+	}
+	r.Logs = logs
+	r.Bloom = types.CreateBloom(r)
+}
+
+// TruncateBody makes block responses for blockHash list only the first n
+// transactions, like a node serving an incompletely populated body. The
+// header, and so the transaction root, is unchanged.
+func (b *Backend) TruncateBody(blockHash common.Hash, n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bodies[blockHash] = n
+}
+
+// ServeBlockAs makes block lookups by hash return block, like a faulty or
+// malicious node answering with the wrong block
+func (b *Backend) ServeBlockAs(hash common.Hash, block *types.Block) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.aliases[hash] = block
+}
+
+// Rewind drops every block above number, simulating a reorg. Transactions
+// in dropped blocks are forgotten rather than returned to the mempool.
+func (b *Backend) Rewind(number uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for len(b.blocks) > int(number)+1 {
+		last := b.blocks[len(b.blocks)-1]
+		for _, tx := range last.Transactions() {
+			delete(b.receipts, tx.Hash())
+			delete(b.lookup, tx.Hash())
+			if from, err := types.Sender(b.signer, tx); err == nil && b.nonces[from] > 0 {
+				b.nonces[from]--
+			}
+		}
+		b.blocks = b.blocks[:len(b.blocks)-1]
+	}
+}
+
+func (b *Backend) appendBlock(txs []*types.Transaction) *types.Block {
+	parent := b.blocks[len(b.blocks)-1]
+	number := parent.NumberU64() + 1
+
+	block := b.makeBlock(parent.Hash(), number, txs)
+	b.blocks = append(b.blocks, block)
+
+	var cumulative uint64
+	for i, tx := range block.Transactions() {
+		cumulative += tx.Gas()
+		receipt := &types.Receipt{
+			Type:              tx.Type(),
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: cumulative,
+			Logs:              []*types.Log{},
+			TxHash:            tx.Hash(),
+			GasUsed:           tx.Gas(),
+			EffectiveGasPrice: effectiveGasPrice(tx, block.BaseFee()),
+			BlockHash:         block.Hash(),
+			BlockNumber:       block.Number(),
+			TransactionIndex:  uint(i),
+		}
+		receipt.Bloom = types.CreateBloom(receipt)
+		b.receipts[tx.Hash()] = receipt
+		b.lookup[tx.Hash()] = txLookup{block: number, index: i}
+
+		if from, err := types.Sender(b.signer, tx); err == nil && tx.Nonce() >= b.nonces[from] {
+			b.nonces[from] = tx.Nonce() + 1
+		}
+	}
+
+	b.notifyHead(block.Header())
+	return block
+}
+
+func (b *Backend) makeBlock(parent common.Hash, number uint64, txs []*types.Transaction) *types.Block {
+	var gasUsed uint64
+	for _, tx := range txs {
+		gasUsed += tx.Gas()
+	}
+	header := &types.Header{
+		ParentHash: parent,
+		Number:     new(big.Int).SetUint64(number),
+		GasLimit:   DefaultGasLimit,
+		GasUsed:    gasUsed,
+		Time:       number * BlockTime,
+		Difficulty: new(big.Int),
+		BaseFee:    new(big.Int).Set(b.baseFee),
+	}
+	return types.NewBlock(header, &types.Body{Transactions: txs}, nil, trie.NewStackTrie(nil))
+}
+
+func effectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return tx.GasPrice()
+	}
+	price := new(big.Int).Add(tx.GasTipCap(), baseFee)
+	if price.Cmp(tx.GasFeeCap()) > 0 {
+		return tx.GasFeeCap()
+	}
+	return price
+}
+
+// enter records a call to method, runs its hook and returns its injected
+// fault, if any. The caller must hold b.mu; it is released while the hook runs.
+func (b *Backend) enter(method string) error {
+	b.calls[method]++
+	if hook := b.hooks[method]; hook != nil {
+		call := b.calls[method]
+		b.mu.Unlock()
+		hook(call)
+		b.mu.Lock()
+	}
+	return b.faults[method]
+}
+
+func (b *Backend) blockByNumber(number rpc.BlockNumber) *types.Block {
+	switch number {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		return b.blocks[len(b.blocks)-1]
+	case rpc.SafeBlockNumber, rpc.FinalizedBlockNumber:
+		if uint64(len(b.blocks)) <= b.finalityLag {
+			return b.blocks[0]
+		}
+		return b.blocks[uint64(len(b.blocks))-1-b.finalityLag]
+	case rpc.EarliestBlockNumber:
+		return b.blocks[0]
+	}
+	if number < 0 || int64(number) >= int64(len(b.blocks)) {
+		return nil
+	}
+	return b.blocks[number]
+}
+
+func (b *Backend) blockByHash(hash common.Hash) *types.Block {
+	if block, ok := b.aliases[hash]; ok {
+		return block
+	}
+	for _, block := range b.blocks {
+		if block.Hash() == hash {
+			return block
+		}
+	}
+	return nil
+}
+
+func (b *Backend) marshalBlock(block *types.Block, full bool) (map[string]interface{}, error) {
+	fields, err := toMap(block.Header())
+	if err != nil {
+		return nil, err
+	}
+
+	body := block.Transactions()
+	if n, ok := b.bodies[block.Hash()]; ok && n < len(body) {
+		body = body[:n]
+	}
+
+	txs := make([]interface{}, len(body))
+	for i, tx := range body {
+		if !full {
+			txs[i] = tx.Hash()
+			continue
+		}
+		txs[i], err = b.marshalTx(tx, block, i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fields["transactions"] = txs
+	fields["uncles"] = []common.Hash{}
+	return fields, nil
+}
+
+func (b *Backend) marshalTx(tx *types.Transaction, block *types.Block, index int) (map[string]interface{}, error) {
+	fields, err := toMap(tx)
+	if err != nil {
+		return nil, err
+	}
+	if from, err := types.Sender(b.signer, tx); err == nil {
+		fields["from"] = from
+	}
+	if block != nil {
+		fields["blockHash"] = block.Hash()
+		fields["blockNumber"] = (*hexutil.Big)(block.Number())
+		fields["transactionIndex"] = hexutil.Uint64(index)
+	}
+	return fields, nil
+}
+
+func toMap(v interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// ethAPI implements the eth namespace on top of a Backend.
+type ethAPI struct {
+	b *Backend
+}
+
+func (api *ethAPI) ChainId() (*hexutil.Big, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_chainId"); err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(api.b.chainID), nil
+}
+
+func (api *ethAPI) BlockNumber() (hexutil.Uint64, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_blockNumber"); err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(len(api.b.blocks) - 1), nil
+}
+
+func (api *ethAPI) MaxPriorityFeePerGas() (*hexutil.Big, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_maxPriorityFeePerGas"); err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(api.b.tip), nil
+}
+
+type feeHistoryResult struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+func (api *ethAPI) FeeHistory(blockCount hexutil.Uint, lastBlock rpc.BlockNumber, percentiles []float64) (*feeHistoryResult, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_feeHistory"); err != nil {
+		return nil, err
+	}
+
+	history := api.b.history
+	if history == nil {
+		history = api.b.deriveFeeHistory(uint64(blockCount), lastBlock, percentiles)
+	}
+
+	result := &feeHistoryResult{
+		OldestBlock:  (*hexutil.Big)(history.OldestBlock),
+		GasUsedRatio: history.GasUsedRatio,
+	}
+	for _, fee := range history.BaseFee {
+		result.BaseFee = append(result.BaseFee, (*hexutil.Big)(fee))
+	}
+	for _, rewards := range history.Reward {
+		row := make([]*hexutil.Big, len(rewards))
+		for i, reward := range rewards {
+			row[i] = (*hexutil.Big)(reward)
+		}
+		result.Reward = append(result.Reward, row)
+	}
+	return result, nil
+}
+
+func (b *Backend) deriveFeeHistory(count uint64, lastBlock rpc.BlockNumber, percentiles []float64) *ethereum.FeeHistory {
+	last := b.blockByNumber(lastBlock)
+	if last == nil {
+		last = b.blocks[len(b.blocks)-1]
+	}
+	if count > last.NumberU64()+1 {
+		count = last.NumberU64() + 1
+	}
+
+	oldest := last.NumberU64() + 1 - count
+	history := &ethereum.FeeHistory{OldestBlock: new(big.Int).SetUint64(oldest)}
+	for n := oldest; n <= last.NumberU64(); n++ {
+		block := b.blocks[n]
+		history.BaseFee = append(history.BaseFee, block.BaseFee())
+		history.GasUsedRatio = append(history.GasUsedRatio, float64(block.GasUsed())/float64(block.GasLimit()))
+
+		rewards := make([]*big.Int, len(percentiles))
+		for i, p := range percentiles {
+			if b.rewards != nil {
+				rewards[i] = b.rewards(p)
+			} else {
+				rewards[i] = new(big.Int).Set(b.tip)
+			}
+		}
+		history.Reward = append(history.Reward, rewards)
+	}
+	history.BaseFee = append(history.BaseFee, new(big.Int).Set(b.baseFee))
+	return history
+}
+
+func (api *ethAPI) GetBlockByNumber(number rpc.BlockNumber, full bool) (map[string]interface{}, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_getBlockByNumber"); err != nil {
+		return nil, err
+	}
+	block := api.b.blockByNumber(number)
+	if block == nil {
+		return nil, nil
+	}
+	return api.b.marshalBlock(block, full)
+}
+
+func (api *ethAPI) GetBlockByHash(hash common.Hash, full bool) (map[string]interface{}, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_getBlockByHash"); err != nil {
+		return nil, err
+	}
+	block := api.b.blockByHash(hash)
+	if block == nil {
+		return nil, nil
+	}
+	return api.b.marshalBlock(block, full)
+}
+
+func (api *ethAPI) GetTransactionByHash(hash common.Hash) (map[string]interface{}, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_getTransactionByHash"); err != nil {
+		return nil, err
+	}
+	if loc, ok := api.b.lookup[hash]; ok {
+		block := api.b.blocks[loc.block]
+		return api.b.marshalTx(block.Transactions()[loc.index], block, loc.index)
+	}
+	for _, tx := range api.b.pool {
+		if tx.Hash() == hash {
+			return api.b.marshalTx(tx, nil, 0)
+		}
+	}
+	return nil, nil
+}
+
+func (api *ethAPI) GetTransactionByBlockHashAndIndex(hash common.Hash, index hexutil.Uint64) (map[string]interface{}, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_getTransactionByBlockHashAndIndex"); err != nil {
+		return nil, err
+	}
+	block := api.b.blockByHash(hash)
+	if block == nil || uint64(index) >= uint64(len(block.Transactions())) {
+		return nil, nil
+	}
+	return api.b.marshalTx(block.Transactions()[index], block, int(index))
+}
+
+func (api *ethAPI) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_getTransactionReceipt"); err != nil {
+		return nil, err
+	}
+	return api.b.receipts[hash], nil
+}
+
+func (api *ethAPI) GetBlockReceipts(blockNrOrHash rpc.BlockNumberOrHash) ([]*types.Receipt, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_getBlockReceipts"); err != nil {
+		return nil, err
+	}
+
+	var block *types.Block
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block = api.b.blockByHash(hash)
+	} else if number, ok := blockNrOrHash.Number(); ok {
+		block = api.b.blockByNumber(number)
+	}
+	if block == nil {
+		return nil, nil
+	}
+
+	receipts := make([]*types.Receipt, 0, len(block.Transactions()))
+	for _, tx := range block.Transactions() {
+		receipts = append(receipts, api.b.receipts[tx.Hash()])
+	}
+	return receipts, nil
+}
+
+func (api *ethAPI) GetTransactionCount(addr common.Address, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_getTransactionCount"); err != nil {
+		return 0, err
+	}
+
+	nonce := api.b.nonces[addr]
+	if number, ok := blockNrOrHash.Number(); ok && number == rpc.PendingBlockNumber {
+		for _, tx := range api.b.pool {
+			if from, err := types.Sender(api.b.signer, tx); err == nil && from == addr {
+				nonce++
+			}
+		}
+	}
+	return hexutil.Uint64(nonce), nil
+}
+
+func (api *ethAPI) GetBalance(addr common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_getBalance"); err != nil {
+		return nil, err
+	}
+	if balance, ok := api.b.balances[addr]; ok {
+		return (*hexutil.Big)(balance), nil
+	}
+	return (*hexutil.Big)(big.NewInt(1e18)), nil
+}
+
+func (api *ethAPI) SendRawTransaction(input hexutil.Bytes) (common.Hash, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_sendRawTransaction"); err != nil {
+		return common.Hash{}, err
+	}
+
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	from, err := types.Sender(api.b.signer, tx)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid sender: %w", err)
+	}
+	if tx.Nonce() < api.b.nonces[from] {
+		return common.Hash{}, errors.New("nonce too low")
+	}
+	if _, mined := api.b.lookup[tx.Hash()]; mined {
+		return common.Hash{}, errors.New("already known")
+	}
+	for _, pending := range api.b.pool {
+		if pending.Hash() == tx.Hash() {
+			return common.Hash{}, errors.New("already known")
+		}
+	}
+
+	api.b.pool = append(api.b.pool, tx)
+	return tx.Hash(), nil
+}
+
+type debugAPI struct {
+	b *Backend
+}
+
+// TraceBlockByNumber returns the trace set with SetBlockTrace. The tracer
+// config is ignored.
+func (api *debugAPI) TraceBlockByNumber(number rpc.BlockNumber, config map[string]interface{}) (json.RawMessage, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("debug_traceBlockByNumber"); err != nil {
+		return nil, err
+	}
+
+	block := api.b.blockByNumber(number)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	if trace, ok := api.b.traces[block.NumberU64()]; ok {
+		return trace, nil
+	}
+	return json.RawMessage("[]"), nil
+}
+
+type txpoolAPI struct {
+	b *Backend
+}
+
+// Content lists the mempool as pending transactions keyed by sender and
+// nonce. Nothing is ever queued.
+func (api *txpoolAPI) Content() (map[string]map[string]map[string]map[string]interface{}, error) {
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("txpool_content"); err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string]map[string]map[string]interface{})
+	for _, tx := range api.b.pool {
+		from, err := types.Sender(api.b.signer, tx)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := api.b.marshalTx(tx, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		sender := from.Hex()
+		if pending[sender] == nil {
+			pending[sender] = make(map[string]map[string]interface{})
+		}
+		pending[sender][fmt.Sprint(tx.Nonce())] = fields
+	}
+
+	return map[string]map[string]map[string]map[string]interface{}{
+		"pending": pending,
+		"queued":  {},
+	}, nil
+}
@@ -0,0 +1,101 @@
+package ethtest
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DropSubscriptions closes every WebSocket connection, as if the node
+// restarted. Subscribers see their subscription fail and may reconnect.
+func (b *Backend) DropSubscriptions() {
+	b.wsConns.closeAll()
+}
+
+// Subscribers returns the number of live newHeads subscriptions.
+func (b *Backend) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.heads)
+}
+
+// notifyHead sends header to every newHeads subscriber. The caller holds b.mu.
+func (b *Backend) notifyHead(header *types.Header) {
+	for id, notifier := range b.heads {
+		if err := notifier.Notify(id, header); err != nil {
+			delete(b.heads, id)
+		}
+	}
+}
+
+// NewHeads serves eth_subscribe("newHeads"), notifying every mined block.
+func (api *ethAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	api.b.mu.Lock()
+	defer api.b.mu.Unlock()
+	if err := api.b.enter("eth_subscribe"); err != nil {
+		return nil, err
+	}
+
+	sub := notifier.CreateSubscription()
+	api.b.heads[sub.ID] = notifier
+
+	go func() {
+		<-sub.Err()
+		api.b.mu.Lock()
+		delete(api.b.heads, sub.ID)
+		api.b.mu.Unlock()
+	}()
+
+	return sub, nil
+}
+
+// newWSServer serves srv over WebSocket, tracking connections so they can be
+// dropped. httptest forgets hijacked connections, so they are recorded at
+// the listener.
+func newWSServer(srv *rpc.Server) (*httptest.Server, *connTracker) {
+	ws := httptest.NewUnstartedServer(srv.WebsocketHandler([]string{"*"}))
+	tracker := &connTracker{Listener: ws.Listener}
+	ws.Listener = tracker
+	ws.Start()
+	return ws, tracker
+}
+
+// connTracker is a net.Listener recording accepted connections
+type connTracker struct {
+	net.Listener
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (l *connTracker) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	l.conns = append(l.conns, conn)
+	l.mu.Unlock()
+	return conn, nil
+}
+
+func (l *connTracker) closeAll() {
+	l.mu.Lock()
+	conns := l.conns
+	l.conns = nil
+	l.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
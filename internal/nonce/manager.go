@@ -17,34 +17,275 @@ const (
 type Manager struct {
 	mu            sync.Mutex
 	pendingNonces map[common.Address]uint64
+	reserved      map[common.Address]*reservations
 	client        *ethclient.Client
+
+	resyncInterval  time.Duration
+	resyncTolerance uint64
+	done            chan struct{}
+	closeOnce       sync.Once
+
+	// store persists reservations; nil keeps them in memory only
+	store Store
+
+	// issued counts the nonces handed out per address, so Sync can tell
+	// whether any were taken while it queried the node
+	issued map[common.Address]uint64
+}
+
+// reservations are an address's reserved nonces by key, with the reverse
+// index next uses to skip them
+type reservations struct {
+	byKey  map[string]uint64
+	nonces map[uint64]struct{}
+}
+
+func newReservations() *reservations {
+	return &reservations{
+		byKey:  make(map[string]uint64),
+		nonces: make(map[uint64]struct{}),
+	}
+}
+
+func (r *reservations) add(key string, nonce uint64) {
+	r.byKey[key] = nonce
+	r.nonces[nonce] = struct{}{}
+}
+
+func (r *reservations) remove(key string) {
+	delete(r.nonces, r.byKey[key])
+	delete(r.byKey, key)
+}
+
+// has reports whether nonce is reserved for any key
+func (r *reservations) has(nonce uint64) bool {
+	_, reserved := r.nonces[nonce]
+	return reserved
 }
 
-func New(client *ethclient.Client) *Manager {
-	return &Manager{
+// Store persists nonce reservations so they outlive the process
+type Store interface {
+	// Load returns address's reservations by key
+	Load(address common.Address) (map[string]uint64, error)
+	// Save records that key reserved nonce for address
+	Save(address common.Address, key string, nonce uint64) error
+	// Delete drops key's reservation for address
+	Delete(address common.Address, key string) error
+}
+
+// Option configures optional Manager behaviour
+type Option func(*Manager)
+
+// WithResync makes the manager call Resync every interval, resetting any
+// cached nonce more than tolerance ahead of the node's pending nonce.
+// Resync is disabled unless interval is positive.
+func WithResync(interval time.Duration, tolerance uint64) Option {
+	return func(m *Manager) {
+		m.resyncInterval = interval
+		m.resyncTolerance = tolerance
+	}
+}
+
+// WithStore persists reservations in store. An address's reservations are
+// loaded the first time the manager hands out one of its nonces.
+func WithStore(store Store) Option {
+	return func(m *Manager) {
+		m.store = store
+	}
+}
+
+func New(client *ethclient.Client, opts ...Option) *Manager {
+	m := &Manager{
 		pendingNonces: make(map[common.Address]uint64),
+		reserved:      make(map[common.Address]*reservations),
+		issued:        make(map[common.Address]uint64),
 		client:        client,
+		done:          make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(m)
 	}
+
+	if m.resyncInterval > 0 {
+		go m.resyncLoop()
+	}
+	return m
 }
 
 func (m *Manager) GetNext(address common.Address) (uint64, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.next(address)
+}
+
+// Reserve returns the nonce reserved for key, taking the next nonce the
+// first time a key is seen. Reservations survive Reset, and with WithStore
+// they survive restarts too. Reserved nonces are skipped by GetNext. Once
+// the account's confirmed nonce passes a reservation it is dropped, the
+// next time the nonce is fetched from the node or synced.
+func (m *Manager) Reserve(address common.Address, key string) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	if nonce, exists := m.pendingNonces[address]; exists {
-		m.pendingNonces[address]++
+	reserved, err := m.reservations(address)
+	if err != nil {
+		return 0, err
+	}
+	if nonce, exists := reserved.byKey[key]; exists {
 		return nonce, nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
-	defer cancel()
+	nonce, err := m.next(address)
+	if err != nil {
+		return 0, err
+	}
+
+	if m.store != nil {
+		if err := m.store.Save(address, key, nonce); err != nil {
+			m.pendingNonces[address] = nonce
+			return 0, fmt.Errorf("failed to save reservation: %w", err)
+		}
+	}
+	reserved.add(key, nonce)
+	return nonce, nil
+}
 
-	nonce, err := m.client.PendingNonceAt(ctx, address)
+// Release drops key's reservation, e.g. after its transaction failed to
+// send, so the nonce can be handed out again once the cache is reset
+func (m *Manager) Release(address common.Address, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reserved, loaded := m.reserved[address]
+	if !loaded {
+		return nil
+	}
+	if _, exists := reserved.byKey[key]; !exists {
+		return nil
+	}
+	if m.store != nil {
+		if err := m.store.Delete(address, key); err != nil {
+			return fmt.Errorf("failed to delete reservation: %w", err)
+		}
+	}
+	reserved.remove(key)
+	return nil
+}
+
+// reservations returns address's reservations, loading them from the store
+// the first time
+func (m *Manager) reservations(address common.Address) (*reservations, error) {
+	if reserved, loaded := m.reserved[address]; loaded {
+		return reserved, nil
+	}
+
+	reserved := newReservations()
+	if m.store != nil {
+		stored, err := m.store.Load(address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load reservations: %w", err)
+		}
+		for key, nonce := range stored {
+			reserved.add(key, nonce)
+		}
+	}
+	m.reserved[address] = reserved
+	return reserved, nil
+}
+
+// pruneReservations drops address's reservations below confirmed, whose
+// transactions, or ones replacing them, are already mined
+func (m *Manager) pruneReservations(address common.Address, reserved *reservations, confirmed uint64) error {
+	for key, nonce := range reserved.byKey {
+		if nonce >= confirmed {
+			continue
+		}
+		if m.store != nil {
+			if err := m.store.Delete(address, key); err != nil {
+				return fmt.Errorf("failed to delete reservation: %w", err)
+			}
+		}
+		reserved.remove(key)
+	}
+	return nil
+}
+
+// confirmedNonce fetches address's nonce at the latest block, below which
+// reservations can be pruned
+func (m *Manager) confirmedNonce(ctx context.Context, address common.Address) (uint64, error) {
+	confirmed, err := m.client.NonceAt(ctx, address, nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get pending nonce: %w", err)
+		return 0, fmt.Errorf("failed to get confirmed nonce: %w", err)
+	}
+	return confirmed, nil
+}
+
+// Peek returns the next n nonces GetNext would hand out for address without
+// taking them. A nonce not yet cached is fetched from the node and cached.
+func (m *Manager) Peek(address common.Address, n int) ([]uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	first, err := m.firstFree(address)
+	if err != nil {
+		return nil, err
+	}
+	m.pendingNonces[address] = first
+
+	reserved := m.reserved[address]
+	nonces := make([]uint64, 0, n)
+	for nonce := first; len(nonces) < n; nonce++ {
+		if !reserved.has(nonce) {
+			nonces = append(nonces, nonce)
+		}
 	}
+	return nonces, nil
+}
 
+func (m *Manager) next(address common.Address) (uint64, error) {
+	nonce, err := m.firstFree(address)
+	if err != nil {
+		return 0, err
+	}
 	m.pendingNonces[address] = nonce + 1
+	m.issued[address]++
+	return nonce, nil
+}
+
+// firstFree returns the next unreserved nonce for address without taking
+// it, fetching the nonce from the node if none is cached
+func (m *Manager) firstFree(address common.Address) (uint64, error) {
+	reserved, err := m.reservations(address)
+	if err != nil {
+		return 0, err
+	}
+
+	nonce, exists := m.pendingNonces[address]
+	if !exists {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		defer cancel()
+
+		nonce, err = m.client.PendingNonceAt(ctx, address)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get pending nonce: %w", err)
+		}
+
+		// Reset leaves the cache empty, so pruning here covers it too
+		if len(reserved.byKey) > 0 {
+			confirmed, err := m.confirmedNonce(ctx, address)
+			if err != nil {
+				return 0, err
+			}
+			if err := m.pruneReservations(address, reserved, confirmed); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	for reserved.has(nonce) {
+		nonce++
+	}
 	return nonce, nil
 }
 
@@ -66,3 +307,98 @@ func (m *Manager) ResetAll() {
 	defer m.mu.Unlock()
 	m.pendingNonces = make(map[common.Address]uint64)
 }
+
+// Sync fetches address's pending nonce from the node and makes it the next
+// nonce GetNext hands out, whether the cache was behind or ahead, e.g. after
+// another tool sent from the same account. If GetNext handed out nonces
+// while the node was queried, the node's answer may predate them, so the
+// cache is only moved forward. Reservations the account's confirmed nonce
+// has passed are dropped. It returns the new next nonce.
+func (m *Manager) Sync(ctx context.Context, address common.Address) (uint64, error) {
+	m.mu.Lock()
+	issued := m.issued[address]
+	reserved, err := m.reservations(address)
+	prune := err == nil && len(reserved.byKey) > 0
+	m.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	// Query without the lock so sends are not blocked on the node
+	nonce, err := m.client.PendingNonceAt(ctx, address)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pending nonce: %w", err)
+	}
+	var confirmed uint64
+	if prune {
+		if confirmed, err = m.confirmedNonce(ctx, address); err != nil {
+			return 0, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if prune {
+		if err := m.pruneReservations(address, reserved, confirmed); err != nil {
+			return 0, err
+		}
+	}
+	if cached, exists := m.pendingNonces[address]; exists && m.issued[address] != issued && cached > nonce {
+		return cached, nil
+	}
+	m.pendingNonces[address] = nonce
+	return nonce, nil
+}
+
+// Resync compares every cached nonce with the node's pending nonce and
+// resets those more than the configured tolerance ahead, e.g. after sends
+// that took a nonce but never reached the node. It returns the addresses
+// that were reset.
+func (m *Manager) Resync(ctx context.Context) ([]common.Address, error) {
+	m.mu.Lock()
+	addresses := make([]common.Address, 0, len(m.pendingNonces))
+	for address := range m.pendingNonces {
+		addresses = append(addresses, address)
+	}
+	m.mu.Unlock()
+
+	var reset []common.Address
+	for _, address := range addresses {
+		// Query without the lock so sends are not blocked on the node
+		onChain, err := m.client.PendingNonceAt(ctx, address)
+		if err != nil {
+			return reset, fmt.Errorf("failed to get pending nonce: %w", err)
+		}
+
+		m.mu.Lock()
+		if cached, exists := m.pendingNonces[address]; exists && cached > onChain+m.resyncTolerance {
+			m.pendingNonces[address] = onChain
+			reset = append(reset, address)
+		}
+		m.mu.Unlock()
+	}
+
+	return reset, nil
+}
+
+func (m *Manager) resyncLoop() {
+	ticker := time.NewTicker(m.resyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+			// Errors are retried on the next tick
+			_, _ = m.Resync(ctx)
+			cancel()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// Close stops the periodic resync, if any
+func (m *Manager) Close() {
+	m.closeOnce.Do(func() { close(m.done) })
+}
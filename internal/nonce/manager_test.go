@@ -0,0 +1,251 @@
+package nonce_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/k4rz4/ethereum-custom-transactions/internal/ethtest"
+	"github.com/k4rz4/ethereum-custom-transactions/internal/nonce"
+)
+
+var testAddress = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+func newClient(t *testing.T, backend *ethtest.Backend) *ethclient.Client {
+	t.Helper()
+	client, err := ethclient.Dial(backend.URL)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// drift takes n nonces that never reach the node
+func drift(t *testing.T, m *nonce.Manager, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := m.GetNext(testAddress); err != nil {
+			t.Fatalf("GetNext failed: %v", err)
+		}
+	}
+}
+
+func TestResync(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	backend.SetNonce(testAddress, 3)
+	m := nonce.New(newClient(t, backend), nonce.WithResync(time.Hour, 2))
+	defer m.Close()
+
+	ctx := context.Background()
+
+	// Two nonces ahead is within tolerance
+	drift(t, m, 2)
+	reset, err := m.Resync(ctx)
+	if err != nil {
+		t.Fatalf("Resync failed: %v", err)
+	}
+	if len(reset) != 0 {
+		t.Errorf("reset %v within tolerance", reset)
+	}
+	if cached, _ := m.GetCached(testAddress); cached != 5 {
+		t.Errorf("cached nonce = %d, want 5", cached)
+	}
+
+	drift(t, m, 1)
+	reset, err = m.Resync(ctx)
+	if err != nil {
+		t.Fatalf("Resync failed: %v", err)
+	}
+	if len(reset) != 1 || reset[0] != testAddress {
+		t.Errorf("reset %v, want %s", reset, testAddress.Hex())
+	}
+	if cached, _ := m.GetCached(testAddress); cached != 3 {
+		t.Errorf("cached nonce = %d, want on-chain 3", cached)
+	}
+}
+
+func TestPeriodicResync(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	m := nonce.New(newClient(t, backend), nonce.WithResync(10*time.Millisecond, 0))
+	defer m.Close()
+
+	drift(t, m, 5)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cached, _ := m.GetCached(testAddress)
+		if cached == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cached nonce still %d, want resync to 0", cached)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if next, err := m.GetNext(testAddress); err != nil || next != 0 {
+		t.Errorf("GetNext = %d, %v; want 0 after resync", next, err)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	backend.SetNonce(testAddress, 4)
+	m := nonce.New(newClient(t, backend))
+	defer m.Close()
+
+	for i := 0; i < 2; i++ {
+		nonces, err := m.Peek(testAddress, 3)
+		if err != nil {
+			t.Fatalf("Peek failed: %v", err)
+		}
+		if len(nonces) != 3 || nonces[0] != 4 || nonces[1] != 5 || nonces[2] != 6 {
+			t.Fatalf("Peek = %v, want [4 5 6]", nonces)
+		}
+	}
+
+	if next, err := m.GetNext(testAddress); err != nil || next != 4 {
+		t.Errorf("GetNext after Peek = %d, %v; want 4", next, err)
+	}
+}
+
+func TestResetSkipsReservedNonces(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	backend.SetNonce(testAddress, 4)
+	m := nonce.New(newClient(t, backend))
+	defer m.Close()
+
+	if reserved, err := m.Reserve(testAddress, "order"); err != nil || reserved != 4 {
+		t.Fatalf("Reserve = %d, %v; want 4", reserved, err)
+	}
+
+	// The reserved nonce never reached the node, so a refetch starts at it
+	m.Reset(testAddress)
+	if nonces, err := m.Peek(testAddress, 2); err != nil || nonces[0] != 5 || nonces[1] != 6 {
+		t.Fatalf("Peek = %v, %v; want [5 6]", nonces, err)
+	}
+	if next, err := m.GetNext(testAddress); err != nil || next != 5 {
+		t.Errorf("GetNext after Reset = %d, %v; want 5", next, err)
+	}
+
+	if err := m.Release(testAddress, "order"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	m.Reset(testAddress)
+	if next, err := m.GetNext(testAddress); err != nil || next != 4 {
+		t.Errorf("GetNext after Release = %d, %v; want 4", next, err)
+	}
+}
+
+func TestSyncDuringGetNext(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	backend.SetNonce(testAddress, 3)
+	m := nonce.New(newClient(t, backend))
+	defer m.Close()
+
+	drift(t, m, 1)
+
+	// A send takes nonce 4 while Sync waits on the node, whose answer
+	// predates it
+	var during uint64
+	backend.OnCall("eth_getTransactionCount", func(call int) {
+		if call == 2 {
+			during, _ = m.GetNext(testAddress)
+		}
+	})
+
+	synced, err := m.Sync(context.Background(), testAddress)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if during != 4 || synced != 5 {
+		t.Errorf("GetNext during Sync = %d and Sync = %d, want 4 and 5", during, synced)
+	}
+	if next, err := m.GetNext(testAddress); err != nil || next != 5 {
+		t.Errorf("GetNext after Sync = %d, %v; want 5 so nonce 4 is not reused", next, err)
+	}
+
+	// Without sends in between, Sync moves the cache back to the node's
+	backend.OnCall("eth_getTransactionCount", nil)
+	if synced, err := m.Sync(context.Background(), testAddress); err != nil || synced != 3 {
+		t.Errorf("idle Sync = %d, %v; want 3", synced, err)
+	}
+}
+
+// memStore is a nonce.Store kept in memory
+type memStore struct {
+	reserved map[string]uint64
+}
+
+func (s *memStore) Load(common.Address) (map[string]uint64, error) {
+	return s.reserved, nil
+}
+
+func (s *memStore) Save(_ common.Address, key string, nonce uint64) error {
+	s.reserved[key] = nonce
+	return nil
+}
+
+func (s *memStore) Delete(_ common.Address, key string) error {
+	delete(s.reserved, key)
+	return nil
+}
+
+func TestReservationsPrunedOnceConfirmed(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	backend.SetNonce(testAddress, 4)
+	store := &memStore{reserved: make(map[string]uint64)}
+	m := nonce.New(newClient(t, backend), nonce.WithStore(store))
+	defer m.Close()
+
+	if reserved, err := m.Reserve(testAddress, "first"); err != nil || reserved != 4 {
+		t.Fatalf("Reserve = %d, %v; want 4", reserved, err)
+	}
+
+	// Nonce 4 is mined, so Sync drops its reservation
+	backend.SetNonce(testAddress, 5)
+	if _, err := m.Sync(context.Background(), testAddress); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(store.reserved) != 0 {
+		t.Errorf("store holds %v after Sync, want nothing", store.reserved)
+	}
+	if reserved, err := m.Reserve(testAddress, "first"); err != nil || reserved != 5 {
+		t.Fatalf("Reserve after pruning = %d, %v; want a fresh nonce 5", reserved, err)
+	}
+
+	// The refetch after Reset prunes too
+	backend.SetNonce(testAddress, 6)
+	m.Reset(testAddress)
+	if next, err := m.GetNext(testAddress); err != nil || next != 6 {
+		t.Errorf("GetNext after Reset = %d, %v; want 6", next, err)
+	}
+	if len(store.reserved) != 0 {
+		t.Errorf("store holds %v after Reset, want nothing", store.reserved)
+	}
+}
+
+func TestPeekDuringSync(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	backend.SetNonce(testAddress, 3)
+	m := nonce.New(newClient(t, backend))
+	defer m.Close()
+
+	drift(t, m, 2)
+
+	// A preview while Sync waits on the node takes no nonce, so Sync still
+	// moves the cache back
+	backend.OnCall("eth_getTransactionCount", func(call int) {
+		if call == 2 {
+			_, _ = m.Peek(testAddress, 1)
+		}
+	})
+
+	if synced, err := m.Sync(context.Background(), testAddress); err != nil || synced != 3 {
+		t.Errorf("Sync = %d, %v; want 3", synced, err)
+	}
+}
@@ -1,17 +1,46 @@
 package pool
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/big"
 	"sync"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// RetryClassifier reports whether a failed call may succeed on another client
+type RetryClassifier func(err error) bool
+
 type ClientPool struct {
-	clients []*ethclient.Client
-	current int
-	mu      sync.RWMutex
-	closed  bool
+	clients   []*ethclient.Client
+	current   int
+	mu        sync.RWMutex
+	closed    bool
+	retryable RetryClassifier
+}
+
+// DefaultRetryable retries transport failures and HTTP errors. JSON-RPC
+// errors, missing results and context errors are returned as-is, since
+// another endpoint would answer the same way.
+func DefaultRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ethereum.NotFound) {
+		return false
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return true
+	}
+
+	var rpcErr rpc.Error
+	return !errors.As(err, &rpcErr)
 }
 
 // New creates a new client pool
@@ -38,9 +67,38 @@ func New(rpcURL string, size int) (*ClientPool, error) {
 	}
 
 	return &ClientPool{
-		clients: clients,
-		current: 0,
-		closed:  false,
+		clients:   clients,
+		current:   0,
+		closed:    false,
+		retryable: DefaultRetryable,
+	}, nil
+}
+
+// NewMulti creates a pool with one client per endpoint, so Do can route
+// around a failing node
+func NewMulti(rpcURLs []string) (*ClientPool, error) {
+	if len(rpcURLs) == 0 {
+		return nil, fmt.Errorf("no RPC endpoints given")
+	}
+
+	clients := make([]*ethclient.Client, len(rpcURLs))
+
+	for i, rpcURL := range rpcURLs {
+		client, err := ethclient.Dial(rpcURL)
+		if err != nil {
+			for j := 0; j < i; j++ {
+				clients[j].Close()
+			}
+			return nil, fmt.Errorf("failed to create client for %s: %w", rpcURL, err)
+		}
+		clients[i] = client
+	}
+
+	return &ClientPool{
+		clients:   clients,
+		current:   0,
+		closed:    false,
+		retryable: DefaultRetryable,
 	}, nil
 }
 
@@ -58,6 +116,101 @@ func (p *ClientPool) Get() *ethclient.Client {
 	return client
 }
 
+// GetAt returns client i modulo the pool size, so a caller can keep using
+// the same connection
+func (p *ClientPool) GetAt(i int) *ethclient.Client {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return nil
+	}
+	n := len(p.clients)
+	return p.clients[(i%n+n)%n]
+}
+
+// VerifyChainID asks every client for its chain ID and returns it, or an
+// error if the endpoints disagree, e.g. a pool mixing two networks
+func (p *ClientPool) VerifyChainID(ctx context.Context) (*big.Int, error) {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return nil, fmt.Errorf("client pool is closed")
+	}
+	clients := p.clients
+	p.mu.RUnlock()
+
+	var chainID *big.Int
+	for i, client := range clients {
+		id, err := client.ChainID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chain ID from client %d: %w", i, err)
+		}
+		if chainID == nil {
+			chainID = id
+			continue
+		}
+		if id.Cmp(chainID) != 0 {
+			return nil, fmt.Errorf("client %d reports chain ID %s, client 0 reports %s", i, id, chainID)
+		}
+	}
+
+	return chainID, nil
+}
+
+// SetRetryClassifier replaces the classifier Do uses to decide whether a
+// failed call is retried on the next client
+func (p *ClientPool) SetRetryClassifier(retryable RetryClassifier) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+	p.retryable = retryable
+}
+
+// Do calls fn with the next client in the pool. If fn fails with a retryable
+// error, it is retried on the following clients, trying each client at most
+// once. The last error is returned if every attempt fails.
+func (p *ClientPool) Do(ctx context.Context, fn func(*ethclient.Client) error) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return fmt.Errorf("client pool is closed")
+	}
+	start := p.current
+	p.current = (p.current + 1) % len(p.clients)
+	clients := p.clients
+	retryable := p.retryable
+	p.mu.Unlock()
+
+	var err error
+	for i := range clients {
+		err = fn(clients[(start+i)%len(clients)])
+		if err == nil || !retryable(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return fmt.Errorf("all %d clients failed: %w", len(clients), err)
+}
+
+// Clients returns every client in the pool, e.g. to check each endpoint
+func (p *ClientPool) Clients() []*ethclient.Client {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return nil
+	}
+	clients := make([]*ethclient.Client, len(p.clients))
+	copy(clients, p.clients)
+	return clients
+}
+
 // Size returns the number of clients in the pool
 func (p *ClientPool) Size() int {
 	p.mu.RLock()
@@ -0,0 +1,122 @@
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/k4rz4/ethereum-custom-transactions/internal/ethtest"
+	"github.com/k4rz4/ethereum-custom-transactions/internal/pool"
+)
+
+// newBrokenEndpoint starts a server that fails every request
+func newBrokenEndpoint(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "node unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestDoRetriesOnNextClient(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+
+	p, err := pool.NewMulti([]string{newBrokenEndpoint(t), backend.URL})
+	if err != nil {
+		t.Fatalf("NewMulti failed: %v", err)
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	attempts := 0
+	err = p.Do(ctx, func(client *ethclient.Client) error {
+		attempts++
+		chainID, err := client.ChainID(ctx)
+		if err == nil && chainID.Int64() != ethtest.DefaultChainID {
+			t.Errorf("chain ID = %s, want %d", chainID, ethtest.DefaultChainID)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("made %d attempts, want 2", attempts)
+	}
+}
+
+func TestDoStopsOnNonRetryableError(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	p, err := pool.NewMulti([]string{newBrokenEndpoint(t), backend.URL})
+	if err != nil {
+		t.Fatalf("NewMulti failed: %v", err)
+	}
+	defer p.Close()
+
+	permanent := errors.New("permanent")
+	p.SetRetryClassifier(func(err error) bool { return !errors.Is(err, permanent) })
+
+	attempts := 0
+	err = p.Do(context.Background(), func(*ethclient.Client) error {
+		attempts++
+		return permanent
+	})
+	if !errors.Is(err, permanent) || attempts != 1 {
+		t.Errorf("Do = %v after %d attempts, want the permanent error after 1", err, attempts)
+	}
+}
+
+func TestDoAllClientsFail(t *testing.T) {
+	p, err := pool.NewMulti([]string{newBrokenEndpoint(t), newBrokenEndpoint(t)})
+	if err != nil {
+		t.Fatalf("NewMulti failed: %v", err)
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	attempts := 0
+	err = p.Do(ctx, func(client *ethclient.Client) error {
+		attempts++
+		_, err := client.ChainID(ctx)
+		return err
+	})
+	if err == nil || attempts != 2 {
+		t.Errorf("Do = %v after %d attempts, want an error after trying both clients", err, attempts)
+	}
+}
+
+func TestVerifyChainID(t *testing.T) {
+	mainnet := ethtest.NewBackend(t)
+	replica := ethtest.NewBackend(t)
+	other := ethtest.NewBackendWithChainID(t, ethtest.DefaultChainID+1)
+	ctx := context.Background()
+
+	p, err := pool.NewMulti([]string{mainnet.URL, replica.URL})
+	if err != nil {
+		t.Fatalf("NewMulti failed: %v", err)
+	}
+	defer p.Close()
+
+	chainID, err := p.VerifyChainID(ctx)
+	if err != nil {
+		t.Fatalf("VerifyChainID failed: %v", err)
+	}
+	if chainID.Int64() != ethtest.DefaultChainID {
+		t.Errorf("chain ID = %s, want %d", chainID, ethtest.DefaultChainID)
+	}
+
+	mixed, err := pool.NewMulti([]string{mainnet.URL, other.URL})
+	if err != nil {
+		t.Fatalf("NewMulti failed: %v", err)
+	}
+	defer mixed.Close()
+
+	if _, err := mixed.VerifyChainID(ctx); err == nil {
+		t.Error("expected an error for endpoints on different chains")
+	}
+}
@@ -0,0 +1,31 @@
+package batch
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// WorkerSafetyMargin scales the Little's law worker count to absorb
+	// latency spikes
+	WorkerSafetyMargin = 1.5
+	// QueueBufferSeconds is how many seconds of target load the queue holds
+	QueueBufferSeconds = 2
+)
+
+// RecommendConfig suggests a worker count and queue size for sustaining
+// targetTPS when a send takes avgLatency. By Little's law the processor needs
+// targetTPS * avgLatency requests in flight; this is padded by
+// WorkerSafetyMargin. The queue buffers QueueBufferSeconds of load, and at
+// least two requests per worker.
+func RecommendConfig(targetTPS float64, avgLatency time.Duration) (workers, queueSize int) {
+	if targetTPS <= 0 || avgLatency <= 0 {
+		return 1, 100
+	}
+
+	inFlight := targetTPS * avgLatency.Seconds()
+	workers = max(1, int(math.Ceil(inFlight*WorkerSafetyMargin)))
+	queueSize = max(2*workers, int(math.Ceil(targetTPS*QueueBufferSeconds)))
+
+	return workers, queueSize
+}
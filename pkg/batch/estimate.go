@@ -0,0 +1,32 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+// EstimateCost returns the most the requests can cost in fees if sent now:
+// each request's gas limit, its calldata's intrinsic gas plus margin (see
+// transaction.GasLimitFor), times the fee cap mgr's gas strategy currently
+// picks. Transferred values are not included. Fees change from block to
+// block, so the estimate only holds while the fee cap does.
+func EstimateCost(ctx context.Context, mgr *transaction.Manager, reqs []*Request) (*big.Int, error) {
+	_, gasFeeCap, err := mgr.FeeCaps(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee caps: %w", err)
+	}
+
+	gas := new(big.Int)
+	for i, req := range reqs {
+		if req == nil {
+			return nil, fmt.Errorf("request %d is nil", i)
+		}
+		limit := transaction.GasLimitFor(transaction.EncodeCustomData(req.Data, req.CustomData))
+		gas.Add(gas, new(big.Int).SetUint64(limit))
+	}
+
+	return gas.Mul(gas, gasFeeCap), nil
+}
@@ -2,17 +2,167 @@ package batch
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 
 	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
 )
 
+const (
+	// DefaultBlockTimeout is how long Submit waits for space under the Block policy
+	DefaultBlockTimeout = 5 * time.Second
+	// DefaultDedupWindow is how long a request's content is remembered when
+	// deduplicating by content
+	DefaultDedupWindow = time.Minute
+	// DefaultFeeBackoff and DefaultMaxFeeBackoff bound how long a worker
+	// waits between base fee checks under WithMaxBaseFeeWei
+	DefaultFeeBackoff    = time.Second
+	DefaultMaxFeeBackoff = 30 * time.Second
+)
+
+var (
+	// ErrQueueFull is returned by Submit when the queue has no space
+	ErrQueueFull = errors.New("queue is full")
+	// ErrDropped is the Result error of a request evicted by DropOldest
+	ErrDropped = errors.New("request dropped from full queue")
+	// ErrDuplicate is the Result error of a request dropped as a duplicate
+	ErrDuplicate = errors.New("duplicate request")
+	// ErrCustomDataSize is returned by Submit for custom data outside the
+	// configured bounds
+	ErrCustomDataSize = errors.New("custom data size out of bounds")
+	// ErrProcessorShutdown is returned by Submit once Close has been called,
+	// and is the Result error of a request cut short by Close; there it
+	// wraps the error the request failed with, typically context.Canceled
+	ErrProcessorShutdown = errors.New("processor shut down")
+)
+
+// QueueFullPolicy decides what Submit does when the queue is full
+type QueueFullPolicy int
+
+const (
+	// Reject fails the submission with ErrQueueFull
+	Reject QueueFullPolicy = iota
+	// Block waits up to the block timeout for space in the queue
+	Block
+	// DropOldest evicts the longest-queued request, reporting it as a
+	// Result with ErrDropped, and queues the new one
+	DropOldest
+)
+
+func (p QueueFullPolicy) String() string {
+	switch p {
+	case Reject:
+		return "reject"
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop-oldest"
+	default:
+		return fmt.Sprintf("QueueFullPolicy(%d)", int(p))
+	}
+}
+
+// Option configures optional Processor behaviour
+type Option func(*Processor)
+
+// WithQueueFullPolicy sets what Submit does when the queue is full
+// (default Reject)
+func WithQueueFullPolicy(policy QueueFullPolicy) Option {
+	return func(p *Processor) {
+		p.queueFullPolicy = policy
+	}
+}
+
+// WithBlockTimeout bounds how long Submit waits under the Block policy
+// (default DefaultBlockTimeout)
+func WithBlockTimeout(timeout time.Duration) Option {
+	return func(p *Processor) {
+		if timeout > 0 {
+			p.blockTimeout = timeout
+		}
+	}
+}
+
+// WithMinCustomDataBytes rejects requests with less custom data than min
+// (0 means no minimum)
+func WithMinCustomDataBytes(min int) Option {
+	return func(p *Processor) {
+		p.minCustomData = min
+	}
+}
+
+// WithMaxCustomDataBytes rejects requests with more custom data than max
+// (0 means no maximum)
+func WithMaxCustomDataBytes(max int) Option {
+	return func(p *Processor) {
+		p.maxCustomData = max
+	}
+}
+
+// WithDedupByContent drops requests whose recipient, value and custom data
+// match a request submitted within the dedup window. Dropped requests are
+// reported as Results with Duplicate set. Requests Submit rejects are not
+// remembered, so they can be retried.
+func WithDedupByContent(enabled bool) Option {
+	return func(p *Processor) {
+		p.dedupByContent = enabled
+	}
+}
+
+// WithDedupWindow sets how long content is remembered by WithDedupByContent
+// (default DefaultDedupWindow)
+func WithDedupWindow(window time.Duration) Option {
+	return func(p *Processor) {
+		if window > 0 {
+			p.dedupWindow = window
+		}
+	}
+}
+
+// WithMaxBaseFeeWei makes workers check the base fee before each send and
+// hold the request while it is above max, rechecking with exponential
+// backoff. A held request is put back on the queue after its delay, so the
+// worker moves on meanwhile; a request with a PartitionKey is kept by its
+// worker and retried ahead of the rest of its partition, preserving the
+// key's order. Time spent waiting is reported in GetMetrics.
+func WithMaxBaseFeeWei(max *big.Int) Option {
+	return func(p *Processor) {
+		p.maxBaseFee = max
+	}
+}
+
+// WithFeeBackoff sets the first and largest delay between base fee checks
+// under WithMaxBaseFeeWei (defaults DefaultFeeBackoff and
+// DefaultMaxFeeBackoff)
+func WithFeeBackoff(initial, max time.Duration) Option {
+	return func(p *Processor) {
+		if initial > 0 {
+			p.feeBackoff = initial
+		}
+		if max > 0 {
+			p.maxFeeBackoff = max
+		}
+	}
+}
+
+// WithClientAffinity makes worker i broadcast every send through pooled
+// client i modulo the pool size, rather than whichever client is next, so
+// each worker keeps reusing one connection
+func WithClientAffinity(enabled bool) Option {
+	return func(p *Processor) {
+		p.clientAffinity = enabled
+	}
+}
+
 // Processor handles high-throughput parallel processing
 type Processor struct {
 	manager   *transaction.Manager
@@ -26,6 +176,60 @@ type Processor struct {
 	closeOnce sync.Once
 	closed    bool
 	mu        sync.RWMutex
+
+	// submitting counts Submit calls in progress, so Close can wait for
+	// them before draining the queues; the queues are never closed
+	submitting sync.WaitGroup
+
+	queueFullPolicy QueueFullPolicy
+	blockTimeout    time.Duration
+	minCustomData   int
+	maxCustomData   int
+	dedupByContent  bool
+	dedupWindow     time.Duration
+	// maxBaseFee holds sends while the base fee is above it; nil disables
+	maxBaseFee    *big.Int
+	feeBackoff    time.Duration
+	maxFeeBackoff time.Duration
+
+	// seen maps request content hashes to when they were last submitted;
+	// seenOrder lists the same submissions oldest first, so expiry stops at
+	// the first one still inside the window
+	seen      map[common.Hash]time.Time
+	seenOrder []seenContent
+	dedupMu   sync.Mutex
+
+	// running is closed while the processor runs and replaced by an open
+	// channel while it is paused
+	running chan struct{}
+	paused  bool
+
+	// ready receives a value, without blocking the worker, each time a
+	// worker frees a queue slot
+	ready chan struct{}
+
+	// completions records when requests finished, for Throughput
+	completions completionRing
+
+	// partitions holds one queue per worker for requests with a
+	// PartitionKey, so each key is served by a single worker in order
+	partitions []chan *Request
+
+	// clientAffinity pins each worker to one pooled client
+	clientAffinity bool
+
+	// receipts holds results for WithBulkReceipts; nil when disabled
+	receipts     *receiptTracker
+	failOnRevert bool
+	// receiptTimeout is how long receipts holds a result before failing it
+	receiptTimeout time.Duration
+
+	// overflow keeps results published during shutdown that did not fit in
+	// the results channel, for GetResult and GetResults
+	overflow []*Result
+
+	// delayed counts requests held by WithMaxBaseFeeWei outside the queues
+	delayed atomic.Int64
 }
 
 type Request struct {
@@ -35,6 +239,17 @@ type Request struct {
 	CustomData []byte
 	Data       []byte
 	Timestamp  time.Time
+	// Metadata is carried unchanged to the request's Result for correlation
+	Metadata map[string]string
+	// PartitionKey, if set, routes the request to the worker that owns the
+	// key, so requests sharing a key are sent one at a time in submission
+	// order. Requests without a key go to whichever worker is free.
+	PartitionKey string
+
+	// feeWaitStart is when WithMaxBaseFeeWei first held the request and
+	// feeBackoff its current delay; zero while it is not held
+	feeWaitStart time.Time
+	feeBackoff   time.Duration
 }
 
 type Result struct {
@@ -42,17 +257,39 @@ type Result struct {
 	Transaction *types.Transaction
 	Error       error
 	Duration    time.Duration
+	// Duplicate is set when the request was dropped by WithDedupByContent
+	Duplicate bool
+	// Receipt and GasUsed are set for mined transactions when the processor
+	// uses WithBulkReceipts
+	Receipt *types.Receipt
+	GasUsed uint64
+}
+
+// Label returns the request's metadata value for key, or "" if unset
+func (r *Result) Label(key string) string {
+	if r.Request == nil {
+		return ""
+	}
+	return r.Request.Metadata[key]
 }
 
 type Metrics struct {
 	TotalQueued    uint64
 	TotalProcessed uint64
 	TotalFailed    uint64
+	TotalDropped   uint64
+	TotalDuplicate uint64
 	AvgDuration    time.Duration
-	mu             sync.RWMutex
+	// FeeWaits counts sends held by WithMaxBaseFeeWei and FeeWaitTime is the
+	// total time they were held
+	FeeWaits    uint64
+	FeeWaitTime time.Duration
+	// TotalCustomDataBytes is the custom data carried by processed requests
+	TotalCustomDataBytes uint64
+	mu                   sync.RWMutex
 }
 
-func NewProcessor(manager *transaction.Manager, workers int, queueSize int) *Processor {
+func NewProcessor(manager *transaction.Manager, workers int, queueSize int, opts ...Option) *Processor {
 	if workers < 1 {
 		workers = 1
 	}
@@ -71,6 +308,26 @@ func NewProcessor(manager *transaction.Manager, workers int, queueSize int) *Pro
 		cancel:  cancel,
 		metrics: &Metrics{},
 		closed:  false,
+
+		queueFullPolicy: Reject,
+		blockTimeout:    DefaultBlockTimeout,
+		dedupWindow:     DefaultDedupWindow,
+		feeBackoff:      DefaultFeeBackoff,
+		maxFeeBackoff:   DefaultMaxFeeBackoff,
+		seen:            make(map[common.Hash]time.Time),
+		running:         make(chan struct{}),
+		ready:           make(chan struct{}, 1),
+		receiptTimeout:  DefaultReceiptTimeout,
+	}
+	close(p.running)
+
+	p.partitions = make([]chan *Request, workers)
+	for i := range p.partitions {
+		p.partitions[i] = make(chan *Request, queueSize)
+	}
+
+	for _, opt := range opts {
+		opt(p)
 	}
 
 	for i := 0; i < workers; i++ {
@@ -78,33 +335,251 @@ func NewProcessor(manager *transaction.Manager, workers int, queueSize int) *Pro
 		go p.worker(i)
 	}
 
+	if p.receipts != nil {
+		p.wg.Add(1)
+		go p.trackReceipts()
+	}
+
 	return p
 }
 
 func (p *Processor) worker(id int) {
 	defer p.wg.Done()
 
+	// held is a PartitionKey request waiting out a high base fee; the
+	// worker's partition is not read until it is retried, keeping the key's
+	// order
+	var held *Request
+	var retry <-chan time.Time
+	defer func() {
+		if held != nil {
+			p.delayed.Add(-1)
+			p.publish(p.shutdownResult(held))
+		}
+	}()
+
 	for {
+		if !p.waitRunning() {
+			return
+		}
+
+		partition := p.partitions[id]
+		if held != nil {
+			partition = nil
+		}
+
+		var req *Request
 		select {
 		case <-p.ctx.Done():
 			return
-		case req, ok := <-p.queue:
-			if !ok {
+		case req = <-p.queue:
+		case req = <-partition:
+		case <-retry:
+			req, held, retry = held, nil, nil
+			p.delayed.Add(-1)
+		}
+
+		p.signalReady()
+		// Pause may have been called while waiting for the request or during
+		// the base fee check, which is then repeated after Resume
+		var allowed bool
+		for {
+			if !p.waitRunning() {
+				p.publish(p.shutdownResult(req))
 				return
 			}
-			p.processRequest(req)
+			allowed = p.baseFeeAllows()
+			if !p.Paused() {
+				break
+			}
+		}
+
+		if !allowed {
+			backoff := p.nextFeeBackoff(req)
+			p.delayed.Add(1)
+			if req.PartitionKey != "" {
+				held, retry = req, time.After(backoff)
+			} else {
+				p.requeueAfter(req, backoff)
+			}
+			continue
+		}
+		if !req.feeWaitStart.IsZero() {
+			p.metrics.AddFeeWait(time.Since(req.feeWaitStart))
+			req.feeWaitStart, req.feeBackoff = time.Time{}, 0
 		}
+
+		p.processRequest(id, req)
+	}
+}
+
+// waitRunning blocks while the processor is paused. It returns false if the
+// processor shuts down first.
+func (p *Processor) waitRunning() bool {
+	p.mu.RLock()
+	running := p.running
+	p.mu.RUnlock()
+
+	select {
+	case <-running:
+		return true
+	case <-p.ctx.Done():
+		return false
 	}
 }
 
-func (p *Processor) processRequest(req *Request) {
+// Pause stops workers from taking requests off the queue. Submit still
+// queues requests up to capacity. A worker that took a request just as
+// Pause was called holds it until Resume.
+func (p *Processor) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		return
+	}
+	p.paused = true
+	p.running = make(chan struct{})
+}
+
+// Resume lets workers take requests again after Pause
+func (p *Processor) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		return
+	}
+	p.paused = false
+	close(p.running)
+}
+
+// Paused reports whether the processor is paused
+func (p *Processor) Paused() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.paused
+}
+
+// Available returns the number of free queue slots. Requests waiting in
+// the worker partitions for PartitionKey requests, or held back by
+// WithMaxBaseFeeWei, count against the queue size too, so such a backlog
+// also holds producers back.
+func (p *Processor) Available() int {
+	return max(0, cap(p.queue)-p.queueLen())
+}
+
+// queueLen returns how many requests wait in the shared queue and the
+// worker partitions, or are held by WithMaxBaseFeeWei
+func (p *Processor) queueLen() int {
+	n := len(p.queue) + int(p.delayed.Load())
+	for _, partition := range p.partitions {
+		n += len(partition)
+	}
+	return n
+}
+
+// Ready returns a channel that fires once a worker frees a queue slot, as
+// counted by Available. It is already closed if a slot is free. Each freed
+// slot wakes one receiver, so producers sharing the processor should
+// retry Ready after a Submit fails; another producer may take the slot
+// first, so Submit can still return ErrQueueFull.
+func (p *Processor) Ready() <-chan struct{} {
+	// Discard a signal from a slot that has since been taken, then check
+	// for space: a slot freed after the discard either shows up in the
+	// check or signals again
+	select {
+	case <-p.ready:
+	default:
+	}
+
+	if p.Available() > 0 {
+		ch := make(chan struct{})
+		close(ch)
+		return ch
+	}
+	return p.ready
+}
+
+// signalReady wakes a producer waiting on Ready after a slot frees up. It
+// never blocks: a signal already pending covers this one.
+func (p *Processor) signalReady() {
+	select {
+	case p.ready <- struct{}{}:
+	default:
+	}
+}
+
+// baseFeeAllows reports whether the base fee is at most maxBaseFee, if set.
+// An unreachable node is waited out like a high fee.
+func (p *Processor) baseFeeAllows() bool {
+	if p.maxBaseFee == nil {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, 30*time.Second)
+	defer cancel()
+	baseFee, err := p.manager.BaseFee(ctx)
+	return err == nil && baseFee.Cmp(p.maxBaseFee) <= 0
+}
+
+// nextFeeBackoff returns how long req waits before its next base fee
+// check, doubling each time it is held up to maxFeeBackoff
+func (p *Processor) nextFeeBackoff(req *Request) time.Duration {
+	if req.feeWaitStart.IsZero() {
+		req.feeWaitStart = time.Now()
+		req.feeBackoff = p.feeBackoff
+	} else {
+		req.feeBackoff = min(2*req.feeBackoff, p.maxFeeBackoff)
+	}
+	return req.feeBackoff
+}
+
+// requeueAfter puts req back on the shared queue after delay. If the
+// processor shuts down first req is reported as cut short.
+func (p *Processor) requeueAfter(req *Request, delay time.Duration) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer p.delayed.Add(-1)
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			select {
+			case p.queue <- req:
+				return
+			case <-p.ctx.Done():
+			}
+		case <-p.ctx.Done():
+		}
+		p.publish(p.shutdownResult(req))
+	}()
+}
+
+// shutdownResult reports req as cut short by Close
+func (p *Processor) shutdownResult(req *Request) *Result {
+	return &Result{Request: req, Error: fmt.Errorf("%w: %w", ErrProcessorShutdown, p.ctx.Err())}
+}
+
+// processRequest sends req on behalf of worker id
+func (p *Processor) processRequest(id int, req *Request) {
 	startTime := time.Now()
 
 	ctx, cancel := context.WithTimeout(p.ctx, 30*time.Second)
 	defer cancel()
 
-	tx, err := p.manager.SendWithContext(ctx, req.To, req.Value, req.CustomData, req.Data)
+	var opts []transaction.SendOption
+	if p.clientAffinity {
+		opts = append(opts, transaction.WithClient(id))
+	}
+	tx, err := p.manager.SendWithContext(ctx, req.To, req.Value, req.CustomData, req.Data, opts...)
 	duration := time.Since(startTime)
+	if err != nil && p.ctx.Err() != nil {
+		err = fmt.Errorf("%w: %w", ErrProcessorShutdown, err)
+	}
 
 	result := &Result{
 		Request:     req,
@@ -113,13 +588,18 @@ func (p *Processor) processRequest(req *Request) {
 		Duration:    duration,
 	}
 
+	// Hold before counting the request as processed, so a processed
+	// request is always either held or published
+	held := p.receipts != nil && err == nil
+	if held {
+		p.receipts.hold(result)
+	}
+
 	p.metrics.Update(result)
+	p.completions.add(time.Now())
 
-	select {
-	case p.results <- result:
-	case <-p.ctx.Done():
-	default:
-		// Results channel full, log but don't block
+	if !held {
+		p.publish(result)
 	}
 }
 
@@ -127,51 +607,276 @@ func (p *Processor) Submit(req *Request) error {
 	p.mu.RLock()
 	if p.closed {
 		p.mu.RUnlock()
-		return fmt.Errorf("processor is closed")
+		return ErrProcessorShutdown
 	}
+	p.submitting.Add(1)
 	p.mu.RUnlock()
+	defer p.submitting.Done()
+
+	if err := p.checkCustomData(req); err != nil {
+		return err
+	}
 
 	if req.Value == nil {
 		req.Value = big.NewInt(0)
 	}
 
 	req.Timestamp = time.Now()
+
+	var content common.Hash
+	if p.dedupByContent {
+		var duplicate bool
+		if content, duplicate = p.claimContent(req); duplicate {
+			p.metrics.IncrementDuplicate()
+			p.publish(&Result{Request: req, Error: ErrDuplicate, Duplicate: true})
+			return nil
+		}
+	}
+
 	p.metrics.IncrementQueued()
 
+	err := p.enqueue(req)
+	if err != nil && p.dedupByContent {
+		// Not queued, so a retry must not count as a duplicate
+		p.releaseContent(content, req.Timestamp)
+	}
+	return err
+}
+
+// enqueue adds req to its queue, applying the queue-full policy
+func (p *Processor) enqueue(req *Request) error {
+	queue := p.queueFor(req)
 	select {
-	case p.queue <- req:
+	case queue <- req:
 		return nil
 	case <-p.ctx.Done():
-		return fmt.Errorf("processor is shutting down")
+		return ErrProcessorShutdown
 	default:
-		return fmt.Errorf("queue is full")
 	}
+
+	switch p.queueFullPolicy {
+	case Block:
+		return p.submitBlocking(queue, req)
+	case DropOldest:
+		return p.submitDropOldest(queue, req)
+	default:
+		return ErrQueueFull
+	}
+}
+
+// queueFor returns the queue req goes to: the partition of the worker that
+// owns its PartitionKey, or the shared queue. Keys are spread over workers
+// by FNV-1a hash; the worker count is fixed, so a key always maps to the
+// same worker.
+func (p *Processor) queueFor(req *Request) chan *Request {
+	if req.PartitionKey == "" {
+		return p.queue
+	}
+	h := fnv.New32a()
+	h.Write([]byte(req.PartitionKey))
+	return p.partitions[h.Sum32()%uint32(len(p.partitions))]
+}
+
+// PreviewNonces returns the nonce each of reqs would get if they were the
+// manager's next sends, in order, without sending or taking any nonce. It
+// is a debugging aid only: requests already queued, concurrent sends and
+// workers racing each other all change the actual assignment.
+func (p *Processor) PreviewNonces(reqs []*Request) ([]uint64, error) {
+	for i, req := range reqs {
+		if req == nil {
+			return nil, fmt.Errorf("request %d is nil", i)
+		}
+	}
+	return p.manager.PreviewNonces(len(reqs))
+}
+
+// seenContent is a submission remembered by WithDedupByContent
+type seenContent struct {
+	hash common.Hash
+	at   time.Time
+}
+
+// claimContent reports whether content matching req was submitted within
+// the dedup window, and remembers req's content otherwise. It returns the
+// content hash for releaseContent.
+func (p *Processor) claimContent(req *Request) (common.Hash, bool) {
+	key := contentHash(req)
+
+	p.dedupMu.Lock()
+	defer p.dedupMu.Unlock()
+
+	expired := 0
+	for _, entry := range p.seenOrder {
+		if req.Timestamp.Sub(entry.at) <= p.dedupWindow {
+			break
+		}
+		// A later submission of the same content may have replaced it
+		if p.seen[entry.hash] == entry.at {
+			delete(p.seen, entry.hash)
+		}
+		expired++
+	}
+	p.seenOrder = p.seenOrder[expired:]
+
+	if _, ok := p.seen[key]; ok {
+		return key, true
+	}
+	p.seen[key] = req.Timestamp
+	p.seenOrder = append(p.seenOrder, seenContent{hash: key, at: req.Timestamp})
+	return key, false
 }
 
+// releaseContent forgets the submission claimContent remembered at at, for
+// a request that was not queued
+func (p *Processor) releaseContent(key common.Hash, at time.Time) {
+	p.dedupMu.Lock()
+	defer p.dedupMu.Unlock()
+
+	if p.seen[key] == at {
+		delete(p.seen, key)
+	}
+}
+
+// contentHash identifies a request by recipient, value and custom data
+func contentHash(req *Request) common.Hash {
+	return crypto.Keccak256Hash(req.To.Bytes(), common.BigToHash(req.Value).Bytes(), req.CustomData)
+}
+
+// checkCustomData enforces the configured custom data bounds
+func (p *Processor) checkCustomData(req *Request) error {
+	size := len(req.CustomData)
+	if p.minCustomData > 0 && size < p.minCustomData {
+		return fmt.Errorf("%w: %d bytes, minimum is %d", ErrCustomDataSize, size, p.minCustomData)
+	}
+	if p.maxCustomData > 0 && size > p.maxCustomData {
+		return fmt.Errorf("%w: %d bytes, maximum is %d", ErrCustomDataSize, size, p.maxCustomData)
+	}
+	return nil
+}
+
+// submitBlocking waits up to the block timeout for space in queue
+func (p *Processor) submitBlocking(queue chan *Request, req *Request) error {
+	timer := time.NewTimer(p.blockTimeout)
+	defer timer.Stop()
+
+	select {
+	case queue <- req:
+		return nil
+	case <-p.ctx.Done():
+		return ErrProcessorShutdown
+	case <-timer.C:
+		return ErrQueueFull
+	}
+}
+
+// submitDropOldest evicts requests from queue until req fits
+func (p *Processor) submitDropOldest(queue chan *Request, req *Request) error {
+	for {
+		select {
+		case queue <- req:
+			return nil
+		case <-p.ctx.Done():
+			return ErrProcessorShutdown
+		default:
+		}
+
+		select {
+		case oldest := <-queue:
+			p.drop(oldest)
+		default:
+			// A worker took the oldest request first; retry
+		}
+	}
+}
+
+// drop reports an evicted request as a dropped Result
+func (p *Processor) drop(req *Request) {
+	p.metrics.IncrementDropped()
+
+	p.publish(&Result{
+		Request: req,
+		Error:   ErrDropped,
+	})
+}
+
+// publish reports a result without blocking. Once the processor is
+// shutting down, results that do not fit in the channel are kept in
+// overflow rather than lost.
+func (p *Processor) publish(result *Result) {
+	select {
+	case p.results <- result:
+	default:
+		if p.ctx.Err() != nil {
+			p.mu.Lock()
+			p.overflow = append(p.overflow, result)
+			p.mu.Unlock()
+			return
+		}
+		// Results channel full, log but don't block
+	}
+}
+
+// GetResult waits for the next result. Once the processor is closed it
+// returns the results still buffered, such as those failed with
+// ErrProcessorShutdown, and then nil.
 func (p *Processor) GetResult() *Result {
 	select {
-	case result := <-p.results:
-		return result
+	case result, ok := <-p.results:
+		if ok {
+			return result
+		}
+		return p.bufferedResult()
 	case <-p.ctx.Done():
+		return p.bufferedResult()
+	}
+}
+
+// bufferedResult returns a result already in the channel or in overflow
+// without waiting
+func (p *Processor) bufferedResult() *Result {
+	select {
+	case result, ok := <-p.results:
+		if ok {
+			return result
+		}
+	default:
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.overflow) == 0 {
 		return nil
 	}
+	result := p.overflow[0]
+	p.overflow = p.overflow[1:]
+	return result
 }
 
 func (p *Processor) GetResults(count int, timeout time.Duration) []*Result {
 	results := make([]*Result, 0, count)
 	deadline := time.After(timeout)
 
-	for i := 0; i < count; i++ {
+	for len(results) < count {
 		select {
-		case result := <-p.results:
-			if result != nil {
+		case result, ok := <-p.results:
+			if ok {
 				results = append(results, result)
+				continue
 			}
 		case <-deadline:
 			return results
 		case <-p.ctx.Done():
-			return results
 		}
+
+		// Closed: take what is buffered without waiting
+		for len(results) < count {
+			result := p.bufferedResult()
+			if result == nil {
+				break
+			}
+			results = append(results, result)
+		}
+		return results
 	}
 
 	return results
@@ -184,17 +889,37 @@ func (p *Processor) Close() error {
 		p.closed = true
 		p.mu.Unlock()
 
+		// Submit calls already past the closed check see the cancelled
+		// context and return; the queues stay open so none can panic
 		p.cancel()
-
-		close(p.queue)
+		p.submitting.Wait()
 
 		p.wg.Wait()
 
+		p.failRemaining()
 		close(p.results)
 	})
 	return err
 }
 
+// failRemaining reports every request still queued and every result still
+// waiting for its receipt as failed with ErrProcessorShutdown. It runs once
+// the workers and submitters have stopped.
+func (p *Processor) failRemaining() {
+	for _, queue := range append([]chan *Request{p.queue}, p.partitions...) {
+		for len(queue) > 0 {
+			p.publish(p.shutdownResult(<-queue))
+		}
+	}
+
+	if p.receipts != nil {
+		for _, result := range p.receipts.takeAll() {
+			result.Error = fmt.Errorf("%w: receipt not awaited: %w", ErrProcessorShutdown, p.ctx.Err())
+			p.publish(result)
+		}
+	}
+}
+
 func (p *Processor) IsClosed() bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
@@ -204,16 +929,26 @@ func (p *Processor) IsClosed() bool {
 func (p *Processor) GetMetrics() map[string]interface{} {
 	p.metrics.mu.RLock()
 	defer p.metrics.mu.RUnlock()
+	return p.metricsLocked()
+}
 
+// metricsLocked builds GetMetrics' map; the caller must hold p.metrics.mu
+func (p *Processor) metricsLocked() map[string]interface{} {
 	return map[string]interface{}{
-		"queued":       p.metrics.TotalQueued,
-		"processed":    p.metrics.TotalProcessed,
-		"failed":       p.metrics.TotalFailed,
-		"avg_duration": p.metrics.AvgDuration.Milliseconds(),
-		"success_rate": p.calculateSuccessRate(),
-		"workers":      p.workers,
-		"queue_size":   len(p.queue),
-		"results_size": len(p.results),
+		"queued":            p.metrics.TotalQueued,
+		"processed":         p.metrics.TotalProcessed,
+		"failed":            p.metrics.TotalFailed,
+		"dropped":           p.metrics.TotalDropped,
+		"duplicates":        p.metrics.TotalDuplicate,
+		"avg_duration":      p.metrics.AvgDuration.Milliseconds(),
+		"fee_waits":         p.metrics.FeeWaits,
+		"fee_wait_ms":       p.metrics.FeeWaitTime.Milliseconds(),
+		"custom_data_bytes": p.metrics.TotalCustomDataBytes,
+		"success_rate":      p.calculateSuccessRate(),
+		"workers":           p.workers,
+		"paused":            p.Paused(),
+		"queue_size":        p.queueLen(),
+		"results_size":      len(p.results),
 	}
 }
 
@@ -231,6 +966,31 @@ func (m *Metrics) IncrementQueued() {
 	m.TotalQueued++
 }
 
+func (m *Metrics) IncrementDuplicate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.TotalDuplicate++
+}
+
+func (m *Metrics) IncrementFailed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.TotalFailed++
+}
+
+func (m *Metrics) IncrementDropped() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.TotalDropped++
+}
+
+func (m *Metrics) AddFeeWait(wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.FeeWaits++
+	m.FeeWaitTime += wait
+}
+
 func (m *Metrics) Update(result *Result) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -239,6 +999,9 @@ func (m *Metrics) Update(result *Result) {
 	if result.Error != nil {
 		m.TotalFailed++
 	}
+	if result.Request != nil {
+		m.TotalCustomDataBytes += uint64(len(result.Request.CustomData))
+	}
 
 	if m.TotalProcessed == 1 {
 		m.AvgDuration = result.Duration
@@ -0,0 +1,1130 @@
+package batch_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/k4rz4/ethereum-custom-transactions/internal/ethtest"
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/batch"
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+var testRecipient = common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb")
+
+// newTestManager starts a mock node and a manager connected to it
+func newTestManager(t *testing.T) (*ethtest.Backend, *transaction.Manager) {
+	t.Helper()
+
+	backend := ethtest.NewBackend(t)
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+
+	mgr, err := transaction.NewManager(backend.URL, common.Bytes2Hex(crypto.FromECDSA(key)), 2)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+
+	return backend, mgr
+}
+
+func TestThroughput(t *testing.T) {
+	_, mgr := newTestManager(t)
+
+	p := batch.NewProcessor(mgr, 4, 50)
+	defer p.Close()
+
+	if rate := p.Throughput(time.Second); rate != 0 {
+		t.Errorf("idle throughput = %v, want 0", rate)
+	}
+
+	const requests = 20
+	start := time.Now()
+	for i := 0; i < requests; i++ {
+		if err := p.Submit(&batch.Request{To: testRecipient, CustomData: []byte("payload")}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+
+	results := p.GetResults(requests, 10*time.Second)
+	if len(results) != requests {
+		t.Fatalf("got %d results, want %d", len(results), requests)
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			t.Fatalf("request failed: %v", result.Error)
+		}
+	}
+
+	window := time.Since(start) + time.Second
+	rate := p.Throughput(window)
+	if rate <= 0 {
+		t.Fatalf("throughput = %v, want positive", rate)
+	}
+	if max := float64(requests) / window.Seconds(); rate > max {
+		t.Errorf("throughput = %v, want at most %v", rate, max)
+	}
+}
+
+// stallWorker makes the first sent transaction block in the node until the
+// returned release func is called, and waits for a worker to reach it
+func stallWorker(t *testing.T, backend *ethtest.Backend, p *batch.Processor) (release func()) {
+	t.Helper()
+
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	backend.OnCall("eth_sendRawTransaction", func(call int) {
+		if call == 1 {
+			close(entered)
+			<-unblock
+		}
+	})
+
+	if err := p.Submit(&batch.Request{ID: "stalled", To: testRecipient}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker never reached the node")
+	}
+
+	var once sync.Once
+	release = func() { once.Do(func() { close(unblock) }) }
+	t.Cleanup(release)
+	return release
+}
+
+func TestQueueFullReject(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 1, 1)
+	defer p.Close()
+
+	stallWorker(t, backend, p)
+
+	if err := p.Submit(&batch.Request{ID: "queued", To: testRecipient}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if err := p.Submit(&batch.Request{ID: "rejected", To: testRecipient}); !errors.Is(err, batch.ErrQueueFull) {
+		t.Errorf("Submit on a full queue error = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestQueueFullBlock(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 1, 1,
+		batch.WithQueueFullPolicy(batch.Block),
+		batch.WithBlockTimeout(50*time.Millisecond),
+	)
+	defer p.Close()
+
+	release := stallWorker(t, backend, p)
+
+	if err := p.Submit(&batch.Request{ID: "queued", To: testRecipient}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	start := time.Now()
+	if err := p.Submit(&batch.Request{ID: "timed-out", To: testRecipient}); !errors.Is(err, batch.ErrQueueFull) {
+		t.Errorf("Submit error = %v, want ErrQueueFull after the block timeout", err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("Submit returned after %v, want it to block for the timeout", waited)
+	}
+
+	// Space frees up while Submit is blocked
+	time.AfterFunc(10*time.Millisecond, release)
+	if err := p.Submit(&batch.Request{ID: "unblocked", To: testRecipient}); err != nil {
+		t.Errorf("Submit failed once space freed: %v", err)
+	}
+}
+
+func TestQueueFullDropOldest(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 1, 1, batch.WithQueueFullPolicy(batch.DropOldest))
+	defer p.Close()
+
+	release := stallWorker(t, backend, p)
+
+	if err := p.Submit(&batch.Request{ID: "oldest", To: testRecipient}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if err := p.Submit(&batch.Request{ID: "newest", To: testRecipient}); err != nil {
+		t.Fatalf("Submit with DropOldest failed: %v", err)
+	}
+
+	dropped := p.GetResult()
+	if dropped == nil || dropped.Request.ID != "oldest" || !errors.Is(dropped.Error, batch.ErrDropped) {
+		t.Fatalf("first result = %+v, want the oldest request dropped", dropped)
+	}
+	if got := p.GetMetrics()["dropped"]; got != uint64(1) {
+		t.Errorf("dropped metric = %v, want 1", got)
+	}
+
+	release()
+	processed := map[string]bool{}
+	for _, result := range p.GetResults(2, 5*time.Second) {
+		if result.Error != nil {
+			t.Errorf("request %s failed: %v", result.Request.ID, result.Error)
+		}
+		processed[result.Request.ID] = true
+	}
+	if !processed["stalled"] || !processed["newest"] || processed["oldest"] {
+		t.Errorf("processed %v, want stalled and newest only", processed)
+	}
+}
+
+func TestRecommendConfig(t *testing.T) {
+	tests := []struct {
+		tps       float64
+		latency   time.Duration
+		workers   int
+		queueSize int
+	}{
+		{50, 100 * time.Millisecond, 8, 100}, // 5 in flight * 1.5
+		{10, 2 * time.Second, 30, 60},        // 20 in flight * 1.5
+		{1, 10 * time.Millisecond, 1, 2},     // a single worker suffices
+		{0, 100 * time.Millisecond, 1, 100},  // no target: defaults
+	}
+
+	for _, tt := range tests {
+		workers, queueSize := batch.RecommendConfig(tt.tps, tt.latency)
+		if workers != tt.workers || queueSize != tt.queueSize {
+			t.Errorf("RecommendConfig(%v, %v) = %d, %d; want %d, %d",
+				tt.tps, tt.latency, workers, queueSize, tt.workers, tt.queueSize)
+		}
+		if float64(workers) < tt.tps*tt.latency.Seconds() {
+			t.Errorf("RecommendConfig(%v, %v): %d workers cannot sustain the target", tt.tps, tt.latency, workers)
+		}
+	}
+}
+
+func TestCustomDataBounds(t *testing.T) {
+	_, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 1, 10,
+		batch.WithMinCustomDataBytes(4),
+		batch.WithMaxCustomDataBytes(8),
+	)
+	defer p.Close()
+
+	tests := []struct {
+		name       string
+		customData []byte
+		wantErr    bool
+	}{
+		{"under min", []byte("abc"), true},
+		{"at min", []byte("abcd"), false},
+		{"in range", []byte("abcdef"), false},
+		{"at max", []byte("abcdefgh"), false},
+		{"over max", []byte("abcdefghi"), true},
+	}
+
+	accepted := 0
+	for _, tt := range tests {
+		err := p.Submit(&batch.Request{ID: tt.name, To: testRecipient, CustomData: tt.customData})
+		if tt.wantErr && !errors.Is(err, batch.ErrCustomDataSize) {
+			t.Errorf("%s: Submit error = %v, want ErrCustomDataSize", tt.name, err)
+		}
+		if !tt.wantErr {
+			if err != nil {
+				t.Errorf("%s: Submit failed: %v", tt.name, err)
+			}
+			accepted++
+		}
+	}
+
+	if queued := p.GetMetrics()["queued"]; queued != uint64(accepted) {
+		t.Errorf("queued = %v, want %d; rejected requests must not be queued", queued, accepted)
+	}
+	if results := p.GetResults(accepted, 5*time.Second); len(results) != accepted {
+		t.Errorf("got %d results, want %d", len(results), accepted)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	_, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 2, 10)
+	defer p.Close()
+
+	p.Pause()
+	if !p.Paused() || p.GetMetrics()["paused"] != true {
+		t.Fatal("processor not reported as paused")
+	}
+
+	const requests = 3
+	for i := 0; i < requests; i++ {
+		if err := p.Submit(&batch.Request{To: testRecipient, CustomData: []byte{byte(i)}}); err != nil {
+			t.Fatalf("Submit while paused failed: %v", err)
+		}
+	}
+
+	if results := p.GetResults(1, 100*time.Millisecond); len(results) != 0 {
+		t.Fatalf("%d requests processed while paused", len(results))
+	}
+	if processed := p.GetMetrics()["processed"]; processed != uint64(0) {
+		t.Errorf("processed = %v while paused, want 0", processed)
+	}
+
+	p.Resume()
+	if p.Paused() {
+		t.Error("processor still reported as paused after Resume")
+	}
+
+	results := p.GetResults(requests, 5*time.Second)
+	if len(results) != requests {
+		t.Fatalf("got %d results after Resume, want %d", len(results), requests)
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			t.Errorf("request failed: %v", result.Error)
+		}
+	}
+}
+
+func TestDedupByContent(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 1, 10, batch.WithDedupByContent(true))
+	defer p.Close()
+
+	submit := func(id string, value int64, customData string) {
+		t.Helper()
+		req := &batch.Request{ID: id, To: testRecipient, Value: big.NewInt(value), CustomData: []byte(customData)}
+		if err := p.Submit(req); err != nil {
+			t.Fatalf("Submit(%s) failed: %v", id, err)
+		}
+	}
+
+	submit("first", 0, "payload")
+	submit("repeat", 0, "payload")
+	submit("repeat-again", 0, "payload")
+	submit("other-value", 1, "payload")
+
+	sent, duplicates := map[string]bool{}, map[string]bool{}
+	for _, result := range p.GetResults(4, 5*time.Second) {
+		switch {
+		case result.Duplicate:
+			if !errors.Is(result.Error, batch.ErrDuplicate) {
+				t.Errorf("duplicate %s has error %v, want ErrDuplicate", result.Request.ID, result.Error)
+			}
+			duplicates[result.Request.ID] = true
+		case result.Error != nil:
+			t.Errorf("request %s failed: %v", result.Request.ID, result.Error)
+		default:
+			sent[result.Request.ID] = true
+		}
+	}
+
+	if !sent["first"] || !sent["other-value"] || len(sent) != 2 {
+		t.Errorf("sent %v, want first and other-value", sent)
+	}
+	if !duplicates["repeat"] || !duplicates["repeat-again"] || len(duplicates) != 2 {
+		t.Errorf("duplicates %v, want repeat and repeat-again", duplicates)
+	}
+	if pending := backend.Pending(); len(pending) != 2 {
+		t.Errorf("node received %d transactions, want 2", len(pending))
+	}
+}
+
+func TestDedupWindowExpires(t *testing.T) {
+	_, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 1, 10,
+		batch.WithDedupByContent(true),
+		batch.WithDedupWindow(20*time.Millisecond),
+	)
+	defer p.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := p.Submit(&batch.Request{To: testRecipient, CustomData: []byte("payload")}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+		time.Sleep(40 * time.Millisecond)
+	}
+
+	results := p.GetResults(2, 5*time.Second)
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for _, result := range results {
+		if result.Duplicate || result.Error != nil {
+			t.Errorf("resubmission after the window: duplicate=%v error=%v", result.Duplicate, result.Error)
+		}
+	}
+}
+
+func TestDedupRetryAfterQueueFull(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 1, 1, batch.WithDedupByContent(true))
+	defer p.Close()
+
+	release := stallWorker(t, backend, p)
+
+	if err := p.Submit(&batch.Request{ID: "queued", To: testRecipient, CustomData: []byte("queued")}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	retried := func() *batch.Request {
+		return &batch.Request{ID: "retried", To: testRecipient, CustomData: []byte("retried")}
+	}
+	if err := p.Submit(retried()); !errors.Is(err, batch.ErrQueueFull) {
+		t.Fatalf("Submit on a full queue error = %v, want ErrQueueFull", err)
+	}
+
+	release()
+	if results := p.GetResults(2, 5*time.Second); len(results) != 2 {
+		t.Fatalf("got %d results before the retry, want 2", len(results))
+	}
+
+	// The rejected request was never queued, so its retry is not a duplicate
+	if err := p.Submit(retried()); err != nil {
+		t.Fatalf("retry Submit failed: %v", err)
+	}
+	results := p.GetResults(1, 5*time.Second)
+	if len(results) != 1 {
+		t.Fatalf("got %d results for the retry, want 1", len(results))
+	}
+	if result := results[0]; result.Request.ID != "retried" || result.Duplicate || result.Error != nil {
+		t.Errorf("retry result %s: duplicate=%v error=%v, want it sent", result.Request.ID, result.Duplicate, result.Error)
+	}
+}
+
+func TestRequestMetadata(t *testing.T) {
+	_, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 1, 10)
+	defer p.Close()
+
+	req := &batch.Request{
+		ID:         "tagged",
+		To:         testRecipient,
+		CustomData: []byte("payload"),
+		Metadata:   map[string]string{"order": "42", "tenant": "acme"},
+	}
+	if err := p.Submit(req); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	results := p.GetResults(1, 5*time.Second)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	result := results[0]
+	if result.Error != nil {
+		t.Fatalf("request failed: %v", result.Error)
+	}
+	if got := result.Request.Metadata; len(got) != 2 || got["order"] != "42" || got["tenant"] != "acme" {
+		t.Errorf("Metadata = %v, want order and tenant", got)
+	}
+	if got := result.Label("tenant"); got != "acme" {
+		t.Errorf("Label(tenant) = %q, want acme", got)
+	}
+	if got := result.Label("missing"); got != "" {
+		t.Errorf("Label(missing) = %q, want empty", got)
+	}
+}
+
+func TestReady(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 1, 2)
+	defer p.Close()
+
+	select {
+	case <-p.Ready():
+	default:
+		t.Fatal("Ready should fire immediately while the queue has space")
+	}
+
+	release := stallWorker(t, backend, p)
+
+	for _, id := range []string{"first", "second"} {
+		if err := p.Submit(&batch.Request{ID: id, To: testRecipient}); err != nil {
+			t.Fatalf("Submit(%s) failed: %v", id, err)
+		}
+	}
+	if got := p.Available(); got != 0 {
+		t.Fatalf("Available() = %d on a full queue, want 0", got)
+	}
+
+	ready := p.Ready()
+	select {
+	case <-ready:
+		t.Fatal("Ready fired while the queue is full")
+	default:
+	}
+
+	release()
+	if result := p.GetResult(); result == nil || result.Request.ID != "stalled" {
+		t.Fatalf("first result = %+v, want the stalled request", result)
+	}
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Ready did not fire after a slot freed up")
+	}
+	if got := p.Available(); got < 1 {
+		t.Errorf("Available() = %d after Ready fired, want at least 1", got)
+	}
+}
+
+func TestReadyCountsPartitions(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 1, 2)
+	defer p.Close()
+
+	release := stallWorker(t, backend, p)
+
+	// Keyed requests fill the worker's partition, not the shared queue
+	for _, id := range []string{"first", "second"} {
+		if err := p.Submit(&batch.Request{ID: id, To: testRecipient, PartitionKey: "key"}); err != nil {
+			t.Fatalf("Submit(%s) failed: %v", id, err)
+		}
+	}
+	if got := p.Available(); got != 0 {
+		t.Fatalf("Available() = %d with the partition backlog at the queue size, want 0", got)
+	}
+
+	ready := p.Ready()
+	select {
+	case <-ready:
+		t.Fatal("Ready fired while the partition backlog fills the queue")
+	default:
+	}
+
+	release()
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Ready did not fire after a partitioned request was taken")
+	}
+	if got := p.Available(); got < 1 {
+		t.Errorf("Available() = %d after Ready fired, want at least 1", got)
+	}
+}
+
+func TestBulkReceipts(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 3, 10, batch.WithBulkReceipts(10*time.Millisecond))
+	defer p.Close()
+
+	const requests = 3
+	for i := 0; i < requests; i++ {
+		if err := p.Submit(&batch.Request{To: testRecipient, CustomData: []byte("payload")}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(backend.Pending()) < requests {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d transactions reached the node", len(backend.Pending()), requests)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if result := p.GetResults(1, 100*time.Millisecond); len(result) != 0 {
+		t.Fatalf("result %+v published before its transaction was mined", result[0])
+	}
+
+	before := backend.Calls("eth_getBlockReceipts")
+	backend.Mine()
+
+	results := p.GetResults(requests, 5*time.Second)
+	if len(results) != requests {
+		t.Fatalf("got %d results, want %d", len(results), requests)
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			t.Fatalf("request failed: %v", result.Error)
+		}
+		if result.Receipt == nil || result.Receipt.TxHash != result.Transaction.Hash() {
+			t.Errorf("result receipt = %+v, want the receipt of %s", result.Receipt, result.Transaction.Hash())
+		}
+		if result.GasUsed == 0 || result.GasUsed != result.Receipt.GasUsed {
+			t.Errorf("GasUsed = %d, want the receipt's gas", result.GasUsed)
+		}
+	}
+
+	if calls := backend.Calls("eth_getBlockReceipts") - before; calls != 1 {
+		t.Errorf("eth_getBlockReceipts called %d times for the mined block, want 1", calls)
+	}
+	if calls := backend.Calls("eth_getTransactionReceipt"); calls != 0 {
+		t.Errorf("eth_getTransactionReceipt called %d times, want 0", calls)
+	}
+}
+
+func TestBulkReceiptsTimeout(t *testing.T) {
+	_, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 1, 10,
+		batch.WithBulkReceipts(10*time.Millisecond),
+		batch.WithReceiptTimeout(100*time.Millisecond))
+	defer p.Close()
+
+	// The transaction is never mined
+	if err := p.Submit(&batch.Request{ID: "stuck", To: testRecipient, CustomData: []byte("payload")}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	results := p.GetResults(1, 5*time.Second)
+	if len(results) != 1 {
+		t.Fatal("held result was never published")
+	}
+	result := results[0]
+	if !errors.Is(result.Error, transaction.ErrReceiptTimeout) {
+		t.Errorf("error = %v, want ErrReceiptTimeout", result.Error)
+	}
+	if result.Transaction == nil || result.Receipt != nil {
+		t.Errorf("result has transaction %v and receipt %v, want only the transaction", result.Transaction, result.Receipt)
+	}
+	if failed := p.GetMetrics()["failed"].(uint64); failed != 1 {
+		t.Errorf("failed = %d, want the timed out request counted", failed)
+	}
+}
+
+func TestMaxBaseFee(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	backend.SetBaseFee(big.NewInt(100e9))
+	backend.Mine()
+
+	p := batch.NewProcessor(mgr, 1, 10,
+		batch.WithMaxBaseFeeWei(big.NewInt(50e9)),
+		batch.WithFeeBackoff(10*time.Millisecond, 20*time.Millisecond))
+	defer p.Close()
+
+	if err := p.Submit(&batch.Request{To: testRecipient, CustomData: []byte("cheap")}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if calls := backend.Calls("eth_sendRawTransaction"); calls != 0 {
+		t.Fatalf("sent %d transactions while the base fee was high, want 0", calls)
+	}
+
+	backend.SetBaseFee(big.NewInt(10e9))
+	backend.Mine()
+
+	results := p.GetResults(1, 5*time.Second)
+	if len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("results = %+v, want one successful send", results)
+	}
+	if calls := backend.Calls("eth_sendRawTransaction"); calls != 1 {
+		t.Errorf("sent %d transactions, want 1", calls)
+	}
+
+	metrics := p.GetMetrics()
+	if metrics["fee_waits"] != uint64(1) {
+		t.Errorf("fee_waits = %v, want 1", metrics["fee_waits"])
+	}
+	if wait := metrics["fee_wait_ms"].(int64); wait < 100 {
+		t.Errorf("fee_wait_ms = %d, want at least 100", wait)
+	}
+}
+
+func TestMaxBaseFeeRequeues(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	backend.SetBaseFee(big.NewInt(100e9))
+	backend.Mine()
+
+	p := batch.NewProcessor(mgr, 1, 10,
+		batch.WithMaxBaseFeeWei(big.NewInt(50e9)),
+		batch.WithFeeBackoff(10*time.Millisecond, 20*time.Millisecond))
+	defer p.Close()
+
+	for _, req := range []*batch.Request{
+		{ID: "keyed-1", PartitionKey: "key"},
+		{ID: "keyed-2", PartitionKey: "key"},
+		{ID: "unkeyed"},
+	} {
+		req.To, req.CustomData = testRecipient, []byte(req.ID)
+		if err := p.Submit(req); err != nil {
+			t.Fatalf("Submit(%s) failed: %v", req.ID, err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if calls := backend.Calls("eth_sendRawTransaction"); calls != 0 {
+		t.Fatalf("sent %d transactions while the base fee was high, want 0", calls)
+	}
+	// A worker may be checking the base fee with one request in hand
+	deadline := time.Now().Add(time.Second)
+	for p.GetMetrics()["queue_size"] != 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := p.GetMetrics()["queue_size"]; got != 3 {
+		t.Errorf("queue_size = %v with every request held, want 3", got)
+	}
+
+	// Held requests go back to the queue rather than waiting in a worker,
+	// so a paused processor does not send them when the fee drops
+	p.Pause()
+	backend.SetBaseFee(big.NewInt(10e9))
+	backend.Mine()
+	time.Sleep(100 * time.Millisecond)
+	if calls := backend.Calls("eth_sendRawTransaction"); calls != 0 {
+		t.Fatalf("sent %d transactions while paused, want 0", calls)
+	}
+	p.Resume()
+
+	results := p.GetResults(3, 5*time.Second)
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	nonces := make(map[string]uint64)
+	for _, result := range results {
+		if result.Error != nil {
+			t.Fatalf("%s failed: %v", result.Request.ID, result.Error)
+		}
+		nonces[result.Request.ID] = result.Transaction.Nonce()
+	}
+	if nonces["keyed-1"] > nonces["keyed-2"] {
+		t.Errorf("keyed requests sent out of order: nonces %d and %d", nonces["keyed-1"], nonces["keyed-2"])
+	}
+	// keyed-2 may have waited in its partition behind keyed-1 unchecked
+	if waits := p.GetMetrics()["fee_waits"].(uint64); waits < 2 {
+		t.Errorf("fee_waits = %d, want at least 2", waits)
+	}
+}
+
+func TestFailOnRevert(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 2, 10,
+		batch.WithBulkReceipts(10*time.Millisecond),
+		batch.WithFailOnRevert(true))
+	defer p.Close()
+
+	for _, id := range []string{"ok", "reverts"} {
+		if err := p.Submit(&batch.Request{ID: id, To: testRecipient, CustomData: []byte(id)}); err != nil {
+			t.Fatalf("Submit(%s) failed: %v", id, err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(backend.Pending()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("transactions never reached the node")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	var reverting common.Hash
+	for _, tx := range backend.Pending() {
+		if custom, _ := transaction.GetCustomData(tx); string(custom) == "reverts" {
+			reverting = tx.Hash()
+		}
+	}
+	backend.OnCall("eth_getBlockReceipts", func(int) {
+		backend.SetReceiptStatus(reverting, types.ReceiptStatusFailed)
+	})
+	backend.Mine()
+
+	results := p.GetResults(2, 5*time.Second)
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for _, result := range results {
+		reverted := result.Request.ID == "reverts"
+		if got := errors.Is(result.Error, transaction.ErrReverted); got != reverted {
+			t.Errorf("%s: error = %v, want ErrReverted %v", result.Request.ID, result.Error, reverted)
+		}
+	}
+
+	metrics := p.GetMetrics()
+	if metrics["processed"] != uint64(2) || metrics["failed"] != uint64(1) {
+		t.Errorf("processed = %v, failed = %v; want 2 and 1", metrics["processed"], metrics["failed"])
+	}
+}
+
+func TestCustomDataBytesMetric(t *testing.T) {
+	_, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 2, 10)
+	defer p.Close()
+
+	sizes := []int{1, 10, 100, 1000}
+	var want uint64
+	for i, size := range sizes {
+		req := &batch.Request{ID: fmt.Sprint(i), To: testRecipient, CustomData: bytes.Repeat([]byte{byte(i + 1)}, size)}
+		if err := p.Submit(req); err != nil {
+			t.Fatalf("Submit(%d bytes) failed: %v", size, err)
+		}
+		want += uint64(size)
+	}
+
+	if results := p.GetResults(len(sizes), 5*time.Second); len(results) != len(sizes) {
+		t.Fatalf("got %d results, want %d", len(results), len(sizes))
+	}
+	if got := p.GetMetrics()["custom_data_bytes"]; got != want {
+		t.Errorf("custom_data_bytes = %v, want %d", got, want)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	_, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	reqs := []*batch.Request{
+		{ID: "empty", To: testRecipient},
+		{ID: "small", To: testRecipient, CustomData: []byte("payload")},
+		{ID: "large", To: testRecipient, CustomData: bytes.Repeat([]byte{0xab}, 64*1024)},
+	}
+
+	// Each request alone must cost what sending it actually reserves
+	want := new(big.Int)
+	var costs []*big.Int
+	for _, req := range reqs {
+		cost, err := batch.EstimateCost(ctx, mgr, []*batch.Request{req})
+		if err != nil {
+			t.Fatalf("EstimateCost(%s) failed: %v", req.ID, err)
+		}
+		tx, err := mgr.SendWithContext(ctx, req.To, req.Value, req.CustomData, req.Data)
+		if err != nil {
+			t.Fatalf("SendWithContext(%s) failed: %v", req.ID, err)
+		}
+		if reserved := new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(tx.Gas())); cost.Cmp(reserved) != 0 {
+			t.Errorf("%s: estimate = %v, want gas limit * fee cap = %v", req.ID, cost, reserved)
+		}
+		costs = append(costs, cost)
+		want.Add(want, cost)
+	}
+	if costs[2].Cmp(costs[1]) <= 0 {
+		t.Errorf("64 KiB payload estimate %v is not above the small payload's %v", costs[2], costs[1])
+	}
+
+	total, err := batch.EstimateCost(ctx, mgr, reqs)
+	if err != nil {
+		t.Fatalf("EstimateCost failed: %v", err)
+	}
+	if total.Cmp(want) != 0 {
+		t.Errorf("total = %v, want the sum of the requests %v", total, want)
+	}
+}
+
+func TestPreviewNonces(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	backend.SetNonce(mgr.Address(), 7)
+	p := batch.NewProcessor(mgr, 1, 10)
+	defer p.Close()
+
+	reqs := []*batch.Request{
+		{ID: "a", To: testRecipient, CustomData: []byte("a")},
+		{ID: "b", To: testRecipient, CustomData: []byte("b")},
+		{ID: "c", To: testRecipient, CustomData: []byte("c")},
+	}
+
+	want := []uint64{7, 8, 9}
+	for i := 0; i < 2; i++ {
+		got, err := p.PreviewNonces(reqs)
+		if err != nil {
+			t.Fatalf("PreviewNonces failed: %v", err)
+		}
+		// Previewing again gives the same nonces since none were taken
+		if !slices.Equal(got, want) {
+			t.Fatalf("preview %d = %v, want %v", i, got, want)
+		}
+	}
+
+	for _, req := range reqs {
+		if err := p.Submit(req); err != nil {
+			t.Fatalf("Submit(%s) failed: %v", req.ID, err)
+		}
+	}
+	results := p.GetResults(len(reqs), 5*time.Second)
+	if len(results) != len(reqs) {
+		t.Fatalf("got %d results, want %d", len(results), len(reqs))
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			t.Fatalf("%s failed: %v", result.Request.ID, result.Error)
+		}
+		if i := slices.Index(reqs, result.Request); result.Transaction.Nonce() != want[i] {
+			t.Errorf("%s sent with nonce %d, previewed %d", result.Request.ID, result.Transaction.Nonce(), want[i])
+		}
+	}
+}
+
+func TestSystemSnapshot(t *testing.T) {
+	_, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 2, 10)
+	defer p.Close()
+	system := batch.NewSystem(mgr, p)
+
+	for i := 0; i < 3; i++ {
+		if err := p.Submit(&batch.Request{ID: fmt.Sprint(i), To: testRecipient, CustomData: []byte{byte(i)}}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+	if results := p.GetResults(3, 5*time.Second); len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	snapshot := system.Snapshot()
+	if snapshot.Time.IsZero() {
+		t.Error("snapshot has no time")
+	}
+	if sent := snapshot.Manager["tx_sent"]; sent != 3 {
+		t.Errorf("manager tx_sent = %d, want 3 (manager metrics %v)", sent, snapshot.Manager)
+	}
+	if processed := snapshot.Processor["processed"]; processed != uint64(3) {
+		t.Errorf("processor processed = %v, want 3", processed)
+	}
+}
+
+func TestPartitionKeyOrdering(t *testing.T) {
+	_, mgr := newTestManager(t)
+	p := batch.NewProcessor(mgr, 4, 50)
+	defer p.Close()
+
+	keys := []string{"alice", "bob", "carol"}
+	const perKey = 6
+	for i := 0; i < perKey; i++ {
+		for _, key := range keys {
+			req := &batch.Request{
+				ID:           fmt.Sprintf("%s-%d", key, i),
+				To:           testRecipient,
+				CustomData:   []byte(fmt.Sprintf("%s-%d", key, i)),
+				PartitionKey: key,
+			}
+			if err := p.Submit(req); err != nil {
+				t.Fatalf("Submit(%s) failed: %v", req.ID, err)
+			}
+		}
+	}
+
+	results := p.GetResults(len(keys)*perKey, 10*time.Second)
+	if len(results) != len(keys)*perKey {
+		t.Fatalf("got %d results, want %d", len(results), len(keys)*perKey)
+	}
+
+	next := make(map[string]int)
+	lastNonce := make(map[string]uint64)
+	for _, result := range results {
+		if result.Error != nil {
+			t.Fatalf("%s failed: %v", result.Request.ID, result.Error)
+		}
+		key := result.Request.PartitionKey
+		if want := fmt.Sprintf("%s-%d", key, next[key]); result.Request.ID != want {
+			t.Fatalf("result %s for key %s arrived before %s", result.Request.ID, key, want)
+		}
+		nonce := result.Transaction.Nonce()
+		if next[key] > 0 && nonce <= lastNonce[key] {
+			t.Errorf("%s sent with nonce %d after nonce %d", result.Request.ID, nonce, lastNonce[key])
+		}
+		next[key]++
+		lastNonce[key] = nonce
+	}
+}
+
+// clientRecorder is a Sponsor that records which pooled client broadcast
+// each transaction
+type clientRecorder struct {
+	mu      sync.Mutex
+	clients map[common.Hash]*ethclient.Client
+}
+
+func (r *clientRecorder) Broadcast(ctx context.Context, tx *types.Transaction, client *ethclient.Client) error {
+	r.mu.Lock()
+	r.clients[tx.Hash()] = client
+	r.mu.Unlock()
+	return client.SendTransaction(ctx, tx)
+}
+
+func TestClientAffinity(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	key, _ := crypto.GenerateKey()
+	recorder := &clientRecorder{clients: make(map[common.Hash]*ethclient.Client)}
+	mgr, err := transaction.NewManager(backend.URL, common.Bytes2Hex(crypto.FromECDSA(key)), 3,
+		transaction.WithSponsor(recorder))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	defer mgr.Close()
+
+	p := batch.NewProcessor(mgr, 3, 50, batch.WithClientAffinity(true))
+	defer p.Close()
+
+	// A partition key pins its requests to one worker
+	keys := []string{"alice", "bob", "carol", "dave"}
+	const perKey = 5
+	for i := 0; i < perKey; i++ {
+		for _, key := range keys {
+			req := &batch.Request{
+				ID:           fmt.Sprintf("%s-%d", key, i),
+				To:           testRecipient,
+				CustomData:   []byte(fmt.Sprintf("%s-%d", key, i)),
+				PartitionKey: key,
+			}
+			if err := p.Submit(req); err != nil {
+				t.Fatalf("Submit(%s) failed: %v", req.ID, err)
+			}
+		}
+	}
+
+	results := p.GetResults(len(keys)*perKey, 10*time.Second)
+	if len(results) != len(keys)*perKey {
+		t.Fatalf("got %d results, want %d", len(results), len(keys)*perKey)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	byKey := make(map[string]*ethclient.Client)
+	for _, result := range results {
+		if result.Error != nil {
+			t.Fatalf("%s failed: %v", result.Request.ID, result.Error)
+		}
+		client := recorder.clients[result.Transaction.Hash()]
+		if client == nil {
+			t.Fatalf("%s was not broadcast through the sponsor", result.Request.ID)
+		}
+		key := result.Request.PartitionKey
+		if first, ok := byKey[key]; ok && first != client {
+			t.Errorf("%s went through a different client than earlier %s requests", result.Request.ID, key)
+		}
+		byKey[key] = client
+	}
+}
+
+func TestCloseReportsShutdown(t *testing.T) {
+	backend, mgr := newTestManager(t)
+
+	// Hold the send at the node until the test ends
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+	backend.OnCall("eth_sendRawTransaction", func(int) {
+		started <- struct{}{}
+		<-release
+	})
+
+	p := batch.NewProcessor(mgr, 1, 10)
+	if err := p.Submit(&batch.Request{ID: "in-flight", To: testRecipient, CustomData: []byte("cut short")}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was never sent")
+	}
+	p.Close()
+
+	results := p.GetResults(1, time.Second)
+	if len(results) != 1 {
+		t.Fatalf("got %d results after Close, want the in-flight request's", len(results))
+	}
+	err := results[0].Error
+	if !errors.Is(err, batch.ErrProcessorShutdown) || !errors.Is(err, context.Canceled) {
+		t.Errorf("in-flight result error = %v, want ErrProcessorShutdown wrapping context.Canceled", err)
+	}
+}
+
+func TestSubmitDuringClose(t *testing.T) {
+	for _, policy := range []batch.QueueFullPolicy{batch.Reject, batch.Block, batch.DropOldest} {
+		t.Run(policy.String(), func(t *testing.T) {
+			_, mgr := newTestManager(t)
+
+			// Paused workers leave the queue full, so every policy's
+			// full-queue path races Close
+			p := batch.NewProcessor(mgr, 2, 1, batch.WithQueueFullPolicy(policy), batch.WithBlockTimeout(time.Second))
+			p.Pause()
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 100)
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					for j := 0; ; j++ {
+						req := &batch.Request{To: testRecipient, CustomData: []byte(fmt.Sprintf("%d-%d", i, j))}
+						if j%2 == 0 {
+							req.PartitionKey = "key"
+						}
+						err := p.Submit(req)
+						if errors.Is(err, batch.ErrProcessorShutdown) {
+							return
+						}
+						if err != nil && !errors.Is(err, batch.ErrQueueFull) {
+							errs <- err
+							return
+						}
+					}
+				}(i)
+			}
+
+			time.Sleep(20 * time.Millisecond)
+			p.Close()
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Errorf("Submit during Close returned %v", err)
+			}
+
+			if err := p.Submit(&batch.Request{To: testRecipient}); !errors.Is(err, batch.ErrProcessorShutdown) {
+				t.Errorf("Submit after Close returned %v, want ErrProcessorShutdown", err)
+			}
+		})
+	}
+}
+
+func TestCloseFailsQueuedRequests(t *testing.T) {
+	backend, mgr := newTestManager(t)
+
+	// One result waits for a receipt that never comes; the queued requests
+	// are more than the results channel holds
+	p := batch.NewProcessor(mgr, 1, 2, batch.WithBulkReceipts(10*time.Millisecond))
+	if err := p.Submit(&batch.Request{ID: "unmined", To: testRecipient, CustomData: []byte("unmined")}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for p.GetMetrics()["processed"].(uint64) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("request was never sent")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(backend.Pending()) != 1 {
+		t.Fatalf("node has %d pending transactions, want 1", len(backend.Pending()))
+	}
+
+	p.Pause()
+	want := map[string]bool{"unmined": true}
+	for i, key := range []string{"", "", "a", "a"} {
+		req := &batch.Request{
+			ID:           fmt.Sprintf("queued-%d", i),
+			To:           testRecipient,
+			CustomData:   []byte(fmt.Sprintf("queued-%d", i)),
+			PartitionKey: key,
+		}
+		if err := p.Submit(req); err != nil {
+			t.Fatalf("Submit(%s) failed: %v", req.ID, err)
+		}
+		want[req.ID] = true
+	}
+	p.Close()
+
+	results := p.GetResults(len(want)+1, time.Second)
+	if len(results) != len(want) {
+		t.Fatalf("got %d results after Close, want %d", len(results), len(want))
+	}
+	for _, result := range results {
+		if !want[result.Request.ID] {
+			t.Errorf("unexpected or repeated result for %s", result.Request.ID)
+		}
+		delete(want, result.Request.ID)
+		if !errors.Is(result.Error, batch.ErrProcessorShutdown) || !errors.Is(result.Error, context.Canceled) {
+			t.Errorf("%s: error = %v, want ErrProcessorShutdown wrapping context.Canceled", result.Request.ID, result.Error)
+		}
+		if (result.Request.ID == "unmined") != (result.Transaction != nil) {
+			t.Errorf("%s: transaction = %v, want one only for the sent request", result.Request.ID, result.Transaction)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package batch
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+const (
+	// DefaultReceiptPollInterval is how often WithBulkReceipts checks for
+	// new blocks
+	DefaultReceiptPollInterval = time.Second
+	// DefaultReceiptTimeout is how long WithBulkReceipts holds a result
+	// for its receipt
+	DefaultReceiptTimeout = 10 * time.Minute
+)
+
+// WithBulkReceipts holds each successful Result until its transaction is
+// mined and fills in Receipt and GasUsed before publishing it. Receipts are
+// fetched once per block with eth_getBlockReceipts, so transactions mined in
+// the same block share one round-trip. A result whose transaction is not
+// mined within the receipt timeout (see WithReceiptTimeout), e.g. because
+// it was replaced or dropped from the mempool, is published with an error
+// wrapping transaction.ErrReceiptTimeout. Results still waiting when the
+// processor closes are published with an error wrapping
+// ErrProcessorShutdown.
+func WithBulkReceipts(pollInterval time.Duration) Option {
+	return func(p *Processor) {
+		if pollInterval <= 0 {
+			pollInterval = DefaultReceiptPollInterval
+		}
+		p.receipts = &receiptTracker{
+			interval: pollInterval,
+			pending:  make(map[common.Hash]*heldResult),
+		}
+	}
+}
+
+// WithReceiptTimeout sets how long WithBulkReceipts waits for a
+// transaction to be mined before giving up on its receipt (default
+// DefaultReceiptTimeout)
+func WithReceiptTimeout(timeout time.Duration) Option {
+	return func(p *Processor) {
+		if timeout > 0 {
+			p.receiptTimeout = timeout
+		}
+	}
+}
+
+// WithFailOnRevert makes WithBulkReceipts report a reverted transaction as
+// a failure: its Result.Error wraps transaction.ErrReverted and it counts
+// as failed in the metrics
+func WithFailOnRevert(enabled bool) Option {
+	return func(p *Processor) {
+		p.failOnRevert = enabled
+	}
+}
+
+// receiptTracker holds results whose transactions have not been mined yet
+type receiptTracker struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[common.Hash]*heldResult
+}
+
+// heldResult is a result waiting for its receipt and when it was held
+type heldResult struct {
+	result *Result
+	since  time.Time
+}
+
+// hold parks a result until its transaction's receipt is found
+func (t *receiptTracker) hold(result *Result) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[result.Transaction.Hash()] = &heldResult{result: result, since: time.Now()}
+}
+
+// take removes and returns the result waiting on txHash, if any
+func (t *receiptTracker) take(txHash common.Hash) *Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	held, ok := t.pending[txHash]
+	if !ok {
+		return nil
+	}
+	delete(t.pending, txHash)
+	return held.result
+}
+
+// expire removes and returns the results held since before cutoff, in
+// submission order
+func (t *receiptTracker) expire(cutoff time.Time) []*Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var results []*Result
+	for txHash, held := range t.pending {
+		if held.since.Before(cutoff) {
+			results = append(results, held.result)
+			delete(t.pending, txHash)
+		}
+	}
+	sortBySubmission(results)
+	return results
+}
+
+// takeAll removes and returns every waiting result, in submission order
+func (t *receiptTracker) takeAll() []*Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	results := make([]*Result, 0, len(t.pending))
+	for txHash, held := range t.pending {
+		results = append(results, held.result)
+		delete(t.pending, txHash)
+	}
+	sortBySubmission(results)
+	return results
+}
+
+func sortBySubmission(results []*Result) {
+	slices.SortFunc(results, func(a, b *Result) int {
+		return a.Request.Timestamp.Compare(b.Request.Timestamp)
+	})
+}
+
+func (t *receiptTracker) waiting() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+// trackReceipts polls the chain head and fetches the receipts of every new
+// block while results are waiting
+func (p *Processor) trackReceipts() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.receipts.interval)
+	defer ticker.Stop()
+
+	var next uint64
+	started := false
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, result := range p.receipts.expire(time.Now().Add(-p.receiptTimeout)) {
+			result.Error = fmt.Errorf("%w: %s not mined within %s",
+				transaction.ErrReceiptTimeout, result.Transaction.Hash().Hex(), p.receiptTimeout)
+			p.metrics.IncrementFailed()
+			p.publish(result)
+		}
+
+		head, err := p.manager.BlockNumber(p.ctx)
+		if err != nil {
+			continue
+		}
+		// While idle keep next at the head rather than past it: a result held
+		// just after this check may belong to a transaction already mined in it
+		if !started || p.receipts.waiting() == 0 {
+			next, started = head, true
+			continue
+		}
+
+		for ; next <= head; next++ {
+			receipts, err := p.manager.BlockReceipts(p.ctx, next)
+			if err != nil {
+				// Retry this block on the next tick
+				break
+			}
+			for _, receipt := range receipts {
+				if result := p.receipts.take(receipt.TxHash); result != nil {
+					result.Receipt = receipt
+					result.GasUsed = receipt.GasUsed
+					if p.failOnRevert && receipt.Status == types.ReceiptStatusFailed {
+						result.Error = fmt.Errorf("%w: %s", transaction.ErrReverted, receipt.TxHash.Hex())
+						p.metrics.IncrementFailed()
+					}
+					p.publish(result)
+				}
+			}
+		}
+	}
+}
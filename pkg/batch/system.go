@@ -0,0 +1,42 @@
+package batch
+
+import (
+	"time"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+// System pairs a manager with a processor for combined reporting
+type System struct {
+	manager   *transaction.Manager
+	processor *Processor
+}
+
+// SystemSnapshot holds manager and processor metrics captured together
+type SystemSnapshot struct {
+	Time      time.Time              `json:"time"`
+	Manager   map[string]uint64      `json:"manager"`
+	Processor map[string]interface{} `json:"processor"`
+}
+
+// NewSystem creates a System reporting on manager and processor, typically
+// the manager the processor sends through
+func NewSystem(manager *transaction.Manager, processor *Processor) *System {
+	return &System{manager: manager, processor: processor}
+}
+
+// Snapshot captures the manager's and the processor's metrics together,
+// reading the manager's counters while the processor's metrics are locked
+// so no result is recorded between the two reads. A send still in flight
+// may already be counted by the manager but not yet by the processor.
+func (s *System) Snapshot() SystemSnapshot {
+	p := s.processor
+	p.metrics.mu.RLock()
+	defer p.metrics.mu.RUnlock()
+
+	return SystemSnapshot{
+		Time:      time.Now(),
+		Manager:   s.manager.Metrics(),
+		Processor: p.metricsLocked(),
+	}
+}
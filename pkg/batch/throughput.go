@@ -0,0 +1,57 @@
+package batch
+
+import (
+	"sync"
+	"time"
+)
+
+// ThroughputSamples is how many completion timestamps Throughput keeps
+const ThroughputSamples = 4096
+
+// completionRing is a fixed-size ring buffer of request completion times
+type completionRing struct {
+	mu    sync.Mutex
+	times [ThroughputSamples]time.Time
+	next  int
+	count int
+}
+
+func (r *completionRing) add(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.times[r.next] = t
+	r.next = (r.next + 1) % len(r.times)
+	if r.count < len(r.times) {
+		r.count++
+	}
+}
+
+// since counts the completions at or after cutoff
+func (r *completionRing) since(cutoff time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for i := 1; i <= r.count; i++ {
+		// Walk newest to oldest and stop at the first sample outside the window
+		t := r.times[(r.next-i+len(r.times))%len(r.times)]
+		if t.Before(cutoff) {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// Throughput returns the requests completed per second over the last window,
+// successful or not. At most ThroughputSamples completions are remembered,
+// so very high rates over long windows are under-reported.
+func (p *Processor) Throughput(window time.Duration) float64 {
+	if window <= 0 {
+		return 0
+	}
+
+	n := p.completions.since(time.Now().Add(-window))
+	return float64(n) / window.Seconds()
+}
@@ -1,29 +1,39 @@
 package cache
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	lru "github.com/hashicorp/golang-lru"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/merkle"
 )
 
-// ProofCache stores proofs with TTL
+// ProofCache stores proofs with TTL. Proofs stored with SetAt can also be
+// looked up by block hash and transaction index.
 type ProofCache struct {
 	cache *sync.Map
-	ttl   time.Duration
+	// positions maps "blockHash:index" keys to transaction hashes
+	positions *sync.Map
+	ttl       time.Duration
 }
 
 type CachedProof struct {
 	Proof     interface{}
 	Timestamp time.Time
+	// position is the secondary index key, empty if stored with Set
+	position string
 }
 
 func NewProofCache(ttl time.Duration) *ProofCache {
 	pc := &ProofCache{
-		cache: &sync.Map{},
-		ttl:   ttl,
+		cache:     &sync.Map{},
+		positions: &sync.Map{},
+		ttl:       ttl,
 	}
 	go pc.cleanup()
 	return pc
@@ -44,7 +54,7 @@ func (pc *ProofCache) Get(txHash common.Hash) (interface{}, bool) {
 
 	// Check if expired
 	if time.Since(cached.Timestamp) > pc.ttl {
-		pc.cache.Delete(txHash.Hex())
+		pc.remove(txHash.Hex(), cached)
 		return nil, false
 	}
 
@@ -52,14 +62,90 @@ func (pc *ProofCache) Get(txHash common.Hash) (interface{}, bool) {
 }
 
 func (pc *ProofCache) Set(txHash common.Hash, proof interface{}) {
-	pc.cache.Store(txHash.Hex(), &CachedProof{
+	pc.store(txHash.Hex(), &CachedProof{
+		Proof:     proof,
+		Timestamp: time.Now(),
+	})
+}
+
+// SetAt stores proof for txHash and indexes it by the block and transaction
+// index it proves, for GetByIndex
+func (pc *ProofCache) SetAt(txHash, blockHash common.Hash, index uint, proof interface{}) {
+	position := positionKey(blockHash, index)
+	pc.store(txHash.Hex(), &CachedProof{
 		Proof:     proof,
 		Timestamp: time.Now(),
+		position:  position,
 	})
+	pc.positions.Store(position, txHash.Hex())
+}
+
+// GetByIndex returns the proof stored with SetAt for the transaction at
+// index in blockHash
+func (pc *ProofCache) GetByIndex(blockHash common.Hash, index uint) (interface{}, bool) {
+	val, ok := pc.positions.Load(positionKey(blockHash, index))
+	if !ok {
+		return nil, false
+	}
+	return pc.Get(common.HexToHash(val.(string)))
 }
 
 func (pc *ProofCache) Delete(txHash common.Hash) {
-	pc.cache.Delete(txHash.Hex())
+	if val, ok := pc.cache.Load(txHash.Hex()); ok {
+		cached, _ := val.(*CachedProof)
+		pc.remove(txHash.Hex(), cached)
+	}
+}
+
+// InvalidateBlock deletes every proof stored with SetAt for a transaction
+// in blockHash, along with its position index
+func (pc *ProofCache) InvalidateBlock(blockHash common.Hash) {
+	prefix := blockHash.Hex() + ":"
+	pc.positions.Range(func(position, key interface{}) bool {
+		if !strings.HasPrefix(position.(string), prefix) {
+			return true
+		}
+		if val, ok := pc.cache.Load(key); ok {
+			cached, _ := val.(*CachedProof)
+			pc.remove(key.(string), cached)
+		}
+		pc.positions.CompareAndDelete(position, key)
+		return true
+	})
+}
+
+// store replaces the entry for key, dropping the position index of the
+// proof it replaces (e.g. one from a reorganised block)
+func (pc *ProofCache) store(key string, cached *CachedProof) {
+	if old, loaded := pc.cache.Swap(key, cached); loaded {
+		if old, ok := old.(*CachedProof); ok && old.position != "" && old.position != cached.position {
+			pc.positions.CompareAndDelete(old.position, key)
+		}
+	}
+}
+
+// remove deletes the entry for key and its position index, unless the
+// position now belongs to another transaction
+func (pc *ProofCache) remove(key string, cached *CachedProof) {
+	pc.cache.CompareAndDelete(key, cached)
+	if cached != nil && cached.position != "" {
+		pc.positions.CompareAndDelete(cached.position, key)
+	}
+}
+
+func positionKey(blockHash common.Hash, index uint) string {
+	return fmt.Sprintf("%s:%d", blockHash.Hex(), index)
+}
+
+// Len returns the number of stored proofs, including expired ones not yet
+// cleaned up
+func (pc *ProofCache) Len() int {
+	n := 0
+	pc.cache.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
 }
 
 func (pc *ProofCache) cleanup() {
@@ -75,7 +161,7 @@ func (pc *ProofCache) cleanup() {
 			}
 
 			if time.Since(cached.Timestamp) > pc.ttl {
-				pc.cache.Delete(key)
+				pc.remove(key.(string), cached)
 			}
 			return true
 		})
@@ -168,6 +254,154 @@ func (rc *ReceiptCache) Delete(txHash common.Hash) {
 	rc.cache.Remove(txHash.Hex())
 }
 
+// InvalidateBlock deletes every receipt from blockHash
+func (rc *ReceiptCache) InvalidateBlock(blockHash common.Hash) {
+	for _, key := range rc.cache.Keys() {
+		if val, ok := rc.cache.Peek(key); ok {
+			if receipt, ok := val.(*types.Receipt); !ok || receipt.BlockHash == blockHash {
+				rc.cache.Remove(key)
+			}
+		}
+	}
+}
+
 func (rc *ReceiptCache) Len() int {
 	return rc.cache.Len()
 }
+
+// TreeCache stores Merkle trees per block with LRU eviction
+type TreeCache struct {
+	cache *lru.Cache
+}
+
+// NewTreeCache creates a new tree cache
+// size: Maximum number of block trees to cache
+func NewTreeCache(size int) (*TreeCache, error) {
+	if size < 1 {
+		size = 100
+	}
+
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &TreeCache{cache: cache}, nil
+}
+
+func (tc *TreeCache) Get(blockHash common.Hash) (*merkle.Tree, bool) {
+	val, ok := tc.cache.Get(blockHash.Hex())
+	if !ok {
+		return nil, false
+	}
+
+	tree, ok := val.(*merkle.Tree)
+	if !ok {
+		// Invalid type, remove it
+		tc.cache.Remove(blockHash.Hex())
+		return nil, false
+	}
+
+	return tree, true
+}
+
+func (tc *TreeCache) Set(blockHash common.Hash, tree *merkle.Tree) {
+	tc.cache.Add(blockHash.Hex(), tree)
+}
+
+func (tc *TreeCache) Delete(blockHash common.Hash) {
+	tc.cache.Remove(blockHash.Hex())
+}
+
+func (tc *TreeCache) Len() int {
+	return tc.cache.Len()
+}
+
+// VerificationCache remembers successful proof verifications with LRU
+// eviction, keyed by a digest of the proof content
+type VerificationCache struct {
+	cache *lru.Cache
+}
+
+// NewVerificationCache creates a new verification cache
+// size: Maximum number of verification results to cache
+func NewVerificationCache(size int) (*VerificationCache, error) {
+	if size < 1 {
+		size = 1000
+	}
+
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &VerificationCache{cache: cache}, nil
+}
+
+// Contains reports whether the proof identified by key was verified
+func (vc *VerificationCache) Contains(key common.Hash) bool {
+	return vc.cache.Contains(key.Hex())
+}
+
+// Add records that the proof identified by key verified against blockHash
+func (vc *VerificationCache) Add(key common.Hash, blockHash common.Hash) {
+	vc.cache.Add(key.Hex(), blockHash)
+}
+
+// InvalidateBlock forgets every verification made against blockHash
+func (vc *VerificationCache) InvalidateBlock(blockHash common.Hash) {
+	for _, key := range vc.cache.Keys() {
+		if val, ok := vc.cache.Peek(key); ok && val == blockHash {
+			vc.cache.Remove(key)
+		}
+	}
+}
+
+func (vc *VerificationCache) Len() int {
+	return vc.cache.Len()
+}
+
+// BloomCache stores a bloom filter per block with LRU eviction
+type BloomCache struct {
+	cache *lru.Cache
+}
+
+// NewBloomCache creates a new bloom cache
+// size: Maximum number of block filters to cache
+func NewBloomCache(size int) (*BloomCache, error) {
+	if size < 1 {
+		size = 1000
+	}
+
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &BloomCache{cache: cache}, nil
+}
+
+func (bc *BloomCache) Get(blockHash common.Hash) (types.Bloom, bool) {
+	val, ok := bc.cache.Get(blockHash.Hex())
+	if !ok {
+		return types.Bloom{}, false
+	}
+
+	bloom, ok := val.(types.Bloom)
+	if !ok {
+		// Invalid type, remove it
+		bc.cache.Remove(blockHash.Hex())
+		return types.Bloom{}, false
+	}
+
+	return bloom, true
+}
+
+func (bc *BloomCache) Set(blockHash common.Hash, bloom types.Bloom) {
+	bc.cache.Add(blockHash.Hex(), bloom)
+}
+
+func (bc *BloomCache) Delete(blockHash common.Hash) {
+	bc.cache.Remove(blockHash.Hex())
+}
+
+func (bc *BloomCache) Len() int {
+	return bc.cache.Len()
+}
@@ -0,0 +1,111 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/cache"
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/merkle"
+)
+
+func TestTreeCacheEviction(t *testing.T) {
+	tc, err := cache.NewTreeCache(2)
+	if err != nil {
+		t.Fatalf("NewTreeCache failed: %v", err)
+	}
+
+	hashes := []common.Hash{{0x01}, {0x02}, {0x03}}
+	for _, hash := range hashes {
+		tc.Set(hash, merkle.NewTree(types.Transactions{}))
+	}
+
+	if tc.Len() != 2 {
+		t.Errorf("Len = %d, want 2", tc.Len())
+	}
+	if _, ok := tc.Get(hashes[0]); ok {
+		t.Error("oldest tree should have been evicted")
+	}
+	for _, hash := range hashes[1:] {
+		if _, ok := tc.Get(hash); !ok {
+			t.Errorf("tree for %s should still be cached", hash.Hex())
+		}
+	}
+}
+
+func TestProofCacheByIndex(t *testing.T) {
+	pc := cache.NewProofCache(time.Minute)
+
+	txHash, blockHash := common.Hash{0x01}, common.Hash{0xb1}
+	pc.SetAt(txHash, blockHash, 3, "proof")
+
+	if proof, ok := pc.Get(txHash); !ok || proof != "proof" {
+		t.Errorf("Get = %v, %v; want the proof", proof, ok)
+	}
+	if proof, ok := pc.GetByIndex(blockHash, 3); !ok || proof != "proof" {
+		t.Errorf("GetByIndex = %v, %v; want the proof", proof, ok)
+	}
+	if _, ok := pc.GetByIndex(blockHash, 4); ok {
+		t.Error("GetByIndex found a proof at another index")
+	}
+
+	// Re-caching the transaction in another block moves its index
+	reorged := common.Hash{0xb2}
+	pc.SetAt(txHash, reorged, 0, "reorged proof")
+	if _, ok := pc.GetByIndex(blockHash, 3); ok {
+		t.Error("GetByIndex still finds the proof at its old position")
+	}
+	if proof, ok := pc.GetByIndex(reorged, 0); !ok || proof != "reorged proof" {
+		t.Errorf("GetByIndex = %v, %v; want the reorged proof", proof, ok)
+	}
+
+	pc.Delete(txHash)
+	if _, ok := pc.GetByIndex(reorged, 0); ok {
+		t.Error("GetByIndex finds a deleted proof")
+	}
+	if pc.Len() != 0 {
+		t.Errorf("Len = %d after Delete, want 0", pc.Len())
+	}
+}
+
+func TestProofCacheInvalidateBlock(t *testing.T) {
+	pc := cache.NewProofCache(time.Minute)
+
+	orphaned, kept := common.Hash{0xb1}, common.Hash{0xb2}
+	pc.SetAt(common.Hash{0x01}, orphaned, 0, "first")
+	pc.SetAt(common.Hash{0x02}, orphaned, 1, "second")
+	pc.SetAt(common.Hash{0x03}, kept, 0, "other block")
+
+	pc.InvalidateBlock(orphaned)
+
+	for index := uint(0); index < 2; index++ {
+		if _, ok := pc.GetByIndex(orphaned, index); ok {
+			t.Errorf("GetByIndex still finds index %d of the invalidated block", index)
+		}
+	}
+	if _, ok := pc.Get(common.Hash{0x01}); ok {
+		t.Error("Get still finds a proof from the invalidated block")
+	}
+	if proof, ok := pc.GetByIndex(kept, 0); !ok || proof != "other block" {
+		t.Errorf("GetByIndex = %v, %v; want the other block's proof", proof, ok)
+	}
+	if pc.Len() != 1 {
+		t.Errorf("Len = %d, want 1", pc.Len())
+	}
+}
+
+func TestProofCacheByIndexExpires(t *testing.T) {
+	pc := cache.NewProofCache(10 * time.Millisecond)
+
+	pc.SetAt(common.Hash{0x01}, common.Hash{0xb1}, 0, "proof")
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := pc.GetByIndex(common.Hash{0xb1}, 0); ok {
+		t.Error("GetByIndex returned an expired proof")
+	}
+	if pc.Len() != 0 {
+		t.Errorf("Len = %d, want the expired proof removed", pc.Len())
+	}
+}
@@ -1,35 +1,199 @@
 package merkle
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
+var (
+	// ErrIndexOutOfRange is returned for a leaf index outside the tree
+	ErrIndexOutOfRange = errors.New("leaf index out of range")
+	// ErrProofLength is returned when a proof has the wrong number of hashes
+	ErrProofLength = errors.New("proof has the wrong number of hashes")
+	// ErrRootMismatch is returned when a proof does not reconstruct the root
+	ErrRootMismatch = errors.New("proof does not match the root")
+	// ErrLeafMismatch is returned when the tree holds another leaf at the
+	// proof's index
+	ErrLeafMismatch = errors.New("leaf is not at the proof's index")
+	// ErrPathMismatch is returned when a proof is not the tree's own path
+	// for its index
+	ErrPathMismatch = errors.New("proof is not the path for its index")
+)
+
+// pairPool holds the 64-byte buffers hashPair concatenates a||b into, so
+// building and verifying large trees does not allocate per hash
+var pairPool = sync.Pool{
+	New: func() interface{} { return new([2 * common.HashLength]byte) },
+}
+
+// hashPair returns Keccak256(a || b)
+func hashPair(a, b common.Hash) common.Hash {
+	buf := pairPool.Get().(*[2 * common.HashLength]byte)
+	copy(buf[:common.HashLength], a[:])
+	copy(buf[common.HashLength:], b[:])
+	h := crypto.Keccak256Hash(buf[:])
+	pairPool.Put(buf)
+	return h
+}
+
+// HashOrder decides how a node's two children are ordered before hashing
+type HashOrder int
+
+const (
+	// IndexBased hashes the left child (even index) before the right one
+	IndexBased HashOrder = iota
+	// SortedPair hashes the smaller child first, as in OpenZeppelin's
+	// MerkleProof, so verifiers need no leaf index
+	SortedPair
+)
+
+func (o HashOrder) String() string {
+	switch o {
+	case IndexBased:
+		return "index-based"
+	case SortedPair:
+		return "sorted-pair"
+	default:
+		return fmt.Sprintf("HashOrder(%d)", int(o))
+	}
+}
+
+// combine hashes the children left and right into their parent
+func (o HashOrder) combine(left, right common.Hash) common.Hash {
+	if o == SortedPair && bytes.Compare(left[:], right[:]) > 0 {
+		left, right = right, left
+	}
+	return hashPair(left, right)
+}
+
+// LeafFunc derives a transaction's leaf hash
+type LeafFunc func(tx *types.Transaction) common.Hash
+
+// TxHashLeaf uses the transaction hash as the leaf, the default
+func TxHashLeaf(tx *types.Transaction) common.Hash {
+	return tx.Hash()
+}
+
+// RLPLeaf uses keccak(rlp(tx)) as the leaf, as some other tree
+// implementations do. It matches TxHashLeaf for legacy transactions only;
+// typed transactions are RLP-encoded as a string wrapping their envelope.
+func RLPLeaf(tx *types.Transaction) common.Hash {
+	// Encoding a signed transaction cannot fail
+	enc, _ := rlp.EncodeToBytes(tx)
+	return crypto.Keccak256Hash(enc)
+}
+
+// parallelMinHashes is the smallest number of hashes in a layer worth
+// splitting across workers; below it goroutine overhead outweighs the gain
+const parallelMinHashes = 1024
+
 type Tree struct {
 	root   common.Hash
 	leaves []common.Hash
 	layers [][]common.Hash
 	mu     sync.RWMutex
+
+	// workers is how many goroutines hash each large layer during build
+	workers int
+	// order decides how children are hashed during build and CheckProof
+	order HashOrder
+	// leaf derives the leaves from the transactions
+	leaf LeafFunc
+
+	// bindIndex makes CheckProof check proofs against their index, see
+	// WithIndexBinding
+	bindIndex bool
+}
+
+// Option configures how a Tree is built
+type Option func(*Tree)
+
+// WithWorkers hashes leaves and large layers with up to n goroutines.
+// Values below 2 build sequentially. The tree is identical either way.
+func WithWorkers(n int) Option {
+	return func(t *Tree) {
+		t.workers = n
+	}
+}
+
+// WithHashOrder sets how children are ordered when hashing nodes (default
+// IndexBased). Proofs must be verified with the order the tree was built
+// with.
+func WithHashOrder(order HashOrder) Option {
+	return func(t *Tree) {
+		t.order = order
+	}
+}
+
+// WithLeaf sets how leaves are derived from transactions (default
+// TxHashLeaf). Leaves passed to VerifyProof must be derived the same way;
+// see Leaf and VerifyTxProof.
+func WithLeaf(fn LeafFunc) Option {
+	return func(t *Tree) {
+		t.leaf = fn
+	}
 }
 
-func NewTree(txs types.Transactions) *Tree {
-	leaves := make([]common.Hash, len(txs))
-	for i, tx := range txs {
-		leaves[i] = tx.Hash()
+// WithIndexBinding makes CheckProof and VerifyProof reject a proof unless
+// it is the tree's own path for the leaf at the given index, as CheckProofAt
+// does. Off by default, so a proof checks out whenever it reconstructs the
+// root.
+func WithIndexBinding() Option {
+	return func(t *Tree) {
+		t.bindIndex = true
 	}
+}
 
+func NewTree(txs types.Transactions, opts ...Option) *Tree {
 	tree := &Tree{
-		leaves: leaves,
+		leaves: make([]common.Hash, len(txs)),
 		layers: make([][]common.Hash, 0),
+		leaf:   TxHashLeaf,
+	}
+	for _, opt := range opts {
+		opt(tree)
 	}
 
+	tree.forEach(len(txs), func(i int) {
+		tree.leaves[i] = tree.leaf(txs[i])
+	})
+
 	tree.build()
 	return tree
 }
 
+// forEach calls fn for every index below n, splitting large ranges into
+// contiguous chunks across the tree's workers
+func (t *Tree) forEach(n int, fn func(i int)) {
+	if t.workers < 2 || n < parallelMinHashes {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	chunk := (n + t.workers - 1) / t.workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < n; lo += chunk {
+		hi := min(lo+chunk, n)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := lo; i < hi; i++ {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 // build constructs all layers once (O(n))
 func (t *Tree) build() {
 	t.mu.Lock()
@@ -42,20 +206,19 @@ func (t *Tree) build() {
 
 	// Build tree bottom-up, caching each layer
 	for len(currentLevel) > 1 {
-		nextLevel := make([]common.Hash, 0, (len(currentLevel)+1)/2)
+		level := currentLevel
+		nextLevel := make([]common.Hash, (len(level)+1)/2)
 
 		// Combine pairs of nodes
-		for i := 0; i < len(currentLevel); i += 2 {
-			if i+1 < len(currentLevel) {
+		t.forEach(len(nextLevel), func(i int) {
+			if 2*i+1 < len(level) {
 				// Hash pair of nodes together
-				combined := append(currentLevel[i].Bytes(), currentLevel[i+1].Bytes()...)
-				parentHash := crypto.Keccak256Hash(combined)
-				nextLevel = append(nextLevel, parentHash)
+				nextLevel[i] = t.order.combine(level[2*i], level[2*i+1])
 			} else {
 				// Odd number of nodes, promote the last one
-				nextLevel = append(nextLevel, currentLevel[i])
+				nextLevel[i] = level[2*i]
 			}
-		}
+		})
 
 		t.layers = append(t.layers, nextLevel)
 		currentLevel = nextLevel
@@ -95,27 +258,159 @@ func (t *Tree) GenerateProof(index uint) []common.Hash {
 	return proof
 }
 
+// ProofStep is one level of a Merkle proof with its direction made explicit
+type ProofStep struct {
+	Sibling common.Hash
+	// IsLeft is true if Sibling is hashed on the left of the running hash
+	IsLeft bool
+}
+
+// GenerateProofWithDirections returns the proof for the leaf at index as
+// steps that carry their own direction, so verifiers need not know the index
+func (t *Tree) GenerateProofWithDirections(index uint) ([]ProofStep, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if index >= uint(len(t.leaves)) {
+		return nil, fmt.Errorf("%w: index %d, tree has %d leaves", ErrIndexOutOfRange, index, len(t.leaves))
+	}
+
+	steps := make([]ProofStep, 0, len(t.layers)-1)
+	currentIndex := index
+
+	for level := 0; level < len(t.layers)-1; level++ {
+		layer := t.layers[level]
+
+		siblingIndex := currentIndex ^ 1
+		if siblingIndex < uint(len(layer)) {
+			steps = append(steps, ProofStep{
+				Sibling: layer[siblingIndex],
+				IsLeft:  currentIndex%2 == 1,
+			})
+		}
+
+		currentIndex >>= 1
+	}
+
+	return steps, nil
+}
+
+// VerifyProofSteps reports whether steps lead from leaf to root in an
+// IndexBased tree; SortedPair proofs need no directions, see
+// VerifySortedPairProof
+func VerifyProofSteps(root, leaf common.Hash, steps []ProofStep) bool {
+	currentHash := leaf
+	for _, step := range steps {
+		if step.IsLeft {
+			currentHash = hashPair(step.Sibling, currentHash)
+		} else {
+			currentHash = hashPair(currentHash, step.Sibling)
+		}
+	}
+	return currentHash == root
+}
+
+// VerifySortedPairProof reports whether proof leads from leaf to root when
+// every pair is hashed smaller-first. It needs neither the tree nor the leaf
+// index, so it checks SortedPair proofs produced by other tools.
+func VerifySortedPairProof(root, leaf common.Hash, proof []common.Hash) bool {
+	currentHash := leaf
+	for _, sibling := range proof {
+		currentHash = SortedPair.combine(currentHash, sibling)
+	}
+	return currentHash == root
+}
+
 func (t *Tree) VerifyProof(leaf common.Hash, index uint, proof []common.Hash) bool {
+	return t.CheckProof(leaf, index, proof) == nil
+}
+
+// Leaf derives tx's leaf the way the tree derived its own
+func (t *Tree) Leaf(tx *types.Transaction) common.Hash {
+	return t.leaf(tx)
+}
+
+// VerifyTxProof verifies proof for tx at index, deriving its leaf with Leaf
+func (t *Tree) VerifyTxProof(tx *types.Transaction, index uint, proof []common.Hash) bool {
+	return t.VerifyProof(t.Leaf(tx), index, proof)
+}
+
+// CheckProof verifies proof like VerifyProof, but reports why a proof is
+// rejected. The index is bounds-checked against the leaf count and the proof
+// length against the tree depth before any hashing, so arbitrary input
+// cannot make verification panic. Trees built WithIndexBinding also check
+// the proof against the index, as CheckProofAt does.
+func (t *Tree) CheckProof(leaf common.Hash, index uint, proof []common.Hash) error {
+	t.mu.RLock()
+	bind := t.bindIndex
+	t.mu.RUnlock()
+
+	return t.checkProof(leaf, index, proof, bind)
+}
+
+// CheckProofAt is CheckProof bound to index: walking the index's direction
+// bits, the running hash must be the tree's own node at every level, so the
+// leaf must be the one stored at index and proof its path. Without this a
+// SortedPair proof also verifies under its sibling's index, since the pair
+// hashes the same either way round.
+func (t *Tree) CheckProofAt(leaf common.Hash, index uint, proof []common.Hash) error {
+	return t.checkProof(leaf, index, proof, true)
+}
+
+func (t *Tree) checkProof(leaf common.Hash, index uint, proof []common.Hash, bind bool) error {
 	t.mu.RLock()
 	root := t.root
+	leafCount := uint(len(t.leaves))
+	layers := t.layers
+	order := t.order
 	t.mu.RUnlock()
 
+	if index >= leafCount {
+		return fmt.Errorf("%w: index %d, tree has %d leaves", ErrIndexOutOfRange, index, leafCount)
+	}
+
 	currentHash := leaf
 	currentIndex := index
+	used := 0
 
-	for _, siblingHash := range proof {
-		if currentIndex%2 == 0 {
-			combined := append(currentHash.Bytes(), siblingHash.Bytes()...)
-			currentHash = crypto.Keccak256Hash(combined)
-		} else {
-			combined := append(siblingHash.Bytes(), currentHash.Bytes()...)
-			currentHash = crypto.Keccak256Hash(combined)
+	// Walk the level sizes so promoted nodes, which have no sibling, are
+	// skipped exactly as GenerateProof skips them
+	for level, size := 0, leafCount; size > 1; level, size = level+1, (size+1)/2 {
+		if bind && currentHash != layers[level][currentIndex] {
+			if level == 0 {
+				return fmt.Errorf("%w: index %d", ErrLeafMismatch, index)
+			}
+			return fmt.Errorf("%w: index %d, level %d", ErrPathMismatch, index, level)
 		}
+
+		siblingIndex := currentIndex ^ 1
+
+		if siblingIndex < size {
+			if used >= len(proof) {
+				return fmt.Errorf("%w: got %d", ErrProofLength, len(proof))
+			}
+			siblingHash := proof[used]
+			used++
+
+			if currentIndex%2 == 0 {
+				currentHash = order.combine(currentHash, siblingHash)
+			} else {
+				currentHash = order.combine(siblingHash, currentHash)
+			}
+		}
+
 		currentIndex >>= 1
 	}
 
+	if used != len(proof) {
+		return fmt.Errorf("%w: got %d, want %d", ErrProofLength, len(proof), used)
+	}
+
 	// If we reconstructed the same root, proof is valid
-	return currentHash == root
+	if currentHash != root {
+		return ErrRootMismatch
+	}
+	return nil
 }
 
 func (t *Tree) Root() common.Hash {
@@ -135,3 +430,33 @@ func (t *Tree) Depth() int {
 	defer t.mu.RUnlock()
 	return len(t.layers)
 }
+
+// NodeCount returns the number of hashes stored across all layers
+func (t *Tree) NodeCount() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	count := 0
+	for _, layer := range t.layers {
+		count += len(layer)
+	}
+	return count
+}
+
+// MemoryBytes estimates the memory held by the tree's hashes
+func (t *Tree) MemoryBytes() int {
+	return t.NodeCount() * common.HashLength
+}
+
+// IndexOf returns the index of leaf in the tree, if present
+func (t *Tree) IndexOf(leaf common.Hash) (uint, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for i, hash := range t.leaves {
+		if hash == leaf {
+			return uint(i), true
+		}
+	}
+	return 0, false
+}
@@ -1,6 +1,8 @@
 package merkle_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"math/big"
 	"testing"
@@ -8,6 +10,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/k4rz4/ethereum-custom-transactions/pkg/merkle"
 	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
 )
@@ -35,6 +38,18 @@ func TestMerkleTree(t *testing.T) {
 	t.Logf("✅ Proof verified: %d hashes for 8 txs", len(proof))
 }
 
+func TestRootMatchesPairHashing(t *testing.T) {
+	txs := createTestTxs(3)
+	tree := merkle.NewTree(txs)
+
+	// Two leaves are paired and the third is promoted
+	pair := crypto.Keccak256Hash(append(txs[0].Hash().Bytes(), txs[1].Hash().Bytes()...))
+	want := crypto.Keccak256Hash(append(pair.Bytes(), txs[2].Hash().Bytes()...))
+	if tree.Root() != want {
+		t.Errorf("Root() = %s, want %s", tree.Root().Hex(), want.Hex())
+	}
+}
+
 func BenchmarkProofGeneration(b *testing.B) {
 	txs := createTestTxs(1000)
 	tree := merkle.NewTree(txs)
@@ -65,3 +80,264 @@ func addrPtr(hex string) *common.Address {
 	addr := common.HexToAddress(hex)
 	return &addr
 }
+
+func TestProofOddLeafCounts(t *testing.T) {
+	for _, count := range []int{1, 3, 5, 6, 7, 9} {
+		txs := createTestTxs(count)
+		tree := merkle.NewTree(txs)
+
+		for i := range txs {
+			proof := tree.GenerateProof(uint(i))
+			if err := tree.CheckProof(txs[i].Hash(), uint(i), proof); err != nil {
+				t.Errorf("%d leaves, index %d: %v", count, i, err)
+			}
+		}
+	}
+}
+
+func TestCheckProofBounds(t *testing.T) {
+	txs := createTestTxs(4)
+	tree := merkle.NewTree(txs)
+	proof := tree.GenerateProof(1)
+
+	huge := ^uint(0)
+	if err := tree.CheckProof(txs[1].Hash(), huge, proof); !errors.Is(err, merkle.ErrIndexOutOfRange) {
+		t.Errorf("CheckProof(huge index) error = %v, want ErrIndexOutOfRange", err)
+	}
+	if tree.VerifyProof(txs[1].Hash(), 4, proof) {
+		t.Error("VerifyProof accepted an index equal to the leaf count")
+	}
+
+	long := append(append([]common.Hash{}, proof...), common.Hash{})
+	if err := tree.CheckProof(txs[1].Hash(), 1, long); !errors.Is(err, merkle.ErrProofLength) {
+		t.Errorf("CheckProof(long proof) error = %v, want ErrProofLength", err)
+	}
+	if err := tree.CheckProof(txs[1].Hash(), 1, proof[:1]); !errors.Is(err, merkle.ErrProofLength) {
+		t.Errorf("CheckProof(short proof) error = %v, want ErrProofLength", err)
+	}
+	if err := tree.CheckProof(txs[2].Hash(), 1, proof); !errors.Is(err, merkle.ErrRootMismatch) {
+		t.Errorf("CheckProof(wrong leaf) error = %v, want ErrRootMismatch", err)
+	}
+}
+
+func TestNodeCount(t *testing.T) {
+	tests := []struct {
+		leaves int
+		nodes  int
+	}{
+		{1, 1},
+		{2, 3},  // 2 + 1
+		{3, 6},  // 3 + 2 + 1
+		{4, 7},  // 4 + 2 + 1
+		{5, 11}, // 5 + 3 + 2 + 1
+		{8, 15}, // 8 + 4 + 2 + 1
+	}
+
+	for _, tt := range tests {
+		tree := merkle.NewTree(createTestTxs(tt.leaves))
+		if got := tree.NodeCount(); got != tt.nodes {
+			t.Errorf("%d leaves: NodeCount = %d, want %d", tt.leaves, got, tt.nodes)
+		}
+		if got := tree.MemoryBytes(); got != tt.nodes*32 {
+			t.Errorf("%d leaves: MemoryBytes = %d, want %d", tt.leaves, got, tt.nodes*32)
+		}
+	}
+}
+
+func TestProofWithDirections(t *testing.T) {
+	for _, count := range []int{1, 2, 5, 8} {
+		txs := createTestTxs(count)
+		tree := merkle.NewTree(txs)
+
+		for i := range txs {
+			steps, err := tree.GenerateProofWithDirections(uint(i))
+			if err != nil {
+				t.Fatalf("%d leaves, index %d: %v", count, i, err)
+			}
+
+			// The same siblings, in the same order, as the index-based proof
+			proof := tree.GenerateProof(uint(i))
+			if len(steps) != len(proof) {
+				t.Fatalf("%d leaves, index %d: %d steps, %d proof hashes", count, i, len(steps), len(proof))
+			}
+			for level, step := range steps {
+				if step.Sibling != proof[level] {
+					t.Errorf("%d leaves, index %d: step %d sibling differs from proof", count, i, level)
+				}
+			}
+
+			leaf := txs[i].Hash()
+			if !merkle.VerifyProofSteps(tree.Root(), leaf, steps) || !tree.VerifyProof(leaf, uint(i), proof) {
+				t.Errorf("%d leaves, index %d: verification disagrees", count, i)
+			}
+			if len(steps) > 0 {
+				steps[0].IsLeft = !steps[0].IsLeft
+				if merkle.VerifyProofSteps(tree.Root(), leaf, steps) {
+					t.Errorf("%d leaves, index %d: flipped direction still verifies", count, i)
+				}
+			}
+		}
+	}
+
+	tree := merkle.NewTree(createTestTxs(3))
+	if _, err := tree.GenerateProofWithDirections(3); !errors.Is(err, merkle.ErrIndexOutOfRange) {
+		t.Errorf("out of range error = %v, want ErrIndexOutOfRange", err)
+	}
+}
+
+func BenchmarkNewTree(b *testing.B) {
+	txs := createTestTxs(5000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		merkle.NewTree(txs)
+	}
+}
+
+func BenchmarkVerifyProof(b *testing.B) {
+	txs := createTestTxs(5000)
+	tree := merkle.NewTree(txs)
+	leaf := txs[2500].Hash()
+	proof := tree.GenerateProof(2500)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.VerifyProof(leaf, 2500, proof)
+	}
+}
+
+func TestParallelBuildMatches(t *testing.T) {
+	txs := createTestTxs(5001)
+	sequential := merkle.NewTree(txs)
+	parallel := merkle.NewTree(txs, merkle.WithWorkers(8))
+
+	if parallel.Root() != sequential.Root() {
+		t.Fatalf("parallel root %s, sequential root %s", parallel.Root().Hex(), sequential.Root().Hex())
+	}
+	for _, index := range []uint{0, 2500, 4999, 5000} {
+		proof := parallel.GenerateProof(index)
+		if err := sequential.CheckProof(txs[index].Hash(), index, proof); err != nil {
+			t.Errorf("proof %d from the parallel tree: %v", index, err)
+		}
+	}
+}
+
+func BenchmarkNewTreeParallel(b *testing.B) {
+	txs := createTestTxs(5000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		merkle.NewTree(txs, merkle.WithWorkers(8))
+	}
+}
+
+func TestSortedPairProof(t *testing.T) {
+	txs := createTestTxs(5)
+	// Put the first pair out of order so the two conventions disagree
+	if h0, h1 := txs[0].Hash(), txs[1].Hash(); bytes.Compare(h0[:], h1[:]) < 0 {
+		txs[0], txs[1] = txs[1], txs[0]
+	}
+	tree := merkle.NewTree(txs, merkle.WithHashOrder(merkle.SortedPair))
+
+	sorted := func(a, b common.Hash) common.Hash {
+		if bytes.Compare(a[:], b[:]) > 0 {
+			a, b = b, a
+		}
+		return crypto.Keccak256Hash(a[:], b[:])
+	}
+	h := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		h[i] = tx.Hash()
+	}
+	// Five leaves: two pairs and a promoted leaf, then a pair and the promoted leaf
+	want := sorted(sorted(sorted(h[0], h[1]), sorted(h[2], h[3])), h[4])
+	if tree.Root() != want {
+		t.Fatalf("sorted-pair root = %s, want %s", tree.Root().Hex(), want.Hex())
+	}
+	if indexed := merkle.NewTree(txs); indexed.Root() == tree.Root() {
+		t.Fatal("sorted-pair and index-based trees have the same root")
+	}
+
+	for i := range txs {
+		proof := tree.GenerateProof(uint(i))
+		if !merkle.VerifySortedPairProof(tree.Root(), h[i], proof) {
+			t.Errorf("VerifySortedPairProof rejected the proof of leaf %d", i)
+		}
+		if err := tree.CheckProof(h[i], uint(i), proof); err != nil {
+			t.Errorf("CheckProof(%d) on the sorted-pair tree: %v", i, err)
+		}
+		if err := tree.CheckProofAt(h[i], uint(i), proof); err != nil {
+			t.Errorf("CheckProofAt(%d) on the sorted-pair tree: %v", i, err)
+		}
+	}
+
+	// A sorted pair hashes the same either way round, so only the
+	// index-bound check rejects a proof claiming its sibling's index
+	proof := tree.GenerateProof(0)
+	if err := tree.CheckProof(h[0], 1, proof); err != nil {
+		t.Fatalf("CheckProof with the sibling's index: %v", err)
+	}
+	if err := tree.CheckProofAt(h[0], 1, proof); !errors.Is(err, merkle.ErrLeafMismatch) {
+		t.Errorf("CheckProofAt with the sibling's index = %v, want ErrLeafMismatch", err)
+	}
+	bound := merkle.NewTree(txs, merkle.WithHashOrder(merkle.SortedPair), merkle.WithIndexBinding())
+	if err := bound.CheckProof(h[0], 0, proof); err != nil {
+		t.Errorf("CheckProof on the bound tree: %v", err)
+	}
+	if err := bound.CheckProof(h[0], 1, proof); !errors.Is(err, merkle.ErrLeafMismatch) {
+		t.Errorf("CheckProof on the bound tree with the sibling's index = %v, want ErrLeafMismatch", err)
+	}
+
+	// A wrong sibling is caught at the level it feeds
+	forged := append([]common.Hash(nil), proof...)
+	forged[1] = common.HexToHash("0xbad")
+	if err := tree.CheckProof(h[0], 0, forged); !errors.Is(err, merkle.ErrRootMismatch) {
+		t.Errorf("CheckProof with a forged sibling = %v, want ErrRootMismatch", err)
+	}
+	if err := tree.CheckProofAt(h[0], 0, forged); !errors.Is(err, merkle.ErrPathMismatch) {
+		t.Errorf("CheckProofAt with a forged sibling = %v, want ErrPathMismatch", err)
+	}
+
+	// The conventions are not interchangeable
+	indexed := merkle.NewTree(txs)
+	if merkle.VerifySortedPairProof(indexed.Root(), h[0], indexed.GenerateProof(0)) {
+		t.Error("VerifySortedPairProof accepted an index-based proof")
+	}
+}
+
+func TestRLPLeaves(t *testing.T) {
+	txs := createTestTxs(4)
+	tree := merkle.NewTree(txs, merkle.WithLeaf(merkle.RLPLeaf))
+
+	leaves := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		enc, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Fatalf("EncodeToBytes failed: %v", err)
+		}
+		leaves[i] = crypto.Keccak256Hash(enc)
+		if got := tree.Leaf(tx); got != leaves[i] {
+			t.Fatalf("Leaf(%d) = %s, want keccak(rlp(tx)) %s", i, got.Hex(), leaves[i].Hex())
+		}
+	}
+	pair := func(a, b common.Hash) common.Hash { return crypto.Keccak256Hash(a[:], b[:]) }
+	if want := pair(pair(leaves[0], leaves[1]), pair(leaves[2], leaves[3])); tree.Root() != want {
+		t.Fatalf("root = %s, want %s", tree.Root().Hex(), want.Hex())
+	}
+	if merkle.NewTree(txs).Root() == tree.Root() {
+		t.Fatal("RLP leaves gave the same root as transaction hashes")
+	}
+
+	for i, tx := range txs {
+		proof := tree.GenerateProof(uint(i))
+		if !tree.VerifyTxProof(tx, uint(i), proof) {
+			t.Errorf("VerifyTxProof rejected transaction %d", i)
+		}
+		if tree.VerifyProof(tx.Hash(), uint(i), proof) {
+			t.Errorf("VerifyProof accepted the transaction hash of %d as an RLP leaf", i)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// AuditRange proves and verifies every custom transaction mined in blocks
+// [from, to], building each block's Merkle tree once. Non-custom
+// transactions are skipped. A block or transaction that cannot be fetched,
+// proved or verified is left out and reported in the returned error, which
+// joins one error per failure; the proofs that did verify are returned
+// alongside it.
+func (m *Manager) AuditRange(ctx context.Context, from, to *big.Int) ([]*Proof, error) {
+	if from == nil || to == nil {
+		return nil, fmt.Errorf("block range bounds must not be nil")
+	}
+	if from.Cmp(to) > 0 {
+		return nil, fmt.Errorf("invalid block range: from %s is after to %s", from, to)
+	}
+
+	var proofs []*Proof
+	var errs []error
+	for number := new(big.Int).Set(from); number.Cmp(to) <= 0; number.Add(number, big.NewInt(1)) {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		block, err := m.getBlockByNumber(ctx, number)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("block %s: %w", number, err))
+			continue
+		}
+		audited, blockErrs := m.auditBlock(ctx, block)
+		proofs = append(proofs, audited...)
+		errs = append(errs, blockErrs...)
+	}
+
+	return proofs, errors.Join(errs...)
+}
+
+// auditBlock proves and verifies the custom transactions of block
+func (m *Manager) auditBlock(ctx context.Context, block *types.Block) ([]*Proof, []error) {
+	txs := m.customTransactions(block)
+	if len(txs) == 0 {
+		return nil, nil
+	}
+
+	tree, err := m.getMerkleTree(ctx, block.Hash(), ProofOptions{})
+	if err != nil {
+		return nil, []error{fmt.Errorf("block %d: failed to get merkle tree: %w", block.NumberU64(), err)}
+	}
+
+	var proofs []*Proof
+	var errs []error
+	for _, tx := range txs {
+		proof, err := m.blockProof(ctx, block.Hash(), tx, tree)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("transaction %s: %w", tx.Hash().Hex(), err))
+			continue
+		}
+
+		result, err := m.verifyProof(ctx, proof, ProofOptions{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("transaction %s: %w", tx.Hash().Hex(), err))
+			continue
+		}
+		if !result.Valid() {
+			errs = append(errs, fmt.Errorf("transaction %s: %w", tx.Hash().Hex(), result.failure))
+			continue
+		}
+		proofs = append(proofs, proof)
+	}
+	return proofs, errs
+}
@@ -0,0 +1,66 @@
+package transaction_test
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/k4rz4/ethereum-custom-transactions/internal/ethtest"
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+var testRecipient = common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb")
+
+// newTestManager starts a mock node and a manager connected to it
+func newTestManager(t testing.TB, opts ...transaction.Option) (*ethtest.Backend, *transaction.Manager) {
+	t.Helper()
+
+	backend := ethtest.NewBackend(t)
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+
+	mgr, err := transaction.NewManager(backend.URL, common.Bytes2Hex(crypto.FromECDSA(key)), 2, opts...)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+
+	return backend, mgr
+}
+
+// signedTx builds and signs a transaction, custom if customData is non-nil
+func signedTx(t testing.TB, backend *ethtest.Backend, key *ecdsa.PrivateKey, nonce uint64, customData []byte) *types.Transaction {
+	t.Helper()
+
+	var tx *types.Transaction
+	if customData != nil {
+		tx = transaction.NewCustomTransaction(
+			backend.ChainID(), nonce, &testRecipient,
+			big.NewInt(0), 100000, big.NewInt(1e9), big.NewInt(3e9),
+			[]byte{}, customData,
+		)
+	} else {
+		tx = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   backend.ChainID(),
+			Nonce:     nonce,
+			GasTipCap: big.NewInt(1e9),
+			GasFeeCap: big.NewInt(3e9),
+			Gas:       21000,
+			To:        &testRecipient,
+			Value:     big.NewInt(0),
+		})
+	}
+
+	signed, err := types.SignTx(tx, backend.Signer(), key)
+	if err != nil {
+		t.Fatalf("SignTx failed: %v", err)
+	}
+	return signed
+}
@@ -0,0 +1,123 @@
+package transaction
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// ProofBundle is a proof together with everything needed to verify it
+// without a node: the block header and the transaction's Merkle-Patricia
+// proof against the header's transaction root
+type ProofBundle struct {
+	Proof  *Proof
+	Header *types.Header
+	// TxProof holds the trie nodes on the path from Header.TxHash to the
+	// transaction at Proof.TransactionIndex
+	TxProof [][]byte
+}
+
+// GenerateProofBundle generates the proof of txHash and bundles it with its
+// block header and transaction trie proof for VerifyProofBundle
+func (m *Manager) GenerateProofBundle(ctx context.Context, txHash common.Hash) (*ProofBundle, error) {
+	proof, err := m.GenerateProofWithContext(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := m.getBlock(ctx, proof.BlockHash, ProofOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block: %w", err)
+	}
+
+	txProof, err := proveTransaction(block.Transactions(), proof.TransactionIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProofBundle{
+		Proof:   proof,
+		Header:  block.Header(),
+		TxProof: txProof,
+	}, nil
+}
+
+// proveTransaction rebuilds the transaction trie of txs, as committed to by
+// a block header's TxHash, and returns the proof nodes for txs[index]
+func proveTransaction(txs types.Transactions, index uint) ([][]byte, error) {
+	if index >= uint(len(txs)) {
+		return nil, fmt.Errorf("transaction index %d out of range (block has %d transactions)", index, len(txs))
+	}
+
+	tr := trie.NewEmpty(triedb.NewDatabase(rawdb.NewMemoryDatabase(), nil))
+	for i, tx := range txs {
+		encoded, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode transaction %d: %w", i, err)
+		}
+		if err := tr.Update(rlp.AppendUint64(nil, uint64(i)), encoded); err != nil {
+			return nil, fmt.Errorf("failed to build transaction trie: %w", err)
+		}
+	}
+
+	nodes := trienode.NewProofSet()
+	if err := tr.Prove(rlp.AppendUint64(nil, uint64(index)), nodes); err != nil {
+		return nil, fmt.Errorf("failed to prove transaction %d: %w", index, err)
+	}
+	return nodes.List(), nil
+}
+
+// VerifyProofBundle checks a bundle without network access: the header must
+// hash to the proof's block, the transaction must sit at the proof's index
+// in the header's transaction trie, and the receipt and custom data must
+// match the transaction. It returns false with the first failed check.
+func VerifyProofBundle(bundle *ProofBundle) (bool, error) {
+	if bundle == nil || bundle.Proof == nil || bundle.Header == nil {
+		return false, fmt.Errorf("bundle is missing its proof or header")
+	}
+	proof := bundle.Proof
+	if proof.Transaction == nil || proof.Receipt == nil {
+		return false, fmt.Errorf("proof is missing its transaction or receipt")
+	}
+
+	if hash := bundle.Header.Hash(); hash != proof.BlockHash {
+		return false, fmt.Errorf("header hash %s does not match proof block %s", hash.Hex(), proof.BlockHash.Hex())
+	}
+	if proof.BlockNumber != nil && bundle.Header.Number.Cmp(proof.BlockNumber) != 0 {
+		return false, fmt.Errorf("header number %v does not match proof block number %v", bundle.Header.Number, proof.BlockNumber)
+	}
+
+	nodes := trienode.NewProofSet()
+	for _, node := range bundle.TxProof {
+		nodes.Put(crypto.Keccak256(node), node)
+	}
+	key := rlp.AppendUint64(nil, uint64(proof.TransactionIndex))
+	value, err := trie.VerifyProof(bundle.Header.TxHash, key, nodes)
+	if err != nil {
+		return false, fmt.Errorf("transaction trie proof verification failed: %w", err)
+	}
+	encoded, err := proof.Transaction.MarshalBinary()
+	if err != nil {
+		return false, fmt.Errorf("failed to encode transaction: %w", err)
+	}
+	if value == nil || !bytes.Equal(value, encoded) {
+		return false, fmt.Errorf("transaction not found at index %d of the header's transaction trie", proof.TransactionIndex)
+	}
+
+	if proof.Receipt.TxHash != proof.Transaction.Hash() {
+		return false, fmt.Errorf("receipt transaction hash mismatch")
+	}
+	if err := checkCustomData(proof); err != nil {
+		return false, err
+	}
+	return true, nil
+}
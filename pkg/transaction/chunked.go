@@ -0,0 +1,138 @@
+package transaction
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ChunkHeaderSize is the size of the header prefixed to each chunk's custom
+// data: group id (32) | total chunks (4) | chunk index (4)
+const ChunkHeaderSize = common.HashLength + 4 + 4
+
+// SendChunked splits customData into chunks of at most chunkSize bytes and
+// sends one custom transaction per chunk, in order. Each chunk carries a
+// header with the group id (the Keccak256 of customData), the number of
+// chunks and its index. value and data are sent with the first chunk only;
+// later chunks carry neither. The hashes of the sent transactions are
+// returned, including those sent before an error.
+func (m *Manager) SendChunked(
+	ctx context.Context,
+	to common.Address,
+	value *big.Int,
+	customData, data []byte,
+	chunkSize int,
+) ([]common.Hash, error) {
+	if chunkSize < 1 {
+		return nil, fmt.Errorf("chunk size must be positive, got %d", chunkSize)
+	}
+
+	total := (len(customData) + chunkSize - 1) / chunkSize
+	if total == 0 {
+		// Empty custom data is still sent, as a single empty chunk
+		total = 1
+	}
+	groupID := crypto.Keccak256Hash(customData)
+
+	hashes := make([]common.Hash, 0, total)
+	for index := 0; index < total; index++ {
+		start := index * chunkSize
+		end := start + chunkSize
+		if end > len(customData) {
+			end = len(customData)
+		}
+
+		chunk := make([]byte, ChunkHeaderSize, ChunkHeaderSize+end-start)
+		copy(chunk, groupID[:])
+		binary.BigEndian.PutUint32(chunk[common.HashLength:], uint32(total))
+		binary.BigEndian.PutUint32(chunk[common.HashLength+4:], uint32(index))
+		chunk = append(chunk, customData[start:end]...)
+
+		chunkValue, chunkData := value, data
+		if index > 0 {
+			chunkValue, chunkData = nil, nil
+		}
+
+		tx, err := m.SendWithContext(ctx, to, chunkValue, chunk, chunkData)
+		if err != nil {
+			return hashes, fmt.Errorf("failed to send chunk %d of %d: %w", index, total, err)
+		}
+		hashes = append(hashes, tx.Hash())
+	}
+
+	return hashes, nil
+}
+
+// ReassembleChunks rebuilds the custom data split by SendChunked from its
+// transactions, in any order. Every chunk of the group must be present
+// exactly once, and the result must hash to the group id.
+func ReassembleChunks(txs []*types.Transaction) ([]byte, error) {
+	if len(txs) == 0 {
+		return nil, fmt.Errorf("no chunks")
+	}
+
+	type chunk struct {
+		index   uint32
+		payload []byte
+	}
+
+	var (
+		groupID common.Hash
+		total   uint32
+		chunks  []chunk
+	)
+	seen := make(map[uint32]bool)
+
+	for i, tx := range txs {
+		customData, err := GetCustomData(tx)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: failed to extract custom data: %w", i, err)
+		}
+		if len(customData) < ChunkHeaderSize {
+			return nil, fmt.Errorf("chunk %d: custom data too short for a chunk header", i)
+		}
+
+		id := common.BytesToHash(customData[:common.HashLength])
+		n := binary.BigEndian.Uint32(customData[common.HashLength:])
+		index := binary.BigEndian.Uint32(customData[common.HashLength+4:])
+
+		if i == 0 {
+			groupID, total = id, n
+		}
+		switch {
+		case id != groupID:
+			return nil, fmt.Errorf("chunk %d: group %s, want %s", i, id.Hex(), groupID.Hex())
+		case n != total:
+			return nil, fmt.Errorf("chunk %d: total %d, want %d", i, n, total)
+		case index >= total:
+			return nil, fmt.Errorf("chunk %d: index %d out of range for %d chunks", i, index, total)
+		case seen[index]:
+			return nil, fmt.Errorf("chunk %d: duplicate index %d", i, index)
+		}
+		seen[index] = true
+
+		chunks = append(chunks, chunk{index: index, payload: customData[ChunkHeaderSize:]})
+	}
+
+	if uint32(len(chunks)) != total {
+		return nil, fmt.Errorf("have %d of %d chunks", len(chunks), total)
+	}
+
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].index < chunks[j].index })
+
+	var customData []byte
+	for _, c := range chunks {
+		customData = append(customData, c.payload...)
+	}
+
+	if hash := crypto.Keccak256Hash(customData); hash != groupID {
+		return nil, fmt.Errorf("reassembled data hashes to %s, want group %s", hash.Hex(), groupID.Hex())
+	}
+	return customData, nil
+}
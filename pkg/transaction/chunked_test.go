@@ -0,0 +1,53 @@
+package transaction_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+func TestSendChunked(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	payload := bytes.Repeat([]byte("0123456789"), 25)
+	hashes, err := mgr.SendChunked(ctx, testRecipient, nil, payload, nil, 100)
+	if err != nil {
+		t.Fatalf("SendChunked failed: %v", err)
+	}
+	if len(hashes) != 3 {
+		t.Fatalf("sent %d transactions, want 3", len(hashes))
+	}
+
+	block := backend.Mine()
+	txs := block.Transactions()
+	if len(txs) != 3 {
+		t.Fatalf("mined %d transactions, want 3", len(txs))
+	}
+	for i, tx := range txs {
+		if tx.Hash() != hashes[i] {
+			t.Errorf("transaction %d is %s, want %s", i, tx.Hash().Hex(), hashes[i].Hex())
+		}
+	}
+
+	// Chunks may be collected in any order
+	shuffled := []*types.Transaction{txs[2], txs[0], txs[1]}
+	reassembled, err := transaction.ReassembleChunks(shuffled)
+	if err != nil {
+		t.Fatalf("ReassembleChunks failed: %v", err)
+	}
+	if !bytes.Equal(reassembled, payload) {
+		t.Error("reassembled data does not match the payload")
+	}
+
+	if _, err := transaction.ReassembleChunks(txs[:2]); err == nil {
+		t.Error("expected an error with a chunk missing")
+	}
+	if _, err := transaction.ReassembleChunks([]*types.Transaction{txs[0], txs[0], txs[1]}); err == nil {
+		t.Error("expected an error with a duplicate chunk")
+	}
+}
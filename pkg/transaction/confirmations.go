@@ -0,0 +1,130 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// WatchConfirmations emits the confirmation count of txHash (1 once it is
+// mined, 2 a block later and so on) each time it changes, and closes the
+// channel once it reaches target or ctx is done. New heads come from a
+// newHeads subscription, or from polling every poll interval if the node
+// connection does not support subscriptions. A reorg that unmines the
+// transaction can make the count drop.
+func (m *Manager) WatchConfirmations(ctx context.Context, txHash common.Hash, target uint64) (<-chan uint64, error) {
+	if target == 0 {
+		return nil, fmt.Errorf("target must be at least 1 confirmation")
+	}
+
+	heads := make(chan *types.Header, 1)
+	sub, err := m.clientPool.Get().SubscribeNewHead(ctx, heads)
+	if err != nil {
+		// Subscriptions are unsupported, e.g. over HTTP; poll instead
+		sub = nil
+	}
+
+	counts := make(chan uint64)
+	go m.watchConfirmations(ctx, txHash, target, sub, heads, counts)
+	return counts, nil
+}
+
+// watchConfirmations runs WatchConfirmations, driven by sub's heads or by
+// polling when sub is nil or fails
+func (m *Manager) watchConfirmations(
+	ctx context.Context,
+	txHash common.Hash,
+	target uint64,
+	sub ethereum.Subscription,
+	heads <-chan *types.Header,
+	counts chan<- uint64,
+) {
+	defer close(counts)
+
+	var subErr <-chan error
+	var ticks <-chan time.Time
+	var ticker *time.Ticker
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+	poll := func() {
+		ticker = time.NewTicker(m.pollInterval)
+		ticks = ticker.C
+	}
+	if sub != nil {
+		defer func() {
+			if sub != nil {
+				sub.Unsubscribe()
+			}
+		}()
+		subErr = sub.Err()
+	} else {
+		poll()
+	}
+
+	var last uint64
+	// check emits the count at head if it changed and reports whether to
+	// keep watching
+	check := func(head uint64) bool {
+		count, err := m.confirmations(ctx, txHash, head)
+		if err != nil || count == last {
+			return true
+		}
+		last = count
+		select {
+		case counts <- count:
+		case <-ctx.Done():
+			return false
+		}
+		return count < target
+	}
+
+	if head, err := m.BlockNumber(ctx); err == nil && !check(head) {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case header := <-heads:
+			if !check(header.Number.Uint64()) {
+				return
+			}
+		case <-subErr:
+			// The subscription dropped; poll from now on
+			sub.Unsubscribe()
+			sub, subErr = nil, nil
+			poll()
+		case <-ticks:
+			if head, err := m.BlockNumber(ctx); err == nil && !check(head) {
+				return
+			}
+		}
+	}
+}
+
+// confirmations returns how many blocks up to head include txHash or build
+// on the block that does, or 0 if it is not mined
+func (m *Manager) confirmations(ctx context.Context, txHash common.Hash, head uint64) (uint64, error) {
+	receipt, err := m.clientPool.Get().TransactionReceipt(ctx, txHash)
+	if errors.Is(err, ethereum.NotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	m.ledger.observe(receipt)
+
+	mined := receipt.BlockNumber.Uint64()
+	if head < mined {
+		return 0, nil
+	}
+	return head - mined + 1, nil
+}
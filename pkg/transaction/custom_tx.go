@@ -8,6 +8,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 // MagicBytes is a unique identifier for custom transactions
@@ -62,30 +63,28 @@ func EncodeCustomData(standardData, customData []byte) []byte {
 }
 
 func DecodeCustomData(encodedData []byte) (customData, standardData []byte, err error) {
+	customData, standardData, _, err = DecodeCustomDataWithExpiry(encodedData)
+	return customData, standardData, err
+}
+
+// DecodeCustomDataWithExpiry is DecodeCustomData that also returns the
+// payload's unix-seconds expiry, 0 if it has none
+func DecodeCustomDataWithExpiry(encodedData []byte) (customData, standardData []byte, expiry uint64, err error) {
 	minLength := len(MagicBytes) + 4
 	if len(encodedData) < minLength {
-		return nil, encodedData, nil
+		return nil, encodedData, 0, nil
 	}
 
 	if !bytes.Equal(encodedData[:len(MagicBytes)], MagicBytes) {
-		return nil, encodedData, nil
+		return nil, encodedData, 0, nil
 	}
 
-	length := binary.BigEndian.Uint32(encodedData[len(MagicBytes) : len(MagicBytes)+4])
-	offset := len(MagicBytes) + 4
-
-	if uint32(len(encodedData)) < uint32(offset)+length {
-		return nil, nil, fmt.Errorf(
-			"invalid custom data encoding: declared length %d exceeds available data",
-			length,
-		)
+	env, err := DecodeEnvelope(encodedData)
+	if err != nil {
+		return nil, nil, 0, err
 	}
 
-	customData = encodedData[offset : offset+int(length)]
-
-	standardData = encodedData[offset+int(length):]
-
-	return customData, standardData, nil
+	return env.CustomData, env.StandardData, env.Expiry, nil
 }
 
 func GetCustomData(tx *types.Transaction) ([]byte, error) {
@@ -93,10 +92,154 @@ func GetCustomData(tx *types.Transaction) ([]byte, error) {
 	return customData, err
 }
 
+// DecodeCustomDataFromLog extracts custom data that a contract re-emitted in
+// log, so calldata can be cross-checked against events. The encoding may sit
+// anywhere in log.Data, e.g. inside ABI-encoded bytes; the first occurrence
+// of MagicBytes that decodes is used. Bytes after the custom data, such as
+// ABI padding, are ignored.
+func DecodeCustomDataFromLog(log *types.Log) ([]byte, error) {
+	if log == nil {
+		return nil, fmt.Errorf("log is nil")
+	}
+
+	data := log.Data
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], MagicBytes)
+		if i < 0 {
+			return nil, ErrNotCustomData
+		}
+		offset += i
+
+		if env, err := DecodeEnvelope(data[offset:]); err == nil {
+			return env.CustomData, nil
+		}
+		offset++
+	}
+}
+
+// ReplaceCustomData returns an unsigned copy of tx carrying newCustomData in
+// place of its current custom data. The standard data and envelope options
+// are kept, as are the nonce, gas, fees, recipient and value. Padded custom
+// data is padded to a multiple of the old padded length, since the original
+// block size is not recorded. A transaction without custom data gains it.
+// Signed transactions are rejected, since the signature would no longer
+// cover the data, as is dictionary-compressed custom data, with
+// ErrDictionaryRequired.
+func ReplaceCustomData(tx *types.Transaction, newCustomData []byte) (*types.Transaction, error) {
+	if v, r, s := tx.RawSignatureValues(); v.Sign() != 0 || r.Sign() != 0 || s.Sign() != 0 {
+		return nil, fmt.Errorf("transaction is already signed")
+	}
+
+	var data []byte
+	if IsCustomTransaction(tx) {
+		env, err := decodeEnvelope(tx.Data())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode custom data: %w", err)
+		}
+		if env.Flags&FlagDictionary != 0 {
+			return nil, fmt.Errorf("%w: custom data uses dictionary %08x, which cannot be re-encoded", ErrDictionaryRequired, env.DictionaryID)
+		}
+		if env.Version == FormatLegacy {
+			data = EncodeCustomData(env.StandardData, newCustomData)
+		} else {
+			data = EncodeCustomDataWithOptions(env.StandardData, newCustomData, EncodeOptions{
+				Expiry:       env.Expiry,
+				SchemaID:     env.SchemaID,
+				LittleEndian: env.Flags&FlagLittleEndian != 0,
+				Checksum:     env.Flags&FlagChecksum != 0,
+				PadTo:        env.paddedLength,
+			})
+		}
+	} else {
+		data = EncodeCustomData(tx.Data(), newCustomData)
+	}
+
+	switch tx.Type() {
+	case types.DynamicFeeTxType:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  tx.GasTipCap(),
+			GasFeeCap:  tx.GasFeeCap(),
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       data,
+			AccessList: tx.AccessList(),
+		}), nil
+	case types.AccessListTxType:
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasPrice:   tx.GasPrice(),
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       data,
+			AccessList: tx.AccessList(),
+		}), nil
+	case types.LegacyTxType:
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: tx.GasPrice(),
+			Gas:      tx.Gas(),
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     data,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %d", tx.Type())
+	}
+}
+
+// CustomDataHash returns the Keccak256 of the decoded custom data segment.
+// Unlike tx.Hash() it does not depend on the nonce, fees or signature, so it
+// identifies the payload itself. Non-custom or malformed transactions hash to
+// the zero hash.
+func CustomDataHash(tx *types.Transaction) common.Hash {
+	if !IsCustomTransaction(tx) {
+		return common.Hash{}
+	}
+
+	customData, err := GetCustomData(tx)
+	if err != nil {
+		return common.Hash{}
+	}
+
+	return crypto.Keccak256Hash(customData)
+}
+
+// IsCustomTransaction reports whether tx's calldata starts with MagicBytes.
+// tx.Data() returns the calldata without copying it, so this allocates
+// nothing and reads only the first four bytes.
 func IsCustomTransaction(tx *types.Transaction) bool {
-	data := tx.Data()
-	if len(data) < len(MagicBytes) {
+	return IsCustomData(tx.Data())
+}
+
+// IsCustomTransactionStrict reports whether tx's calldata is a well-formed
+// custom encoding, not merely one starting with MagicBytes: the format
+// version must be known, the header complete, the declared length must fit
+// the calldata and, for payloads encoded with EncodeOptions.Checksum, the
+// checksum must match. Ordinary calldata that happens to start with
+// MagicBytes rarely passes, and with a checksum only by a 2^-32 chance.
+func IsCustomTransactionStrict(tx *types.Transaction) bool {
+	return IsCustomDataStrict(tx.Data())
+}
+
+// IsCustomDataStrict is IsCustomTransactionStrict for raw calldata
+func IsCustomDataStrict(data []byte) bool {
+	if !IsCustomData(data) {
 		return false
 	}
-	return bytes.Equal(data[:len(MagicBytes)], MagicBytes)
+	_, err := decodeEnvelope(data)
+	return err == nil
+}
+
+// magicWord is MagicBytes read big-endian, for single-comparison checks
+var magicWord = binary.BigEndian.Uint32(MagicBytes)
+
+// IsCustomData reports whether calldata starts with MagicBytes, for callers
+// that already hold raw calldata, e.g. from logs or RPC responses
+func IsCustomData(data []byte) bool {
+	return len(data) >= len(MagicBytes) && binary.BigEndian.Uint32(data) == magicWord
 }
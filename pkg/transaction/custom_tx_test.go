@@ -2,10 +2,14 @@ package transaction_test
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"errors"
 	"math/big"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
 )
 
@@ -61,7 +65,284 @@ func TestEncodeDecodeCustomData(t *testing.T) {
 	}
 }
 
+func TestCustomDataHash(t *testing.T) {
+	to := addrPtr("0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb")
+	newTx := func(nonce uint64, customData []byte) *types.Transaction {
+		return transaction.NewCustomTransaction(
+			big.NewInt(1), nonce, to, big.NewInt(0), 21000,
+			big.NewInt(1000000000), big.NewInt(2000000000), []byte{0x01}, customData,
+		)
+	}
+
+	first := newTx(0, []byte("payload"))
+	second := newTx(7, []byte("payload"))
+
+	if first.Hash() == second.Hash() {
+		t.Fatal("transactions with different nonces should have different hashes")
+	}
+	if transaction.CustomDataHash(first) != transaction.CustomDataHash(second) {
+		t.Error("identical custom data should hash identically across nonces")
+	}
+	if got, want := transaction.CustomDataHash(first), crypto.Keccak256Hash([]byte("payload")); got != want {
+		t.Errorf("CustomDataHash = %s, want %s", got.Hex(), want.Hex())
+	}
+	if transaction.CustomDataHash(newTx(0, []byte("other"))) == transaction.CustomDataHash(first) {
+		t.Error("different custom data should hash differently")
+	}
+}
+
 func addrPtr(hex string) *common.Address {
 	addr := common.HexToAddress(hex)
 	return &addr
 }
+
+func TestReplaceCustomData(t *testing.T) {
+	to := addrPtr("0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb")
+	tx := transaction.NewCustomTransaction(
+		big.NewInt(1), 9, to, big.NewInt(5), 50000,
+		big.NewInt(1000000000), big.NewInt(2000000000), []byte{0x01, 0x02}, []byte("draft"),
+	)
+
+	replaced, err := transaction.ReplaceCustomData(tx, []byte("final payload"))
+	if err != nil {
+		t.Fatalf("ReplaceCustomData failed: %v", err)
+	}
+
+	custom, standard, err := transaction.DecodeCustomData(replaced.Data())
+	if err != nil || string(custom) != "final payload" || !bytes.Equal(standard, []byte{0x01, 0x02}) {
+		t.Errorf("decoded %q, %v, %v; want the new payload and the original standard data", custom, standard, err)
+	}
+	if replaced.Nonce() != tx.Nonce() || replaced.Gas() != tx.Gas() || *replaced.To() != *tx.To() ||
+		replaced.Value().Cmp(tx.Value()) != 0 || replaced.GasTipCap().Cmp(tx.GasTipCap()) != 0 ||
+		replaced.GasFeeCap().Cmp(tx.GasFeeCap()) != 0 || replaced.ChainId().Cmp(tx.ChainId()) != 0 ||
+		replaced.Type() != tx.Type() {
+		t.Error("fields other than the custom data changed")
+	}
+
+	signed, err := types.SignTx(replaced, types.NewLondonSigner(big.NewInt(1)), mustKey(t))
+	if err != nil {
+		t.Fatalf("SignTx failed: %v", err)
+	}
+	if _, err := transaction.ReplaceCustomData(signed, []byte("late")); err == nil {
+		t.Error("expected an error replacing custom data of a signed transaction")
+	}
+}
+
+func TestReplaceCustomDataKeepsEnvelopeOptions(t *testing.T) {
+	opts := transaction.EncodeOptions{Expiry: 1_700_000_000, SchemaID: 3, LittleEndian: true}
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID: big.NewInt(1),
+		Data:    transaction.EncodeCustomDataWithOptions(nil, []byte("draft"), opts),
+	})
+
+	replaced, err := transaction.ReplaceCustomData(tx, []byte("final"))
+	if err != nil {
+		t.Fatalf("ReplaceCustomData failed: %v", err)
+	}
+
+	env, err := transaction.DecodeEnvelope(replaced.Data())
+	if err != nil {
+		t.Fatalf("DecodeEnvelope failed: %v", err)
+	}
+	if env.Expiry != opts.Expiry || env.SchemaID != opts.SchemaID ||
+		env.Flags&transaction.FlagLittleEndian == 0 || string(env.CustomData) != "final" {
+		t.Errorf("envelope options not preserved: %+v", env)
+	}
+}
+
+func TestReplaceCustomDataPadded(t *testing.T) {
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID: big.NewInt(1),
+		Data:    transaction.EncodeCustomDataPadded([]byte{0x01}, []byte("draft"), 32),
+	})
+
+	replaced, err := transaction.ReplaceCustomData(tx, []byte("final version"))
+	if err != nil {
+		t.Fatalf("ReplaceCustomData failed: %v", err)
+	}
+
+	env, err := transaction.DecodeEnvelope(replaced.Data())
+	if err != nil {
+		t.Fatalf("DecodeEnvelope failed: %v", err)
+	}
+	if env.Flags&transaction.FlagPadded == 0 || string(env.CustomData) != "final version" || !bytes.Equal(env.StandardData, []byte{0x01}) {
+		t.Errorf("padded envelope not preserved: %+v", env)
+	}
+	if len(replaced.Data()) != len(tx.Data()) {
+		t.Errorf("replaced calldata is %d bytes, want the same padded size %d", len(replaced.Data()), len(tx.Data()))
+	}
+}
+
+func TestReplaceCustomDataDictionary(t *testing.T) {
+	dict := []byte("a shared dictionary of common payload words")
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID: big.NewInt(1),
+		Data:    transaction.EncodeCustomDataWithDict(nil, []byte("common payload"), dict),
+	})
+
+	if _, err := transaction.ReplaceCustomData(tx, []byte("final")); !errors.Is(err, transaction.ErrDictionaryRequired) {
+		t.Errorf("ReplaceCustomData on dictionary data error = %v, want ErrDictionaryRequired", err)
+	}
+}
+
+func mustKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	return key
+}
+
+func TestCustomDataWriter(t *testing.T) {
+	standard := []byte{0xa9, 0x05, 0x9c, 0xbb}
+	chunks := [][]byte{[]byte("first chunk, "), {}, []byte("second, "), bytes.Repeat([]byte{0x42}, 1000)}
+
+	w, stream := transaction.NewCustomDataWriter(standard)
+	var all []byte
+	for _, chunk := range chunks {
+		if _, err := stream.Write(chunk); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		all = append(all, chunk...)
+	}
+
+	encoded := w.Finalize()
+	if want := transaction.EncodeCustomData(standard, all); !bytes.Equal(encoded, want) {
+		t.Fatalf("Finalize() does not match EncodeCustomData of the concatenated chunks")
+	}
+
+	if _, err := stream.Write([]byte("late")); err == nil {
+		t.Error("expected Write after Finalize to fail")
+	}
+	if again := w.Finalize(); !bytes.Equal(again, encoded) {
+		t.Error("second Finalize changed the encoding")
+	}
+}
+
+func TestDecodeCustomDataFromLog(t *testing.T) {
+	payload := []byte("emitted payload")
+	encoded := transaction.EncodeCustomData(nil, payload)
+
+	// ABI encoding of a single bytes argument: offset, length, padded data
+	data := make([]byte, 64)
+	data[31] = 0x20
+	data[63] = byte(len(encoded))
+	data = append(data, encoded...)
+	data = append(data, make([]byte, 32-len(encoded)%32)...)
+
+	got, err := transaction.DecodeCustomDataFromLog(&types.Log{Data: data})
+	if err != nil {
+		t.Fatalf("DecodeCustomDataFromLog failed: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("custom data = %q, want %q", got, payload)
+	}
+
+	if _, err := transaction.DecodeCustomDataFromLog(&types.Log{Data: make([]byte, 64)}); !errors.Is(err, transaction.ErrNotCustomData) {
+		t.Errorf("log without custom data error = %v, want ErrNotCustomData", err)
+	}
+}
+
+func TestIsCustomData(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want bool
+	}{
+		{nil, false},
+		{[]byte{0xCA, 0xFE, 0xDA}, false},
+		{[]byte{0xCA, 0xFE, 0xDA, 0x7A}, true},
+		{transaction.EncodeCustomData([]byte{0x01}, []byte("x")), true},
+		{[]byte{0xa9, 0x05, 0x9c, 0xbb, 0xCA, 0xFE, 0xDA, 0x7A}, false},
+	}
+	for _, tt := range tests {
+		if got := transaction.IsCustomData(tt.data); got != tt.want {
+			t.Errorf("IsCustomData(%x) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+// scanTxs returns n transactions, every other one custom
+func scanTxs(n int) []*types.Transaction {
+	to := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb")
+	txs := make([]*types.Transaction, n)
+	for i := range txs {
+		data := bytes.Repeat([]byte{byte(i)}, 256)
+		if i%2 == 0 {
+			data = transaction.EncodeCustomData(data, []byte("payload"))
+		}
+		txs[i] = types.NewTx(&types.DynamicFeeTx{Nonce: uint64(i), To: &to, Data: data})
+	}
+	return txs
+}
+
+// isCustomBytesEqual is the original IsCustomTransaction, kept as the
+// benchmark baseline
+func isCustomBytesEqual(tx *types.Transaction) bool {
+	data := tx.Data()
+	if len(data) < len(transaction.MagicBytes) {
+		return false
+	}
+	return bytes.Equal(data[:len(transaction.MagicBytes)], transaction.MagicBytes)
+}
+
+func BenchmarkIsCustomTransaction(b *testing.B) {
+	txs := scanTxs(100_000)
+
+	for _, bm := range []struct {
+		name string
+		fn   func(*types.Transaction) bool
+	}{
+		{"bytes-equal", isCustomBytesEqual},
+		{"word-compare", transaction.IsCustomTransaction},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				custom := 0
+				for _, tx := range txs {
+					if bm.fn(tx) {
+						custom++
+					}
+				}
+				if custom != len(txs)/2 {
+					b.Fatalf("found %d custom transactions, want %d", custom, len(txs)/2)
+				}
+			}
+		})
+	}
+}
+
+func TestIsCustomTransactionStrict(t *testing.T) {
+	magic := transaction.MagicBytes
+	checksummed := transaction.EncodeCustomDataWithOptions([]byte{0x01}, []byte("summed"), transaction.EncodeOptions{Checksum: true})
+	corrupted := bytes.Clone(checksummed)
+	corrupted[len(corrupted)-2] ^= 0xff
+
+	tests := []struct {
+		name   string
+		data   []byte
+		strict bool
+	}{
+		{"legacy encoding", transaction.EncodeCustomData([]byte{0x01}, []byte("x")), true},
+		{"v1 encoding", transaction.EncodeCustomDataWithOptions(nil, []byte("x"), transaction.EncodeOptions{SchemaID: 7}), true},
+		{"checksummed encoding", checksummed, true},
+		{"length past the calldata", append(bytes.Clone(magic), 0x00, 0xff, 0xff, 0xff, 0x01, 0x02), false},
+		{"unknown version", append(bytes.Clone(magic), 0x09, 0x00, 0x00, 0x00, 0x00), false},
+		{"truncated header", append(bytes.Clone(magic), transaction.FormatV1, transaction.FlagExpiry, 0x01), false},
+		{"checksum mismatch", corrupted, false},
+	}
+	for _, tt := range tests {
+		tx := types.NewTx(&types.DynamicFeeTx{Data: tt.data})
+		if !transaction.IsCustomTransaction(tx) {
+			t.Errorf("%s: IsCustomTransaction = false, want the loose check to pass", tt.name)
+		}
+		if got := transaction.IsCustomTransactionStrict(tx); got != tt.strict {
+			t.Errorf("%s: IsCustomTransactionStrict = %v, want %v", tt.name, got, tt.strict)
+		}
+	}
+
+	if _, _, err := transaction.DecodeCustomData(corrupted); !errors.Is(err, transaction.ErrChecksumMismatch) {
+		t.Errorf("DecodeCustomData of corrupted data error = %v, want ErrChecksumMismatch", err)
+	}
+}
@@ -0,0 +1,62 @@
+package transaction
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+var errWriterFinalized = errors.New("custom data writer is finalized")
+
+// CustomDataWriter streams custom data into the EncodeCustomData format.
+// Chunks are appended directly after a reserved header, so the encoding is
+// built without first assembling the custom data separately.
+type CustomDataWriter struct {
+	buf          []byte
+	standardData []byte
+	finalized    bool
+}
+
+// NewCustomDataWriter returns a writer for custom data to be encoded
+// together with standardData. The io.Writer is the same writer, for callers
+// that only need to stream into it.
+func NewCustomDataWriter(standardData []byte) (*CustomDataWriter, io.Writer) {
+	headerLen := len(MagicBytes) + 4
+	w := &CustomDataWriter{
+		buf:          make([]byte, headerLen, headerLen+len(standardData)),
+		standardData: standardData,
+	}
+	copy(w.buf, MagicBytes)
+	return w, w
+}
+
+// Write appends p to the custom data
+func (w *CustomDataWriter) Write(p []byte) (int, error) {
+	if w.finalized {
+		return 0, errWriterFinalized
+	}
+	if uint64(w.Len())+uint64(len(p)) > math.MaxUint32 {
+		return 0, errors.New("custom data exceeds the 4-byte length limit")
+	}
+
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+// Len returns how many bytes of custom data have been written
+func (w *CustomDataWriter) Len() int {
+	return len(w.buf) - len(MagicBytes) - 4
+}
+
+// Finalize writes the length header, appends the standard data and returns
+// the encoding. Later writes fail; later calls to Finalize return the same
+// encoding.
+func (w *CustomDataWriter) Finalize() []byte {
+	if !w.finalized {
+		binary.BigEndian.PutUint32(w.buf[len(MagicBytes):], uint32(w.Len()))
+		w.buf = append(w.buf, w.standardData...)
+		w.finalized = true
+	}
+	return w.buf
+}
@@ -0,0 +1,99 @@
+package transaction
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// MaxDecompressedSize bounds how large dictionary-compressed custom data may
+// inflate to, so a small payload cannot exhaust memory
+const MaxDecompressedSize = 4 << 20
+
+var (
+	// ErrDictionaryRequired is returned when decoding dictionary-compressed
+	// custom data without a dictionary
+	ErrDictionaryRequired = errors.New("custom data needs a compression dictionary")
+	// ErrDictionaryMismatch is returned when the dictionary given to the
+	// decoder is not the one the custom data was compressed with
+	ErrDictionaryMismatch = errors.New("compression dictionary mismatch")
+)
+
+// DictionaryID identifies a compression dictionary in FormatV1 headers: the
+// first 4 bytes of its Keccak-256 hash, read big-endian
+func DictionaryID(dict []byte) uint32 {
+	return binary.BigEndian.Uint32(crypto.Keccak256(dict)[:4])
+}
+
+// EncodeCustomDataWithDict compresses customData with raw DEFLATE using dict
+// as the preset dictionary and encodes it in FormatV1 with the dictionary's
+// id. Decode it with DecodeCustomDataWithDict and the same dictionary.
+func EncodeCustomDataWithDict(standardData, customData, dict []byte) []byte {
+	return EncodeCustomDataWithOptions(standardData, customData, EncodeOptions{Dictionary: dict})
+}
+
+// DecodeCustomDataWithDict decodes data written by EncodeCustomDataWithDict,
+// inflating the custom data with dict. Payloads that are not compressed
+// decode as with DecodeCustomData.
+func DecodeCustomDataWithDict(encodedData, dict []byte) (customData, standardData []byte, err error) {
+	env, err := DecodeEnvelopeWithDict(encodedData, dict)
+	if err != nil {
+		return nil, nil, err
+	}
+	return env.CustomData, env.StandardData, nil
+}
+
+// DecodeEnvelopeWithDict is DecodeEnvelope for payloads whose custom data
+// may be compressed with dict
+func DecodeEnvelopeWithDict(encodedData, dict []byte) (*Envelope, error) {
+	env, err := decodeEnvelope(encodedData)
+	if err != nil {
+		return nil, err
+	}
+	if env.Flags&FlagDictionary == 0 {
+		return env, nil
+	}
+
+	if dict == nil {
+		return nil, fmt.Errorf("%w: id %08x", ErrDictionaryRequired, env.DictionaryID)
+	}
+	if id := DictionaryID(dict); id != env.DictionaryID {
+		return nil, fmt.Errorf("%w: data needs id %08x, got %08x", ErrDictionaryMismatch, env.DictionaryID, id)
+	}
+
+	env.CustomData, err = inflate(env.CustomData, dict)
+	if err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// deflate compresses data with dict as the preset dictionary
+func deflate(data, dict []byte) []byte {
+	var buf bytes.Buffer
+	// Only an invalid level fails
+	w, _ := flate.NewWriterDict(&buf, flate.BestCompression, dict)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// inflate reverses deflate, refusing output above MaxDecompressedSize
+func inflate(compressed, dict []byte) ([]byte, error) {
+	r := flate.NewReaderDict(bytes.NewReader(compressed), dict)
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress custom data: %w", err)
+	}
+	if len(data) > MaxDecompressedSize {
+		return nil, fmt.Errorf("decompressed custom data exceeds %d bytes", MaxDecompressedSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,63 @@
+package transaction
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ProofDiff describes how two proofs for the same transaction differ,
+// typically because the transaction was re-mined after a reorg
+type ProofDiff struct {
+	TxHash common.Hash
+
+	BlockHashChanged bool
+	OldBlockHash     common.Hash
+	NewBlockHash     common.Hash
+
+	IndexChanged bool
+	OldIndex     uint
+	NewIndex     uint
+
+	// ChangedLevels lists the proof path levels whose sibling hash differs,
+	// including levels present in only one of the proofs
+	ChangedLevels []int
+}
+
+// Changed reports whether the proofs differ at all
+func (d *ProofDiff) Changed() bool {
+	return d.BlockHashChanged || d.IndexChanged || len(d.ChangedLevels) > 0
+}
+
+// DiffProofs compares proof a against proof b for the same transaction
+func DiffProofs(a, b *Proof) (*ProofDiff, error) {
+	if a == nil || b == nil {
+		return nil, fmt.Errorf("proof is nil")
+	}
+	if a.Transaction == nil || b.Transaction == nil {
+		return nil, fmt.Errorf("proof has no transaction")
+	}
+	if a.Transaction.Hash() != b.Transaction.Hash() {
+		return nil, fmt.Errorf("proofs are for different transactions: %s and %s",
+			a.Transaction.Hash().Hex(), b.Transaction.Hash().Hex())
+	}
+
+	diff := &ProofDiff{
+		TxHash:           a.Transaction.Hash(),
+		BlockHashChanged: a.BlockHash != b.BlockHash,
+		OldBlockHash:     a.BlockHash,
+		NewBlockHash:     b.BlockHash,
+		IndexChanged:     a.TransactionIndex != b.TransactionIndex,
+		OldIndex:         a.TransactionIndex,
+		NewIndex:         b.TransactionIndex,
+	}
+
+	levels := max(len(a.ProofPath), len(b.ProofPath))
+	for level := 0; level < levels; level++ {
+		if level >= len(a.ProofPath) || level >= len(b.ProofPath) || a.ProofPath[level] != b.ProofPath[level] {
+			diff.ChangedLevels = append(diff.ChangedLevels, level)
+		}
+	}
+
+	return diff, nil
+}
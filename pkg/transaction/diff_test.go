@@ -0,0 +1,54 @@
+package transaction_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/k4rz4/ethereum-custom-transactions/internal/ethtest"
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+func TestDiffProofs(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	key, _ := crypto.GenerateKey()
+	tx := signedTx(t, backend, key, 0, []byte("payload"))
+
+	a := &transaction.Proof{
+		Transaction:      tx,
+		BlockHash:        common.HexToHash("0x01"),
+		TransactionIndex: 0,
+		ProofPath:        []common.Hash{common.HexToHash("0xaa"), common.HexToHash("0xbb")},
+	}
+	b := &transaction.Proof{
+		Transaction:      tx,
+		BlockHash:        common.HexToHash("0x01"),
+		TransactionIndex: 3,
+		ProofPath:        []common.Hash{common.HexToHash("0xaa"), common.HexToHash("0xcc"), common.HexToHash("0xdd")},
+	}
+
+	diff, err := transaction.DiffProofs(a, b)
+	if err != nil {
+		t.Fatalf("DiffProofs failed: %v", err)
+	}
+	if !diff.Changed() || !diff.IndexChanged || diff.OldIndex != 0 || diff.NewIndex != 3 {
+		t.Errorf("index change not captured: %+v", diff)
+	}
+	if diff.BlockHashChanged {
+		t.Error("block hash reported as changed")
+	}
+	if want := []int{1, 2}; !slices.Equal(diff.ChangedLevels, want) {
+		t.Errorf("ChangedLevels = %v, want %v", diff.ChangedLevels, want)
+	}
+
+	if same, _ := transaction.DiffProofs(a, a); same.Changed() {
+		t.Errorf("identical proofs reported as changed: %+v", same)
+	}
+
+	other := &transaction.Proof{Transaction: signedTx(t, backend, key, 1, []byte("payload"))}
+	if _, err := transaction.DiffProofs(a, other); err == nil {
+		t.Error("expected an error diffing proofs of different transactions")
+	}
+}
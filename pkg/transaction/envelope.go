@@ -0,0 +1,299 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Encoding format versions. The version byte follows the magic bytes; in the
+// legacy layout that byte is the high byte of the length field, which is
+// always zero because calldata can never approach 16 MiB.
+const (
+	// FormatLegacy is magic | length(4) | custom | standard
+	FormatLegacy byte = 0x00
+	// FormatV1 is magic | version | flags | optional fields | length(4) | custom | standard
+	FormatV1 byte = 0x01
+)
+
+// FormatV1 header flags, each enabling an optional field in this order
+const (
+	// FlagExpiry adds an 8-byte unix-seconds expiry
+	FlagExpiry byte = 1 << iota
+	// FlagSchema adds a 2-byte schema id describing the custom data layout
+	FlagSchema
+	// FlagLittleEndian encodes the length and numeric header fields little-endian
+	FlagLittleEndian
+	// FlagDictionary adds a 4-byte dictionary id; the custom data is raw
+	// DEFLATE compressed with that preset dictionary
+	FlagDictionary
+	// FlagPadded adds the 4-byte true length of the custom data, which is
+	// zero-padded up to the length field
+	FlagPadded
+	// FlagChecksum adds a 4-byte checksum of the custom segment as encoded:
+	// the first bytes of its Keccak-256 hash
+	FlagChecksum
+)
+
+// NoSchemaID is reported for payloads that carry no schema id
+const NoSchemaID uint16 = 0
+
+var (
+	// ErrNotCustomData is returned when data does not start with MagicBytes
+	ErrNotCustomData = errors.New("data is not custom-encoded")
+	// ErrChecksumMismatch is returned when custom data does not match the
+	// checksum in its header
+	ErrChecksumMismatch = errors.New("custom data checksum mismatch")
+)
+
+// EncodeOptions selects the optional FormatV1 header fields
+type EncodeOptions struct {
+	// Expiry is the unix time in seconds after which the payload is stale; 0 means never
+	Expiry uint64
+	// SchemaID identifies the layout of the custom data; NoSchemaID omits it
+	SchemaID uint16
+	// LittleEndian encodes the length and numeric fields little-endian
+	// instead of the default big-endian
+	LittleEndian bool
+	// Dictionary, if non-nil, compresses the custom data with this preset
+	// DEFLATE dictionary, see EncodeCustomDataWithDict
+	Dictionary []byte
+	// PadTo, if positive, zero-pads the custom data to a multiple of PadTo
+	// bytes, see EncodeCustomDataPadded
+	PadTo int
+	// Checksum adds a checksum of the custom data, which decoders verify and
+	// IsCustomTransactionStrict uses to rule out chance MagicBytes prefixes
+	Checksum bool
+}
+
+// byteOrder is implemented by binary.BigEndian and binary.LittleEndian
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// orderFor returns the byte order selected by a FormatV1 flags byte
+func orderFor(flags byte) byteOrder {
+	if flags&FlagLittleEndian != 0 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// Envelope is a decoded custom data payload together with its header fields
+type Envelope struct {
+	Version      byte
+	Flags        byte
+	Expiry       uint64
+	SchemaID     uint16
+	CustomData   []byte
+	StandardData []byte
+	// DictionaryID is set with FlagDictionary; CustomData is only
+	// decompressed by DecodeEnvelopeWithDict
+	DictionaryID uint32
+
+	// paddedLength is the custom segment's length before the padding is
+	// trimmed, set with FlagPadded
+	paddedLength int
+}
+
+// EncodeCustomDataWithOptions encodes customData in FormatV1 with the header
+// fields selected by opts
+func EncodeCustomDataWithOptions(standardData, customData []byte, opts EncodeOptions) []byte {
+	var flags byte
+	if opts.Expiry != 0 {
+		flags |= FlagExpiry
+	}
+	if opts.SchemaID != NoSchemaID {
+		flags |= FlagSchema
+	}
+	if opts.LittleEndian {
+		flags |= FlagLittleEndian
+	}
+	if opts.Dictionary != nil {
+		flags |= FlagDictionary
+		customData = deflate(customData, opts.Dictionary)
+	}
+	trueLength := len(customData)
+	if opts.PadTo > 0 {
+		flags |= FlagPadded
+		customData = pad(customData, opts.PadTo)
+	}
+	if opts.Checksum {
+		flags |= FlagChecksum
+	}
+	order := orderFor(flags)
+
+	totalSize := len(MagicBytes) + 2 + 8 + 2 + 4 + 4 + 4 + 4 + len(customData) + len(standardData)
+	result := make([]byte, 0, totalSize)
+
+	result = append(result, MagicBytes...)
+	result = append(result, FormatV1, flags)
+
+	if flags&FlagExpiry != 0 {
+		result = order.AppendUint64(result, opts.Expiry)
+	}
+	if flags&FlagSchema != 0 {
+		result = order.AppendUint16(result, opts.SchemaID)
+	}
+	if flags&FlagDictionary != 0 {
+		result = order.AppendUint32(result, DictionaryID(opts.Dictionary))
+	}
+	if flags&FlagPadded != 0 {
+		result = order.AppendUint32(result, uint32(trueLength))
+	}
+	if flags&FlagChecksum != 0 {
+		result = order.AppendUint32(result, checksum(customData))
+	}
+
+	result = order.AppendUint32(result, uint32(len(customData)))
+	result = append(result, customData...)
+	result = append(result, standardData...)
+
+	return result
+}
+
+// DecodeEnvelope decodes a payload in any supported format. Custom data
+// compressed with a dictionary fails with ErrDictionaryRequired; use
+// DecodeEnvelopeWithDict for it.
+func DecodeEnvelope(encodedData []byte) (*Envelope, error) {
+	return DecodeEnvelopeWithDict(encodedData, nil)
+}
+
+// decodeEnvelope decodes the header and segments without decompressing
+func decodeEnvelope(encodedData []byte) (*Envelope, error) {
+	if len(encodedData) < len(MagicBytes)+1 || !bytes.Equal(encodedData[:len(MagicBytes)], MagicBytes) {
+		return nil, ErrNotCustomData
+	}
+
+	version := encodedData[len(MagicBytes)]
+	switch version {
+	case FormatLegacy:
+		return decodeBody(&Envelope{Version: FormatLegacy}, encodedData, len(MagicBytes), binary.BigEndian)
+	case FormatV1:
+		return decodeV1(encodedData)
+	default:
+		return nil, fmt.Errorf("unsupported custom data format version %d", version)
+	}
+}
+
+func decodeV1(encodedData []byte) (*Envelope, error) {
+	offset := len(MagicBytes) + 1
+	if len(encodedData) < offset+1 {
+		return nil, fmt.Errorf("invalid custom data encoding: missing flags")
+	}
+
+	env := &Envelope{Version: FormatV1, Flags: encodedData[offset]}
+	order := orderFor(env.Flags)
+	offset++
+
+	if env.Flags&FlagExpiry != 0 {
+		if len(encodedData) < offset+8 {
+			return nil, fmt.Errorf("invalid custom data encoding: truncated expiry")
+		}
+		env.Expiry = order.Uint64(encodedData[offset : offset+8])
+		offset += 8
+	}
+
+	if env.Flags&FlagSchema != 0 {
+		if len(encodedData) < offset+2 {
+			return nil, fmt.Errorf("invalid custom data encoding: truncated schema id")
+		}
+		env.SchemaID = order.Uint16(encodedData[offset : offset+2])
+		offset += 2
+	}
+
+	if env.Flags&FlagDictionary != 0 {
+		if len(encodedData) < offset+4 {
+			return nil, fmt.Errorf("invalid custom data encoding: truncated dictionary id")
+		}
+		env.DictionaryID = order.Uint32(encodedData[offset : offset+4])
+		offset += 4
+	}
+
+	var trueLength uint32
+	if env.Flags&FlagPadded != 0 {
+		if len(encodedData) < offset+4 {
+			return nil, fmt.Errorf("invalid custom data encoding: truncated true length")
+		}
+		trueLength = order.Uint32(encodedData[offset : offset+4])
+		offset += 4
+	}
+
+	var sum uint32
+	if env.Flags&FlagChecksum != 0 {
+		if len(encodedData) < offset+4 {
+			return nil, fmt.Errorf("invalid custom data encoding: truncated checksum")
+		}
+		sum = order.Uint32(encodedData[offset : offset+4])
+		offset += 4
+	}
+
+	env, err := decodeBody(env, encodedData, offset, order)
+	if err != nil {
+		return nil, err
+	}
+
+	if env.Flags&FlagChecksum != 0 && checksum(env.CustomData) != sum {
+		return nil, ErrChecksumMismatch
+	}
+	if env.Flags&FlagPadded != 0 {
+		if uint64(trueLength) > uint64(len(env.CustomData)) {
+			return nil, fmt.Errorf(
+				"invalid custom data encoding: true length %d exceeds padded length %d",
+				trueLength, len(env.CustomData),
+			)
+		}
+		env.paddedLength = len(env.CustomData)
+		env.CustomData = env.CustomData[:trueLength]
+	}
+	return env, nil
+}
+
+// checksum is the FlagChecksum field for a custom segment: the first 4
+// bytes of its Keccak-256 hash, read big-endian
+func checksum(segment []byte) uint32 {
+	return binary.BigEndian.Uint32(crypto.Keccak256(segment)[:4])
+}
+
+// decodeBody reads the length-prefixed custom segment at offset and the
+// standard data that follows it
+func decodeBody(env *Envelope, encodedData []byte, offset int, order binary.ByteOrder) (*Envelope, error) {
+	if len(encodedData) < offset+4 {
+		return nil, fmt.Errorf("invalid custom data encoding: missing length")
+	}
+
+	length := order.Uint32(encodedData[offset : offset+4])
+	offset += 4
+
+	if uint64(len(encodedData)) < uint64(offset)+uint64(length) {
+		return nil, fmt.Errorf(
+			"invalid custom data encoding: declared length %d exceeds available data",
+			length,
+		)
+	}
+
+	env.CustomData = encodedData[offset : offset+int(length)]
+	env.StandardData = encodedData[offset+int(length):]
+
+	return env, nil
+}
+
+// IsExpired reports whether tx carries an expiry that now has passed. A
+// payload without an expiry (or one that cannot be decoded) never expires.
+func IsExpired(tx *types.Transaction, now time.Time) bool {
+	env, err := DecodeEnvelope(tx.Data())
+	if err != nil || env.Expiry == 0 {
+		return false
+	}
+	// Compare unsigned: expiries past math.MaxInt64 would wrap negative
+	if now.Unix() < 0 {
+		return false
+	}
+	return uint64(now.Unix()) > env.Expiry
+}
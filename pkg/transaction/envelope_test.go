@@ -0,0 +1,222 @@
+package transaction_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+func TestEnvelopeExpiryRoundTrip(t *testing.T) {
+	expiry := uint64(1_700_000_000)
+	encoded := transaction.EncodeCustomDataWithOptions(
+		[]byte{0x01, 0x02}, []byte("expiring"), transaction.EncodeOptions{Expiry: expiry},
+	)
+
+	env, err := transaction.DecodeEnvelope(encoded)
+	if err != nil {
+		t.Fatalf("DecodeEnvelope failed: %v", err)
+	}
+	if env.Version != transaction.FormatV1 || env.Expiry != expiry {
+		t.Errorf("got version %d expiry %d, want %d and %d", env.Version, env.Expiry, transaction.FormatV1, expiry)
+	}
+	if !bytes.Equal(env.CustomData, []byte("expiring")) || !bytes.Equal(env.StandardData, []byte{0x01, 0x02}) {
+		t.Error("payload mismatch")
+	}
+
+	// DecodeCustomData understands the versioned layout too
+	custom, standard, err := transaction.DecodeCustomData(encoded)
+	if err != nil || !bytes.Equal(custom, []byte("expiring")) || !bytes.Equal(standard, []byte{0x01, 0x02}) {
+		t.Errorf("DecodeCustomData = %q, %v, %v", custom, standard, err)
+	}
+}
+
+func TestDecodeEnvelopeLegacy(t *testing.T) {
+	env, err := transaction.DecodeEnvelope(transaction.EncodeCustomData(nil, []byte("legacy")))
+	if err != nil {
+		t.Fatalf("DecodeEnvelope failed: %v", err)
+	}
+	if env.Version != transaction.FormatLegacy || env.Expiry != 0 || string(env.CustomData) != "legacy" {
+		t.Errorf("unexpected legacy envelope: %+v", env)
+	}
+
+	if _, err := transaction.DecodeEnvelope([]byte{0x01, 0x02}); !errors.Is(err, transaction.ErrNotCustomData) {
+		t.Errorf("DecodeEnvelope(non-custom) error = %v, want ErrNotCustomData", err)
+	}
+}
+
+func TestIsExpired(t *testing.T) {
+	expiry := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name   string
+		expiry uint64
+		now    time.Time
+		want   bool
+	}{
+		{"before expiry", uint64(expiry.Unix()), expiry.Add(-time.Second), false},
+		{"at expiry", uint64(expiry.Unix()), expiry, false},
+		{"after expiry", uint64(expiry.Unix()), expiry.Add(time.Second), true},
+		{"no expiry", 0, expiry.Add(100 * 365 * 24 * time.Hour), false},
+		{"max expiry", math.MaxUint64, expiry, false},
+		{"past max int64", math.MaxInt64 + 1, expiry, false},
+		{"before the epoch", uint64(expiry.Unix()), time.Unix(-1, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := transaction.EncodeCustomDataWithOptions(nil, []byte("msg"), transaction.EncodeOptions{Expiry: tt.expiry})
+			tx := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), Data: data})
+
+			if got := transaction.IsExpired(tx, tt.now); got != tt.want {
+				t.Errorf("IsExpired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCustomDataWithExpiry(t *testing.T) {
+	data := transaction.EncodeCustomDataWithOptions([]byte{0x01}, []byte("msg"), transaction.EncodeOptions{Expiry: math.MaxUint64})
+
+	customData, standardData, expiry, err := transaction.DecodeCustomDataWithExpiry(data)
+	if err != nil {
+		t.Fatalf("DecodeCustomDataWithExpiry failed: %v", err)
+	}
+	if string(customData) != "msg" || !bytes.Equal(standardData, []byte{0x01}) || expiry != math.MaxUint64 {
+		t.Errorf("got %q, %x, expiry %d", customData, standardData, expiry)
+	}
+
+	_, standardData, expiry, err = transaction.DecodeCustomDataWithExpiry([]byte{0xaa, 0xbb})
+	if err != nil || expiry != 0 || !bytes.Equal(standardData, []byte{0xaa, 0xbb}) {
+		t.Errorf("plain calldata: got %x, expiry %d, err %v", standardData, expiry, err)
+	}
+}
+
+func TestEnvelopeSchemaRoundTrip(t *testing.T) {
+	opts := transaction.EncodeOptions{Expiry: 42, SchemaID: 0x0102}
+	env, err := transaction.DecodeEnvelope(transaction.EncodeCustomDataWithOptions(nil, []byte("typed"), opts))
+	if err != nil {
+		t.Fatalf("DecodeEnvelope failed: %v", err)
+	}
+	if env.SchemaID != opts.SchemaID || env.Expiry != opts.Expiry || string(env.CustomData) != "typed" {
+		t.Errorf("unexpected envelope: %+v", env)
+	}
+}
+
+func TestEnvelopeEndianness(t *testing.T) {
+	opts := transaction.EncodeOptions{Expiry: 1_700_000_000, SchemaID: 0x0a0b}
+
+	for _, littleEndian := range []bool{false, true} {
+		opts.LittleEndian = littleEndian
+		encoded := transaction.EncodeCustomDataWithOptions([]byte{0xff}, []byte("ordered"), opts)
+
+		env, err := transaction.DecodeEnvelope(encoded)
+		if err != nil {
+			t.Fatalf("little-endian=%v: DecodeEnvelope failed: %v", littleEndian, err)
+		}
+		if env.Expiry != opts.Expiry || env.SchemaID != opts.SchemaID ||
+			string(env.CustomData) != "ordered" || !bytes.Equal(env.StandardData, []byte{0xff}) {
+			t.Errorf("little-endian=%v: unexpected envelope %+v", littleEndian, env)
+		}
+		if got := env.Flags&transaction.FlagLittleEndian != 0; got != littleEndian {
+			t.Errorf("little-endian flag = %v, want %v", got, littleEndian)
+		}
+	}
+}
+
+func TestEnvelopeLittleEndianReadAsBigEndian(t *testing.T) {
+	encoded := transaction.EncodeCustomDataWithOptions(nil, []byte("short"), transaction.EncodeOptions{LittleEndian: true})
+
+	// Clear the flag so the length is read big-endian
+	flagsOffset := len(transaction.MagicBytes) + 1
+	encoded[flagsOffset] &^= transaction.FlagLittleEndian
+
+	if _, err := transaction.DecodeEnvelope(encoded); err == nil {
+		t.Fatal("decoding a little-endian length as big-endian should fail")
+	}
+}
+
+func TestCustomDataDictionary(t *testing.T) {
+	dict := []byte(`{"type":"transfer","currency":"EUR","amount":,"reference":"invoice-"}`)
+	payload := []byte(`{"type":"transfer","currency":"EUR","amount":125,"reference":"invoice-2024-0042"}`)
+	standard := []byte{0xa9, 0x05, 0x9c, 0xbb}
+
+	encoded := transaction.EncodeCustomDataWithDict(standard, payload, dict)
+	plain := transaction.EncodeCustomDataWithOptions(standard, payload, transaction.EncodeOptions{})
+	if len(encoded) >= len(plain) {
+		t.Errorf("compressed encoding is %d bytes, uncompressed %d", len(encoded), len(plain))
+	}
+
+	custom, std, err := transaction.DecodeCustomDataWithDict(encoded, dict)
+	if err != nil {
+		t.Fatalf("DecodeCustomDataWithDict failed: %v", err)
+	}
+	if !bytes.Equal(custom, payload) || !bytes.Equal(std, standard) {
+		t.Errorf("round trip = %q, %x; want %q, %x", custom, std, payload, standard)
+	}
+
+	env, err := transaction.DecodeEnvelopeWithDict(encoded, dict)
+	if err != nil || env.DictionaryID != transaction.DictionaryID(dict) {
+		t.Errorf("envelope dictionary id = %08x, %v; want %08x", env.DictionaryID, err, transaction.DictionaryID(dict))
+	}
+
+	if _, _, err := transaction.DecodeCustomData(encoded); !errors.Is(err, transaction.ErrDictionaryRequired) {
+		t.Errorf("DecodeCustomData without a dictionary error = %v, want ErrDictionaryRequired", err)
+	}
+	other := []byte(`{"kind":"other"}`)
+	if _, _, err := transaction.DecodeCustomDataWithDict(encoded, other); !errors.Is(err, transaction.ErrDictionaryMismatch) {
+		t.Errorf("DecodeCustomDataWithDict with another dictionary error = %v, want ErrDictionaryMismatch", err)
+	}
+
+	// Uncompressed payloads decode the same with or without a dictionary
+	if custom, _, err := transaction.DecodeCustomDataWithDict(plain, dict); err != nil || !bytes.Equal(custom, payload) {
+		t.Errorf("uncompressed payload with a dictionary = %q, %v", custom, err)
+	}
+}
+
+func TestCustomDataPadded(t *testing.T) {
+	const blockSize = 256
+	standard := []byte{0xde, 0xad}
+	headerLen := len(transaction.EncodeCustomDataPadded(nil, nil, blockSize)) - blockSize
+
+	for _, n := range []int{0, 1, 31, 100, 255} {
+		custom := bytes.Repeat([]byte{0x5a}, n)
+		encoded := transaction.EncodeCustomDataPadded(standard, custom, blockSize)
+
+		// Every payload shorter than a block encodes to the same length
+		if got, want := len(encoded), headerLen+blockSize+len(standard); got != want {
+			t.Errorf("len %d: encoded length = %d, want %d", n, got, want)
+		}
+
+		gotCustom, gotStandard, err := transaction.DecodeCustomData(encoded)
+		if err != nil {
+			t.Fatalf("len %d: DecodeCustomData failed: %v", n, err)
+		}
+		if !bytes.Equal(gotCustom, custom) || !bytes.Equal(gotStandard, standard) {
+			t.Errorf("len %d: decoded %x, %x; want %x, %x", n, gotCustom, gotStandard, custom, standard)
+		}
+	}
+
+	// Longer payloads take whole blocks
+	encoded := transaction.EncodeCustomDataPadded(nil, make([]byte, 257), blockSize)
+	if got, want := len(encoded), headerLen+2*blockSize; got != want {
+		t.Errorf("len 257: encoded length = %d, want %d", got, want)
+	}
+
+	// A true length past the padded segment is rejected
+	env, err := transaction.DecodeEnvelope(encoded)
+	if err != nil || env.Flags&transaction.FlagPadded == 0 || len(env.CustomData) != 257 {
+		t.Fatalf("DecodeEnvelope = %+v, %v", env, err)
+	}
+	trueLengthOffset := len(transaction.MagicBytes) + 2
+	copy(encoded[trueLengthOffset:], []byte{0xff, 0xff, 0xff, 0xff})
+	if _, err := transaction.DecodeEnvelope(encoded); err == nil {
+		t.Error("decoding a true length beyond the padding should fail")
+	}
+}
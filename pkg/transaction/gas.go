@@ -0,0 +1,444 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	DefaultFeeHistoryBlocks     = uint64(10)
+	DefaultFeeHistoryPercentile = 50.0
+	// ConfirmationSampleBlocks is how many recent blocks EstimateConfirmationTime samples
+	ConfirmationSampleBlocks = uint64(20)
+	// DefaultBaseFeeBufferPercent pads the predicted base fee in PredictedBaseFeeStrategy
+	DefaultBaseFeeBufferPercent = uint64(10)
+
+	// EIP-1559 parameters
+	elasticityMultiplier     = 2
+	baseFeeChangeDenominator = 8
+)
+
+// confirmationTiers maps reward percentiles to the expected number of blocks
+// until a transaction paying at least that tip is mined. Percentiles are
+// ascending, as eth_feeHistory requires.
+var confirmationTiers = []struct {
+	percentile float64
+	blocks     int64
+}{
+	{10, 10},
+	{25, 5},
+	{50, 3},
+	{75, 2},
+	{90, 1},
+}
+
+// confirmationBlocksBelowTiers is the estimate for tips under the lowest tier
+const confirmationBlocksBelowTiers = 30
+
+// GasStrategy picks the EIP-1559 fee caps for outgoing transactions
+type GasStrategy interface {
+	FeeCaps(ctx context.Context, m *Manager) (gasTipCap, gasFeeCap *big.Int, err error)
+}
+
+// FeeHistoryResult holds the node's recent fee market history
+type FeeHistoryResult struct {
+	OldestBlock *big.Int
+	// BaseFees has one entry per block plus the base fee of the next block
+	BaseFees []*big.Int
+	// Rewards has one entry per block, one tip per requested percentile
+	Rewards      [][]*big.Int
+	GasUsedRatio []float64
+}
+
+// FeeHistory returns base fees and tip percentiles over the last blockCount
+// blocks, as reported by eth_feeHistory
+func (m *Manager) FeeHistory(
+	ctx context.Context,
+	blockCount uint64,
+	rewardPercentiles []float64,
+) (*FeeHistoryResult, error) {
+	history, err := m.clientPool.Get().FeeHistory(ctx, blockCount, nil, rewardPercentiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee history: %w", err)
+	}
+
+	return &FeeHistoryResult{
+		OldestBlock:  history.OldestBlock,
+		BaseFees:     history.BaseFee,
+		Rewards:      history.Reward,
+		GasUsedRatio: history.GasUsedRatio,
+	}, nil
+}
+
+// BaseFeeTrend reports whether the base fee is rising over the last lookback
+// blocks, i.e. whether the latest block's base fee is above the mean of the
+// blocks before it, along with that latest base fee. Callers can hold off
+// sending while it rises.
+func (m *Manager) BaseFeeTrend(ctx context.Context, lookback int) (rising bool, latest *big.Int, err error) {
+	if lookback < 2 {
+		return false, nil, fmt.Errorf("lookback must be at least 2 blocks, got %d", lookback)
+	}
+
+	history, err := m.FeeHistory(ctx, uint64(lookback), nil)
+	if err != nil {
+		return false, nil, err
+	}
+
+	// The final entry is the next block's base fee, not a mined block's
+	if len(history.BaseFees) < 3 {
+		return false, nil, fmt.Errorf("not enough blocks to measure the base fee trend")
+	}
+	mined := history.BaseFees[:len(history.BaseFees)-1]
+	latest = mined[len(mined)-1]
+
+	sum := new(big.Int)
+	for _, fee := range mined[:len(mined)-1] {
+		sum.Add(sum, fee)
+	}
+	previous := int64(len(mined) - 1)
+
+	// latest > sum/previous, compared without integer division
+	rising = new(big.Int).Mul(latest, big.NewInt(previous)).Cmp(sum) > 0
+
+	return rising, new(big.Int).Set(latest), nil
+}
+
+// SuggestTip returns the median over the last DefaultFeeHistoryBlocks blocks
+// of the given reward percentile, e.g. 50 for a normal and 90 for a fast
+// inclusion
+func (m *Manager) SuggestTip(ctx context.Context, percentile float64) (*big.Int, error) {
+	if percentile < 0 || percentile > 100 {
+		return nil, fmt.Errorf("percentile %v out of range [0, 100]", percentile)
+	}
+
+	history, err := m.FeeHistory(ctx, DefaultFeeHistoryBlocks, []float64{percentile})
+	if err != nil {
+		return nil, err
+	}
+	if len(history.Rewards) == 0 {
+		return nil, fmt.Errorf("fee history has no rewards")
+	}
+
+	return new(big.Int).Set(medianReward(history.Rewards, 0)), nil
+}
+
+// tipPercentileStrategy is BaseFeeStrategy with the tip taken from a fee
+// history percentile, as selected by WithTipPercentile
+type tipPercentileStrategy struct {
+	percentile float64
+}
+
+func (s tipPercentileStrategy) FeeCaps(ctx context.Context, m *Manager) (*big.Int, *big.Int, error) {
+	gasTipCap, err := m.SuggestTip(ctx, s.percentile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	head, err := m.clientPool.Get().HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get block header: %w", err)
+	}
+	if head.BaseFee == nil {
+		return nil, nil, fmt.Errorf("base fee is nil, chain may not support EIP-1559")
+	}
+
+	gasFeeCap := new(big.Int).Add(
+		gasTipCap,
+		new(big.Int).Mul(head.BaseFee, big.NewInt(BaseFeeMultiplier)),
+	)
+
+	return gasTipCap, gasFeeCap, nil
+}
+
+// clampTip bounds gasTipCap by the manager's WithMinTipWei and WithMaxTipWei
+// limits, moving gasFeeCap by the same amount so the base fee headroom is
+// unchanged
+func (m *Manager) clampTip(gasTipCap, gasFeeCap *big.Int) (*big.Int, *big.Int) {
+	clamped := gasTipCap
+	if m.minTip != nil && clamped.Cmp(m.minTip) < 0 {
+		clamped = m.minTip
+	}
+	if m.maxTip != nil && clamped.Cmp(m.maxTip) > 0 {
+		clamped = m.maxTip
+	}
+	if clamped == gasTipCap {
+		return gasTipCap, gasFeeCap
+	}
+
+	delta := new(big.Int).Sub(clamped, gasTipCap)
+	return new(big.Int).Set(clamped), new(big.Int).Add(gasFeeCap, delta)
+}
+
+// FeeCaps returns the tip and fee cap the manager's gas strategy picks for a
+// send made now, after the WithMinTipWei and WithMaxTipWei limits
+func (m *Manager) FeeCaps(ctx context.Context) (gasTipCap, gasFeeCap *big.Int, err error) {
+	gasTipCap, gasFeeCap, err = m.gasStrategy.FeeCaps(ctx, m)
+	if err != nil {
+		return nil, nil, err
+	}
+	gasTipCap, gasFeeCap = m.clampTip(gasTipCap, gasFeeCap)
+	return gasTipCap, gasFeeCap, nil
+}
+
+// GasLimitFor returns the gas limit used for a custom transaction with the
+// given encoded calldata: DefaultGasLimit, or the calldata's intrinsic gas
+// plus GasLimitMargin if that is larger. The intrinsic gas includes the
+// EIP-7623 calldata floor, so large payloads are never underfunded.
+func GasLimitFor(calldata []byte) uint64 {
+	intrinsic, err := core.IntrinsicGas(calldata, nil, nil, false, true, true, true)
+	if err != nil {
+		// Only overflows fail, and no block could fit such a transaction
+		return math.MaxUint64
+	}
+	floor, err := core.FloorDataGas(calldata)
+	if err != nil {
+		return math.MaxUint64
+	}
+	return max(DefaultGasLimit, max(intrinsic, floor)+GasLimitMargin)
+}
+
+// BaseFee returns the base fee of the latest block
+func (m *Manager) BaseFee(ctx context.Context) (*big.Int, error) {
+	head, err := m.clientPool.Get().HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block header: %w", err)
+	}
+	if head.BaseFee == nil {
+		return nil, fmt.Errorf("base fee is nil, chain may not support EIP-1559")
+	}
+	return head.BaseFee, nil
+}
+
+// BaseFeeStrategy uses the node's suggested tip and a fee cap of
+// tip + BaseFeeMultiplier * latest base fee. It is the default strategy.
+type BaseFeeStrategy struct{}
+
+func (BaseFeeStrategy) FeeCaps(ctx context.Context, m *Manager) (*big.Int, *big.Int, error) {
+	client := m.clientPool.Get()
+
+	gasTipCap, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get gas tip: %w", err)
+	}
+
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get block header: %w", err)
+	}
+
+	if head.BaseFee == nil {
+		return nil, nil, fmt.Errorf("base fee is nil, chain may not support EIP-1559")
+	}
+
+	gasFeeCap := new(big.Int).Add(
+		gasTipCap,
+		new(big.Int).Mul(head.BaseFee, big.NewInt(BaseFeeMultiplier)),
+	)
+
+	return gasTipCap, gasFeeCap, nil
+}
+
+// FeeHistoryStrategy takes the median of a reward percentile over recent
+// blocks as the tip, and caps fees at tip + BaseFeeMultiplier * next base fee
+type FeeHistoryStrategy struct {
+	// Blocks is the number of recent blocks sampled (default 10)
+	Blocks uint64
+	// Percentile is the reward percentile sampled per block (default 50)
+	Percentile float64
+}
+
+func (s FeeHistoryStrategy) FeeCaps(ctx context.Context, m *Manager) (*big.Int, *big.Int, error) {
+	blocks := s.Blocks
+	if blocks == 0 {
+		blocks = DefaultFeeHistoryBlocks
+	}
+	percentile := s.Percentile
+	if percentile == 0 {
+		percentile = DefaultFeeHistoryPercentile
+	}
+
+	history, err := m.FeeHistory(ctx, blocks, []float64{percentile})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(history.BaseFees) == 0 || len(history.Rewards) == 0 {
+		return nil, nil, fmt.Errorf("fee history is empty, chain may not support EIP-1559")
+	}
+
+	tips := make([]*big.Int, 0, len(history.Rewards))
+	for _, rewards := range history.Rewards {
+		if len(rewards) > 0 {
+			tips = append(tips, rewards[0])
+		}
+	}
+	if len(tips) == 0 {
+		return nil, nil, fmt.Errorf("fee history has no rewards")
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+
+	gasTipCap := new(big.Int).Set(tips[len(tips)/2])
+	nextBaseFee := history.BaseFees[len(history.BaseFees)-1]
+
+	gasFeeCap := new(big.Int).Add(
+		gasTipCap,
+		new(big.Int).Mul(nextBaseFee, big.NewInt(BaseFeeMultiplier)),
+	)
+
+	return gasTipCap, gasFeeCap, nil
+}
+
+// PredictNextBaseFee applies the EIP-1559 update rule to a block header: the
+// base fee moves by up to 12.5% in proportion to how far the block's gas
+// usage was from the gas target
+func PredictNextBaseFee(header *types.Header) *big.Int {
+	baseFee := new(big.Int).Set(header.BaseFee)
+	target := header.GasLimit / elasticityMultiplier
+	if target == 0 || header.GasUsed == target {
+		return baseFee
+	}
+
+	var gasDelta uint64
+	if header.GasUsed > target {
+		gasDelta = header.GasUsed - target
+	} else {
+		gasDelta = target - header.GasUsed
+	}
+
+	delta := new(big.Int).Mul(header.BaseFee, new(big.Int).SetUint64(gasDelta))
+	delta.Div(delta, new(big.Int).SetUint64(target))
+	delta.Div(delta, big.NewInt(baseFeeChangeDenominator))
+
+	if header.GasUsed > target {
+		if delta.Sign() == 0 {
+			delta.SetInt64(1)
+		}
+		return baseFee.Add(baseFee, delta)
+	}
+	return baseFee.Sub(baseFee, delta)
+}
+
+// PredictedBaseFeeStrategy uses the node's suggested tip and a fee cap of
+// tip + the next block's predicted base fee plus a buffer, avoiding
+// BaseFeeStrategy's overbid when the base fee is falling
+type PredictedBaseFeeStrategy struct {
+	// BufferPercent pads the predicted base fee (default 10)
+	BufferPercent uint64
+}
+
+func (s PredictedBaseFeeStrategy) FeeCaps(ctx context.Context, m *Manager) (*big.Int, *big.Int, error) {
+	bufferPercent := s.BufferPercent
+	if bufferPercent == 0 {
+		bufferPercent = DefaultBaseFeeBufferPercent
+	}
+
+	client := m.clientPool.Get()
+
+	gasTipCap, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get gas tip: %w", err)
+	}
+
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get block header: %w", err)
+	}
+
+	if head.BaseFee == nil {
+		return nil, nil, fmt.Errorf("base fee is nil, chain may not support EIP-1559")
+	}
+
+	predicted := PredictNextBaseFee(head)
+	buffer := new(big.Int).Mul(predicted, new(big.Int).SetUint64(bufferPercent))
+	buffer.Div(buffer, big.NewInt(100))
+
+	gasFeeCap := new(big.Int).Add(gasTipCap, predicted)
+	gasFeeCap.Add(gasFeeCap, buffer)
+
+	return gasTipCap, gasFeeCap, nil
+}
+
+// EstimateConfirmationTime estimates how long a transaction paying gasTipCap
+// takes to be mined. It compares the tip against the median reward
+// percentiles of recent blocks to pick an expected number of blocks and
+// multiplies by the average recent block time. The estimate is a heuristic,
+// but deterministic for a given fee history and block timestamps.
+func (m *Manager) EstimateConfirmationTime(ctx context.Context, gasTipCap *big.Int) (time.Duration, error) {
+	if gasTipCap == nil {
+		return 0, fmt.Errorf("gas tip cap is nil")
+	}
+
+	percentiles := make([]float64, len(confirmationTiers))
+	for i, tier := range confirmationTiers {
+		percentiles[i] = tier.percentile
+	}
+
+	history, err := m.FeeHistory(ctx, ConfirmationSampleBlocks, percentiles)
+	if err != nil {
+		return 0, err
+	}
+	if len(history.Rewards) == 0 {
+		return 0, fmt.Errorf("fee history has no rewards")
+	}
+
+	blockTime, err := m.averageBlockTime(ctx, history.OldestBlock)
+	if err != nil {
+		return 0, err
+	}
+
+	// Pick the most aggressive tier the tip still matches
+	blocks := int64(confirmationBlocksBelowTiers)
+	for i := len(confirmationTiers) - 1; i >= 0; i-- {
+		if gasTipCap.Cmp(medianReward(history.Rewards, i)) >= 0 {
+			blocks = confirmationTiers[i].blocks
+			break
+		}
+	}
+
+	return time.Duration(blocks) * blockTime, nil
+}
+
+// averageBlockTime is the mean time between blocks from oldest to the head
+func (m *Manager) averageBlockTime(ctx context.Context, oldest *big.Int) (time.Duration, error) {
+	client := m.clientPool.Get()
+
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get head: %w", err)
+	}
+
+	first, err := client.HeaderByNumber(ctx, oldest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get header %s: %w", oldest, err)
+	}
+
+	span := new(big.Int).Sub(head.Number, first.Number)
+	if span.Sign() <= 0 || head.Time <= first.Time {
+		return 0, fmt.Errorf("not enough blocks to measure block time")
+	}
+
+	elapsed := time.Duration(head.Time-first.Time) * time.Second
+	return elapsed / time.Duration(span.Int64()), nil
+}
+
+// medianReward is the median across blocks of the reward at column i
+func medianReward(rewards [][]*big.Int, i int) *big.Int {
+	column := make([]*big.Int, 0, len(rewards))
+	for _, row := range rewards {
+		if i < len(row) {
+			column = append(column, row[i])
+		}
+	}
+	if len(column) == 0 {
+		return new(big.Int)
+	}
+
+	sort.Slice(column, func(a, b int) bool { return column[a].Cmp(column[b]) < 0 })
+	return column[len(column)/2]
+}
@@ -0,0 +1,334 @@
+package transaction_test
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/k4rz4/ethereum-custom-transactions/internal/ethtest"
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+func knownFeeHistory() *ethereum.FeeHistory {
+	return &ethereum.FeeHistory{
+		OldestBlock: big.NewInt(7),
+		BaseFee:     []*big.Int{big.NewInt(100), big.NewInt(110), big.NewInt(120), big.NewInt(130)},
+		Reward: [][]*big.Int{
+			{big.NewInt(5), big.NewInt(50)},
+			{big.NewInt(3), big.NewInt(30)},
+			{big.NewInt(4), big.NewInt(40)},
+		},
+		GasUsedRatio: []float64{0.5, 0.6, 0.7},
+	}
+}
+
+func TestFeeHistory(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	backend.SetFeeHistory(knownFeeHistory())
+
+	history, err := mgr.FeeHistory(context.Background(), 3, []float64{10, 90})
+	if err != nil {
+		t.Fatalf("FeeHistory failed: %v", err)
+	}
+
+	if history.OldestBlock.Int64() != 7 {
+		t.Errorf("OldestBlock = %s, want 7", history.OldestBlock)
+	}
+	if len(history.BaseFees) != 4 || history.BaseFees[3].Int64() != 130 {
+		t.Errorf("BaseFees = %v, want 4 entries ending in 130", history.BaseFees)
+	}
+	if len(history.Rewards) != 3 || history.Rewards[1][1].Int64() != 30 {
+		t.Errorf("Rewards = %v, want 3 rows with 30 at [1][1]", history.Rewards)
+	}
+	if len(history.GasUsedRatio) != 3 || history.GasUsedRatio[2] != 0.7 {
+		t.Errorf("GasUsedRatio = %v", history.GasUsedRatio)
+	}
+}
+
+func TestFeeHistoryStrategy(t *testing.T) {
+	backend, mgr := newTestManager(t, transaction.WithGasStrategy(transaction.FeeHistoryStrategy{}))
+	backend.SetFeeHistory(knownFeeHistory())
+
+	tx, err := mgr.SendWithContext(context.Background(), testRecipient, nil, []byte("fees"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+
+	// Median of the first reward column is 4; next base fee is 130
+	if tx.GasTipCap().Int64() != 4 {
+		t.Errorf("GasTipCap = %s, want 4", tx.GasTipCap())
+	}
+	if want := int64(4 + 130*transaction.BaseFeeMultiplier); tx.GasFeeCap().Int64() != want {
+		t.Errorf("GasFeeCap = %s, want %d", tx.GasFeeCap(), want)
+	}
+	if backend.Calls("eth_maxPriorityFeePerGas") != 0 {
+		t.Error("fee history strategy should not query the suggested tip")
+	}
+}
+
+func TestBaseFeeStrategyIsDefault(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	backend.SetTip(big.NewInt(7))
+
+	tx, err := mgr.SendWithContext(context.Background(), testRecipient, nil, []byte("fees"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+
+	baseFee := int64(ethtest.DefaultBaseFee)
+	if want := 7 + baseFee*transaction.BaseFeeMultiplier; tx.GasFeeCap().Int64() != want {
+		t.Errorf("GasFeeCap = %s, want %d", tx.GasFeeCap(), want)
+	}
+}
+
+func TestEstimateConfirmationTime(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	for i := 0; i < 4; i++ {
+		backend.AddBlock()
+	}
+
+	// Every block pays tips of 10/20/30/40/50 at the 10th..90th percentiles
+	row := func() []*big.Int {
+		return []*big.Int{big.NewInt(10), big.NewInt(20), big.NewInt(30), big.NewInt(40), big.NewInt(50)}
+	}
+	backend.SetFeeHistory(&ethereum.FeeHistory{
+		OldestBlock:  big.NewInt(1),
+		BaseFee:      []*big.Int{big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1)},
+		Reward:       [][]*big.Int{row(), row(), row(), row()},
+		GasUsedRatio: []float64{0.5, 0.5, 0.5, 0.5},
+	})
+
+	blockTime := time.Duration(ethtest.BlockTime) * time.Second
+	tests := []struct {
+		tip  int64
+		want time.Duration
+	}{
+		{60, 1 * blockTime},
+		{50, 1 * blockTime},
+		{35, 3 * blockTime},
+		{10, 10 * blockTime},
+		{1, 30 * blockTime},
+	}
+
+	for _, tt := range tests {
+		got, err := mgr.EstimateConfirmationTime(context.Background(), big.NewInt(tt.tip))
+		if err != nil {
+			t.Fatalf("EstimateConfirmationTime(%d) failed: %v", tt.tip, err)
+		}
+		if got != tt.want {
+			t.Errorf("EstimateConfirmationTime(%d) = %v, want %v", tt.tip, got, tt.want)
+		}
+	}
+
+	// Higher tips never wait longer
+	fast, _ := mgr.EstimateConfirmationTime(context.Background(), big.NewInt(45))
+	slow, _ := mgr.EstimateConfirmationTime(context.Background(), big.NewInt(15))
+	if fast > slow {
+		t.Errorf("higher tip estimate %v exceeds lower tip estimate %v", fast, slow)
+	}
+}
+
+func TestBaseFeeTrend(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseFees []int64 // per block, then the next block's
+		rising   bool
+		latest   int64
+	}{
+		{"rising", []int64{100, 110, 120, 130, 140}, true, 130},
+		{"falling", []int64{130, 120, 110, 100, 90}, false, 100},
+		{"spike then drop", []int64{100, 200, 100, 120, 120}, false, 120},
+		{"flat", []int64{100, 100, 100, 100}, false, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, mgr := newTestManager(t)
+
+			history := &ethereum.FeeHistory{OldestBlock: big.NewInt(1)}
+			for _, fee := range tt.baseFees {
+				history.BaseFee = append(history.BaseFee, big.NewInt(fee))
+			}
+			backend.SetFeeHistory(history)
+
+			rising, latest, err := mgr.BaseFeeTrend(context.Background(), len(tt.baseFees)-1)
+			if err != nil {
+				t.Fatalf("BaseFeeTrend failed: %v", err)
+			}
+			if rising != tt.rising || latest.Int64() != tt.latest {
+				t.Errorf("BaseFeeTrend = %v, %s; want %v, %d", rising, latest, tt.rising, tt.latest)
+			}
+		})
+	}
+
+	_, mgr := newTestManager(t)
+	if _, _, err := mgr.BaseFeeTrend(context.Background(), 1); err == nil {
+		t.Error("expected an error for a single-block lookback")
+	}
+}
+
+func TestPredictNextBaseFee(t *testing.T) {
+	header := func(gasUsed uint64) *types.Header {
+		return &types.Header{GasLimit: 30_000_000, GasUsed: gasUsed, BaseFee: big.NewInt(1_000_000_000)}
+	}
+
+	tests := []struct {
+		name    string
+		gasUsed uint64
+		want    int64
+	}{
+		{"full block", 30_000_000, 1_125_000_000},
+		{"at target", 15_000_000, 1_000_000_000},
+		{"empty block", 0, 875_000_000},
+		{"half over target", 22_500_000, 1_062_500_000},
+	}
+
+	for _, tt := range tests {
+		if got := transaction.PredictNextBaseFee(header(tt.gasUsed)); got.Int64() != tt.want {
+			t.Errorf("%s: PredictNextBaseFee = %s, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPredictedBaseFeeStrategy(t *testing.T) {
+	feeCap := func(t *testing.T, gasUsed uint64) *big.Int {
+		t.Helper()
+		backend, mgr := newTestManager(t, transaction.WithGasStrategy(transaction.PredictedBaseFeeStrategy{}))
+
+		// A single transaction sets the head block's gas usage
+		key, _ := crypto.GenerateKey()
+		filler, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+			ChainID:   backend.ChainID(),
+			GasTipCap: big.NewInt(1e9),
+			GasFeeCap: big.NewInt(3e9),
+			Gas:       gasUsed,
+			To:        &testRecipient,
+		}), backend.Signer(), key)
+		if err != nil {
+			t.Fatalf("SignTx failed: %v", err)
+		}
+		backend.AddBlock(filler)
+
+		tx, err := mgr.SendWithContext(context.Background(), testRecipient, nil, []byte("fees"), nil)
+		if err != nil {
+			t.Fatalf("SendWithContext failed: %v", err)
+		}
+		return tx.GasFeeCap()
+	}
+
+	// Base fee 1 gwei, tip 1 gwei, 10% buffer on the predicted base fee
+	rising := feeCap(t, ethtest.DefaultGasLimit)
+	if want := int64(1e9 + 1_125_000_000 + 112_500_000); rising.Int64() != want {
+		t.Errorf("rising market: GasFeeCap = %s, want %d", rising, want)
+	}
+
+	falling := feeCap(t, 21000)
+	if falling.Cmp(rising) >= 0 {
+		t.Errorf("falling market cap %s should be below rising market cap %s", falling, rising)
+	}
+	if limit := int64(1e9 + 2*ethtest.DefaultBaseFee); falling.Int64() >= limit {
+		t.Errorf("falling market: GasFeeCap = %s, want below the base fee strategy's %d", falling, limit)
+	}
+}
+
+func TestSuggestTip(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	// Each percentile maps to a distinct tip: p * 0.01 gwei
+	backend.SetPercentileRewards(func(p float64) *big.Int {
+		return big.NewInt(int64(p * 1e7))
+	})
+
+	normal, err := mgr.SuggestTip(ctx, 50)
+	if err != nil {
+		t.Fatalf("SuggestTip failed: %v", err)
+	}
+	if normal.Int64() != 5e8 {
+		t.Errorf("50th percentile tip = %s, want %d", normal, int64(5e8))
+	}
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("fast"), nil, transaction.WithTipPercentile(90))
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	if tx.GasTipCap().Int64() != 9e8 {
+		t.Errorf("GasTipCap = %s, want the 90th percentile %d", tx.GasTipCap(), int64(9e8))
+	}
+	if want := int64(9e8 + 2*ethtest.DefaultBaseFee); tx.GasFeeCap().Int64() != want {
+		t.Errorf("GasFeeCap = %s, want %d", tx.GasFeeCap(), want)
+	}
+
+	if _, err := mgr.SuggestTip(ctx, 101); err == nil {
+		t.Error("expected an error for a percentile above 100")
+	}
+}
+
+func TestTipBounds(t *testing.T) {
+	backend, mgr := newTestManager(t,
+		transaction.WithMinTipWei(big.NewInt(100)),
+		transaction.WithMaxTipWei(big.NewInt(1000)),
+	)
+	ctx := context.Background()
+	headroom := int64(ethtest.DefaultBaseFee) * transaction.BaseFeeMultiplier
+
+	tests := []struct {
+		suggested int64
+		want      int64
+	}{
+		{0, 100},
+		{50, 100},
+		{500, 500},
+		{5000, 1000},
+	}
+	for _, tt := range tests {
+		backend.SetTip(big.NewInt(tt.suggested))
+
+		tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("tip"), nil)
+		if err != nil {
+			t.Fatalf("SendWithContext failed: %v", err)
+		}
+		if tx.GasTipCap().Int64() != tt.want {
+			t.Errorf("suggested %d: tip = %s, want %d", tt.suggested, tx.GasTipCap(), tt.want)
+		}
+		if want := tt.want + headroom; tx.GasFeeCap().Int64() != want {
+			t.Errorf("suggested %d: fee cap = %s, want %d", tt.suggested, tx.GasFeeCap(), want)
+		}
+	}
+}
+
+func TestGasLimitForLargePayload(t *testing.T) {
+	_, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	small, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("small"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	if small.Gas() != transaction.DefaultGasLimit {
+		t.Errorf("small payload gas limit = %d, want %d", small.Gas(), transaction.DefaultGasLimit)
+	}
+
+	payload := bytes.Repeat([]byte{0xAB}, 50*1024)
+	large, err := mgr.SendWithContext(ctx, testRecipient, nil, payload, nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	if large.Gas() <= transaction.DefaultGasLimit {
+		t.Fatalf("50KB payload gas limit = %d, want above %d", large.Gas(), transaction.DefaultGasLimit)
+	}
+
+	// 16 gas per non-zero byte, raised to the EIP-7623 floor of 40
+	if floor := uint64(21000 + 40*len(large.Data())); large.Gas() < floor {
+		t.Errorf("50KB payload gas limit = %d, below the calldata floor %d", large.Gas(), floor)
+	}
+	if large.Gas() != transaction.GasLimitFor(large.Data()) {
+		t.Errorf("gas limit = %d, want GasLimitFor = %d", large.Gas(), transaction.GasLimitFor(large.Data()))
+	}
+}
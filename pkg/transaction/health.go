@@ -0,0 +1,70 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+// HealthReport summarises a Manager's state, e.g. for a /healthz handler
+type HealthReport struct {
+	// Healthy is true if at least one pooled client answered
+	Healthy bool
+	ChainID *big.Int
+	// BlockNumber is the head reported by the first client to answer
+	BlockNumber uint64
+
+	PoolSize       int
+	PoolClosed     bool
+	HealthyClients int
+	// ClientErrors holds the error of each client that failed to answer
+	ClientErrors map[int]string
+
+	// PendingTransactions counts sent transactions not yet seen mined; a
+	// replacement counts once with the transaction it replaced
+	PendingTransactions int
+
+	CachedProofs        int
+	CachedBlocks        int
+	CachedReceipts      int
+	CachedTrees         int
+	CachedVerifications int
+}
+
+// Health pings every pooled client with eth_blockNumber and reports the
+// manager's pool, pending and cache state. A failing client is reported in
+// ClientErrors but only makes the report unhealthy if no client answers.
+func (m *Manager) Health(ctx context.Context) HealthReport {
+	report := HealthReport{
+		ChainID:    new(big.Int).Set(m.chainID),
+		PoolSize:   m.clientPool.Size(),
+		PoolClosed: m.clientPool.IsClosed(),
+
+		PendingTransactions: m.ledger.pendingCount(),
+
+		CachedProofs:        m.proofCache.Len(),
+		CachedBlocks:        m.blockCache.Len(),
+		CachedReceipts:      m.receiptCache.Len(),
+		CachedTrees:         m.treeCache.Len(),
+		CachedVerifications: m.verifyCache.Len(),
+	}
+
+	for i, client := range m.clientPool.Clients() {
+		number, err := client.BlockNumber(ctx)
+		if err != nil {
+			if report.ClientErrors == nil {
+				report.ClientErrors = make(map[int]string)
+			}
+			report.ClientErrors[i] = fmt.Sprintf("client %d: %v", i, err)
+			continue
+		}
+
+		if report.HealthyClients == 0 {
+			report.BlockNumber = number
+		}
+		report.HealthyClients++
+	}
+	report.Healthy = report.HealthyClients > 0
+
+	return report
+}
@@ -0,0 +1,79 @@
+package transaction
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// Latency buckets grow geometrically from latencyMin by latencyGrowth,
+	// so a percentile is reported to within 20% of the recorded durations
+	latencyMin     = 100 * time.Microsecond
+	latencyGrowth  = 1.2
+	latencyBuckets = 80 // latencyMin * 1.2^79 is about 30 minutes
+)
+
+// latencyHistogram counts durations in fixed geometric buckets, so recording
+// is constant time and memory regardless of how many sends are made
+type latencyHistogram struct {
+	mu     sync.Mutex
+	counts [latencyBuckets]uint64
+	total  uint64
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	bucket := 0
+	if d > latencyMin {
+		bucket = int(math.Ceil(math.Log(float64(d)/float64(latencyMin)) / math.Log(latencyGrowth)))
+		if bucket >= latencyBuckets {
+			bucket = latencyBuckets - 1
+		}
+	}
+
+	h.mu.Lock()
+	h.counts[bucket]++
+	h.total++
+	h.mu.Unlock()
+}
+
+// percentile returns the upper bound of the bucket holding the p-th
+// percentile (0-100), or 0 if nothing was recorded
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(p / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen uint64
+	for bucket, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			return bucketBound(bucket)
+		}
+	}
+	return bucketBound(latencyBuckets - 1)
+}
+
+func bucketBound(bucket int) time.Duration {
+	return time.Duration(float64(latencyMin) * math.Pow(latencyGrowth, float64(bucket)))
+}
+
+// LatencyStats returns the p50, p95 and p99 durations of successful
+// SendWithContext calls, keyed "p50", "p95" and "p99". Values are bucket
+// upper bounds, within 20% of the recorded durations; all are zero before
+// the first send.
+func (m *Manager) LatencyStats() map[string]time.Duration {
+	return map[string]time.Duration{
+		"p50": m.metrics.latency.percentile(50),
+		"p95": m.metrics.latency.percentile(95),
+		"p99": m.metrics.latency.percentile(99),
+	}
+}
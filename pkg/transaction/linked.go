@@ -0,0 +1,82 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrBrokenChain is returned by VerifyChain when a message does not commit
+// to the one before it
+var ErrBrokenChain = errors.New("broken message chain")
+
+// LinkHash is the hash a linked message commits to: the Keccak-256 of the
+// previous transaction's custom data
+func LinkHash(tx *types.Transaction) (common.Hash, error) {
+	customData, err := GetCustomData(tx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(customData), nil
+}
+
+// EncodeLinked prefixes customData with prevHash, the LinkHash of the
+// previous message. The first message of a chain uses the zero hash.
+func EncodeLinked(prevHash common.Hash, customData []byte) []byte {
+	linked := make([]byte, 0, common.HashLength+len(customData))
+	linked = append(linked, prevHash.Bytes()...)
+	return append(linked, customData...)
+}
+
+// DecodeLinked splits custom data written by EncodeLinked into the previous
+// message's hash and the payload
+func DecodeLinked(linked []byte) (prevHash common.Hash, customData []byte, err error) {
+	if len(linked) < common.HashLength {
+		return common.Hash{}, nil, fmt.Errorf("linked custom data too short: %d bytes", len(linked))
+	}
+	return common.BytesToHash(linked[:common.HashLength]), linked[common.HashLength:], nil
+}
+
+// SendLinked sends customData as the next message of a hash chain, committing
+// to prevHash, the LinkHash of the previous message's transaction
+func (m *Manager) SendLinked(
+	ctx context.Context,
+	to common.Address,
+	value *big.Int,
+	customData []byte,
+	prevHash common.Hash,
+) (*types.Transaction, error) {
+	return m.SendWithContext(ctx, to, value, EncodeLinked(prevHash, customData), nil)
+}
+
+// VerifyChain checks that each transaction after the first commits to the
+// LinkHash of the one before it. It returns false with an ErrBrokenChain
+// error naming the first message whose link does not match.
+func VerifyChain(txs []*types.Transaction) (bool, error) {
+	for i := 1; i < len(txs); i++ {
+		want, err := LinkHash(txs[i-1])
+		if err != nil {
+			return false, fmt.Errorf("message %d: %w", i-1, err)
+		}
+
+		customData, err := GetCustomData(txs[i])
+		if err != nil {
+			return false, fmt.Errorf("message %d: %w", i, err)
+		}
+		prevHash, _, err := DecodeLinked(customData)
+		if err != nil {
+			return false, fmt.Errorf("message %d: %w", i, err)
+		}
+
+		if prevHash != want {
+			return false, fmt.Errorf("%w: message %d commits to %s, previous message hashes to %s",
+				ErrBrokenChain, i, prevHash.Hex(), want.Hex())
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,51 @@
+package transaction_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+func TestVerifyChain(t *testing.T) {
+	_, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	var chain []*types.Transaction
+	prev := common.Hash{}
+	for _, message := range []string{"first", "second", "third"} {
+		tx, err := mgr.SendLinked(ctx, testRecipient, nil, []byte(message), prev)
+		if err != nil {
+			t.Fatalf("SendLinked(%s) failed: %v", message, err)
+		}
+		if prev, err = transaction.LinkHash(tx); err != nil {
+			t.Fatalf("LinkHash failed: %v", err)
+		}
+		chain = append(chain, tx)
+	}
+
+	if ok, err := transaction.VerifyChain(chain); !ok || err != nil {
+		t.Fatalf("VerifyChain = %v, %v; want true", ok, err)
+	}
+
+	customData, _ := transaction.GetCustomData(chain[1])
+	if _, payload, err := transaction.DecodeLinked(customData); err != nil || string(payload) != "second" {
+		t.Errorf("DecodeLinked payload = %q, %v; want %q", payload, err, "second")
+	}
+
+	// A forged middle message can commit to the first, but the third no
+	// longer matches it
+	first, _ := transaction.LinkHash(chain[0])
+	forged, err := mgr.SendLinked(ctx, testRecipient, nil, []byte("forged"), first)
+	if err != nil {
+		t.Fatalf("SendLinked failed: %v", err)
+	}
+	ok, err := transaction.VerifyChain([]*types.Transaction{chain[0], forged, chain[2]})
+	if ok || !errors.Is(err, transaction.ErrBrokenChain) {
+		t.Errorf("VerifyChain with a tampered middle message = %v, %v; want ErrBrokenChain", ok, err)
+	}
+}
@@ -0,0 +1,54 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrLogNotFound is returned by VerifyProofWithLog when the transaction
+// emitted no log with the expected topic
+var ErrLogNotFound = errors.New("no log with the expected topic")
+
+// VerifyProofWithLog verifies proof like VerifyProof and additionally checks
+// that the transaction emitted a log with expectedTopic among its topics.
+// The logs are taken from the node's receipt, not trusted from the proof,
+// and the proof's Logs must match them.
+func (m *Manager) VerifyProofWithLog(proof *Proof, expectedTopic common.Hash) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	return m.VerifyProofWithLogContext(ctx, proof, expectedTopic)
+}
+
+func (m *Manager) VerifyProofWithLogContext(ctx context.Context, proof *Proof, expectedTopic common.Hash) (bool, error) {
+	if valid, err := m.VerifyProofWithContext(ctx, proof); !valid {
+		return false, err
+	}
+
+	receipt, err := m.getReceipt(ctx, proof.Transaction.Hash(), ProofOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to get receipt: %w", err)
+	}
+	if receipt.BlockHash != proof.BlockHash {
+		return false, fmt.Errorf("receipt is from block %s, proof is for %s", receipt.BlockHash.Hex(), proof.BlockHash.Hex())
+	}
+	if !slices.EqualFunc(receipt.Logs, proof.Logs, sameLog) {
+		return false, fmt.Errorf("proof logs do not match the receipt")
+	}
+
+	for _, log := range receipt.Logs {
+		if slices.Contains(log.Topics, expectedTopic) {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("%w: %s", ErrLogNotFound, expectedTopic.Hex())
+}
+
+// sameLog compares the consensus fields of two logs
+func sameLog(a, b *types.Log) bool {
+	return a.Address == b.Address && slices.Equal(a.Topics, b.Topics) && string(a.Data) == string(b.Data)
+}
@@ -2,15 +2,20 @@ package transaction
 
 import (
 	"context"
-	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math/big"
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/k4rz4/ethereum-custom-transactions/internal/nonce"
 	"github.com/k4rz4/ethereum-custom-transactions/internal/pool"
@@ -22,8 +27,74 @@ const (
 	DefaultGasLimit   = uint64(100_000)
 	BaseFeeMultiplier = 2
 	DefaultTimeout    = 30 * time.Second
+	// GasLimitMargin is added to the intrinsic gas of payloads too large
+	// for DefaultGasLimit, see GasLimitFor
+	GasLimitMargin = uint64(25_000)
+	// DefaultPollInterval is how often the manager polls the node while waiting
+	DefaultPollInterval = time.Second
+	// DefaultTreeCacheSize is how many block Merkle trees are kept by default
+	DefaultTreeCacheSize = 100
+	// DefaultWatchBackoff is the first delay before WatchCustomTransactions
+	// resubscribes; it doubles up to DefaultMaxWatchBackoff
+	DefaultWatchBackoff    = time.Second
+	DefaultMaxWatchBackoff = 30 * time.Second
 )
 
+// ErrIncompleteBlock is returned when a block's transactions do not match
+// the transaction root in its header
+var ErrIncompleteBlock = errors.New("block body does not match its header")
+
+// ErrReverted is returned when a mined transaction's receipt reports failure
+var ErrReverted = errors.New("transaction reverted")
+
+// ErrTooManyInFlight is returned by SendWithContext when the WithMaxInFlight
+// limit is reached and the manager is not configured to wait
+var ErrTooManyInFlight = errors.New("too many unconfirmed transactions")
+
+// ErrCustomDataMismatch is returned by AssertCustomData when a transaction
+// carries other custom data than expected
+var ErrCustomDataMismatch = errors.New("custom data mismatch")
+
+// ErrReceiptTimeout is returned by AwaitMined when no receipt was found
+// within the WithReceiptMaxAttempts limit
+var ErrReceiptTimeout = errors.New("receipt not found within the maximum attempts")
+
+// ErrZeroBalance is returned by NewManager under BalanceCheckError when the
+// signing account holds no ether on the connected chain
+var ErrZeroBalance = errors.New("account has zero balance")
+
+// ErrReservedNonceUsed is returned by SendIdempotent when the node already
+// counts the key's reserved nonce, e.g. because a process that crashed
+// after broadcasting sent it. The reservation is kept so the key is never
+// sent at another nonce.
+var ErrReservedNonceUsed = errors.New("reserved nonce already used")
+
+// BalanceCheck decides what NewManager does about a signing account with
+// zero balance, which could never pay for a send
+type BalanceCheck int
+
+const (
+	// BalanceCheckOff skips the check, for offline and test use
+	BalanceCheckOff BalanceCheck = iota
+	// BalanceCheckWarn logs a warning with the default slog logger
+	BalanceCheckWarn
+	// BalanceCheckError fails construction with ErrZeroBalance
+	BalanceCheckError
+)
+
+func (c BalanceCheck) String() string {
+	switch c {
+	case BalanceCheckOff:
+		return "off"
+	case BalanceCheckWarn:
+		return "warn"
+	case BalanceCheckError:
+		return "error"
+	default:
+		return fmt.Sprintf("BalanceCheck(%d)", int(c))
+	}
+}
+
 type Proof struct {
 	Transaction      *types.Transaction
 	BlockNumber      *big.Int
@@ -32,12 +103,32 @@ type Proof struct {
 	Receipt          *types.Receipt
 	CustomData       []byte
 	ProofPath        []common.Hash
+	// Logs are the logs the transaction emitted, from its receipt
+	Logs []*types.Log
+}
+
+// ProofOptions controls how GenerateProof and VerifyProof use the caches
+type ProofOptions struct {
+	// BypassCache refetches the receipt and block and rebuilds the Merkle tree
+	BypassCache bool
+	// PopulateCache stores results fetched while bypassing the cache
+	PopulateCache bool
+}
+
+// useCache reports whether cached entries may be read
+func (o ProofOptions) useCache() bool {
+	return !o.BypassCache
+}
+
+// storeCache reports whether fetched entries should be cached
+func (o ProofOptions) storeCache() bool {
+	return !o.BypassCache || o.PopulateCache
 }
 
 type Manager struct {
-	privateKey *ecdsa.PrivateKey
-	address    common.Address
-	chainID    *big.Int
+	signer  Signer
+	address common.Address
+	chainID *big.Int
 
 	clientPool   *pool.ClientPool
 	nonceManager *nonce.Manager
@@ -45,7 +136,60 @@ type Manager struct {
 	proofCache   *cache.ProofCache
 	blockCache   *cache.BlockCache
 	receiptCache *cache.ReceiptCache
-	treeCache    *sync.Map // stores common.Hash -> *merkle.Tree
+	treeCache    *cache.TreeCache
+	verifyCache  *cache.VerificationCache
+	// schemaBlooms holds, per scanned block, a bloom filter over the schema
+	// ids of its custom transactions
+	schemaBlooms *cache.BloomCache
+
+	gasStrategy   GasStrategy
+	sponsor       Sponsor
+	pollInterval  time.Duration
+	treeCacheSize int
+	// treeWorkers is how many goroutines build each Merkle tree
+	treeWorkers int
+	// blockFetches and treeBuilds let concurrent proofs and verifications
+	// of one block share a single block fetch and tree build
+	blockFetches singleflight.Group
+	treeBuilds   singleflight.Group
+	// maxInFlight limits unconfirmed transactions from SendWithContext (0
+	// means no limit); waitInFlight makes it wait rather than fail
+	maxInFlight  int
+	waitInFlight bool
+	// watchBackoff and maxWatchBackoff bound WatchCustomTransactions' delay
+	// between resubscribe attempts
+	watchBackoff    time.Duration
+	maxWatchBackoff time.Duration
+	// minTip and maxTip bound the tip chosen by the gas strategy; nil means
+	// unbounded
+	minTip *big.Int
+	maxTip *big.Int
+	// minBumpPercent is the smallest fee bump SpeedUp and Cancel accept
+	minBumpPercent int
+	// balanceCheck is what construction does about a zero balance
+	balanceCheck BalanceCheck
+	// receiptPollInterval and receiptMaxAttempts tune AwaitMined; a zero
+	// interval falls back to pollInterval and zero attempts means no limit
+	receiptPollInterval time.Duration
+	receiptMaxAttempts  int
+
+	// onNonceReset is called whenever a failed send resets the cached nonce
+	onNonceReset func(addr common.Address, reason error)
+
+	// nonceOpts configures the nonce manager, e.g. WithNonceResync
+	nonceOpts []nonce.Option
+
+	// strictScan makes scans use IsCustomTransactionStrict
+	strictScan bool
+	// streamConfirmations makes StreamCustomTransactions follow the head
+	// minus this many blocks instead of the finalized block; 0 means
+	// finalized
+	streamConfirmations uint64
+
+	// idempotent holds the send made for each SendIdempotent key
+	idempotent map[string]*idempotentSend
+	// ledger totals the gas paid by sent transactions
+	ledger gasLedger
 
 	metrics *Metrics
 	mu      sync.RWMutex
@@ -59,28 +203,248 @@ type Metrics struct {
 	CacheHits       uint64
 	CacheMisses     uint64
 	mu              sync.RWMutex
+
+	// latency records how long successful sends took
+	latency latencyHistogram
+}
+
+// Option configures optional Manager behaviour
+type Option func(*Manager)
+
+// WithGasStrategy sets how fee caps are chosen for sent transactions
+func WithGasStrategy(strategy GasStrategy) Option {
+	return func(m *Manager) {
+		m.gasStrategy = strategy
+	}
+}
+
+// WithSponsor routes every transaction the manager signs and sends through
+// sponsor instead of straight to the node (default DirectSponsor).
+// SendBatch is not routed, since it sends a single JSON-RPC batch.
+func WithSponsor(sponsor Sponsor) Option {
+	return func(m *Manager) {
+		m.sponsor = sponsor
+	}
+}
+
+// WithPollInterval sets how often the manager polls the node while waiting
+// for chain state to change (default DefaultPollInterval)
+func WithPollInterval(interval time.Duration) Option {
+	return func(m *Manager) {
+		if interval > 0 {
+			m.pollInterval = interval
+		}
+	}
+}
+
+// WithReceiptPollInterval sets how often AwaitMined asks for a receipt
+// (default the WithPollInterval interval), e.g. faster on local dev chains
+func WithReceiptPollInterval(interval time.Duration) Option {
+	return func(m *Manager) {
+		if interval > 0 {
+			m.receiptPollInterval = interval
+		}
+	}
+}
+
+// WithReceiptMaxAttempts makes AwaitMined give up with ErrReceiptTimeout
+// after asking for a receipt attempts times. By default it polls until its
+// context is done.
+func WithReceiptMaxAttempts(attempts int) Option {
+	return func(m *Manager) {
+		m.receiptMaxAttempts = attempts
+	}
+}
+
+// WithTreeCacheSize bounds how many block Merkle trees are cached
+// (default DefaultTreeCacheSize). Evicted trees are rebuilt on demand.
+func WithTreeCacheSize(size int) Option {
+	return func(m *Manager) {
+		m.treeCacheSize = size
+	}
+}
+
+// WithTreeWorkers builds block Merkle trees for proofs and verification
+// with up to n goroutines (default 1, sequential)
+func WithTreeWorkers(n int) Option {
+	return func(m *Manager) {
+		m.treeWorkers = n
+	}
+}
+
+// WithMaxInFlight limits how many transactions sent by SendWithContext may
+// be unconfirmed at once. A slot frees once the manager sees the
+// transaction's receipt. At the limit, SendWithContext fails with
+// ErrTooManyInFlight or, if wait is set, polls the pending receipts until
+// one is mined or ctx is done. A transaction and its replacements by
+// SpeedUp or Cancel share a slot, which frees once one of them is mined or
+// the account's confirmed nonce passes theirs.
+func WithMaxInFlight(limit int, wait bool) Option {
+	return func(m *Manager) {
+		m.maxInFlight = limit
+		m.waitInFlight = wait
+	}
+}
+
+// WithWatchBackoff sets the first delay before WatchCustomTransactions
+// resubscribes after a failure and the cap it doubles up to
+func WithWatchBackoff(initial, max time.Duration) Option {
+	return func(m *Manager) {
+		if initial > 0 {
+			m.watchBackoff = initial
+		}
+		if max >= m.watchBackoff {
+			m.maxWatchBackoff = max
+		}
+	}
+}
+
+// WithStreamConfirmations makes StreamCustomTransactions emit a block once
+// it is depth blocks below the head, for chains without a finalized tag or
+// consumers that want lower latency. A depth of 0 restores the default of
+// waiting for the finalized block.
+func WithStreamConfirmations(depth uint64) Option {
+	return func(m *Manager) {
+		m.streamConfirmations = depth
+	}
+}
+
+// WithMinTipWei raises any tip below min to min, e.g. on dev chains that
+// suggest a zero tip
+func WithMinTipWei(min *big.Int) Option {
+	return func(m *Manager) {
+		m.minTip = min
+	}
+}
+
+// WithMaxTipWei lowers any tip above max to max
+func WithMaxTipWei(max *big.Int) Option {
+	return func(m *Manager) {
+		m.maxTip = max
+	}
+}
+
+// WithMinBumpPercent sets the smallest fee bump SpeedUp and Cancel accept
+// (default DefaultMinBumpPercent), for nodes that require more than the
+// protocol minimum to replace a pending transaction
+func WithMinBumpPercent(percent int) Option {
+	return func(m *Manager) {
+		if percent > 0 {
+			m.minBumpPercent = percent
+		}
+	}
+}
+
+// WithOnNonceReset sets a hook called with the address and the error
+// whenever a failed send resets the cached nonce, to make nonce churn
+// observable
+func WithOnNonceReset(fn func(addr common.Address, reason error)) Option {
+	return func(m *Manager) {
+		m.onNonceReset = fn
+	}
+}
+
+// WithBalanceCheck makes NewManager check the signing account's balance and
+// warn or fail if it is zero (default BalanceCheckOff). Managers without a
+// signer are never checked.
+func WithBalanceCheck(check BalanceCheck) Option {
+	return func(m *Manager) {
+		m.balanceCheck = check
+	}
+}
+
+// WithNonceResync periodically resets the cached nonce when it drifts more
+// than tolerance ahead of the node's pending nonce, e.g. after many failed
+// sends under heavy concurrency
+func WithNonceResync(interval time.Duration, tolerance uint64) Option {
+	return func(m *Manager) {
+		m.nonceOpts = append(m.nonceOpts, nonce.WithResync(interval, tolerance))
+	}
+}
+
+// WithReservationStore persists the nonces SendIdempotent reserves in
+// store, e.g. a FileReservationStore, so a retry after a restart reuses the
+// nonce instead of sending twice. Without one, reservations last for the
+// lifetime of the Manager.
+func WithReservationStore(store nonce.Store) Option {
+	return func(m *Manager) {
+		m.nonceOpts = append(m.nonceOpts, nonce.WithStore(store))
+	}
+}
+
+// WithStrictScan makes ScanBlocks, StreamCustomTransactions,
+// WatchCustomTransactions, SchemaHistogram and AuditRange pick out custom
+// transactions with IsCustomTransactionStrict instead of the MagicBytes
+// prefix alone, so contract calls that happen to start with it are skipped
+func WithStrictScan(enabled bool) Option {
+	return func(m *Manager) {
+		m.strictScan = enabled
+	}
+}
+
+// SendOption adjusts a single send
+type SendOption func(*sendConfig)
+
+type sendConfig struct {
+	gasStrategy GasStrategy
+	// clientIndex selects the pooled client to broadcast with; negative
+	// means the next one in the pool
+	clientIndex int
+}
+
+// WithTipPercentile takes the tip from the given fee history reward
+// percentile (see SuggestTip) instead of the manager's gas strategy
+func WithTipPercentile(percentile float64) SendOption {
+	return func(c *sendConfig) {
+		c.gasStrategy = tipPercentileStrategy{percentile: percentile}
+	}
+}
+
+// WithClient broadcasts through pooled client index modulo the pool size
+// instead of the next client in the pool, e.g. to keep one connection per
+// worker. Fee and nonce lookups still use the pool.
+func WithClient(index int) SendOption {
+	return func(c *sendConfig) {
+		c.clientIndex = index
+	}
+}
+
+func (m *Manager) newSendConfig(opts []SendOption) sendConfig {
+	cfg := sendConfig{gasStrategy: m.gasStrategy, clientIndex: -1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
 }
 
 // NewManager creates an optimized transaction manager
 // rpcURL: Ethereum node RPC endpoint (e.g., "http://localhost:8545")
 // privateKeyHex: Private key in hex format (without 0x prefix)
 // poolSize: Number of client connections to pool (recommended: 5-10)
-func NewManager(rpcURL string, privateKeyHex string, poolSize int) (*Manager, error) {
-	if poolSize < 1 {
-		poolSize = 5
+// opts: Optional settings, e.g. WithGasStrategy
+func NewManager(rpcURL string, privateKeyHex string, poolSize int, opts ...Option) (*Manager, error) {
+	signer, err := NewKeySigner(privateKeyHex)
+	if err != nil {
+		return nil, err
 	}
+	return NewManagerWithSigner(rpcURL, signer, poolSize, opts...)
+}
 
-	privateKey, err := crypto.HexToECDSA(privateKeyHex)
-	if err != nil {
-		return nil, fmt.Errorf("invalid private key: %w", err)
+// NewManagerWithSigner creates a transaction manager that signs with signer
+// instead of an in-memory key; see NewManager for the other arguments
+func NewManagerWithSigner(rpcURL string, signer Signer, poolSize int, opts ...Option) (*Manager, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("signer is nil")
 	}
+	return newManager(rpcURL, signer, poolSize, opts...)
+}
 
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("failed to cast public key to ECDSA")
+// newManager creates a manager; a nil signer yields a manager that can
+// only read and verify, as used by NewVerifier
+func newManager(rpcURL string, signer Signer, poolSize int, opts ...Option) (*Manager, error) {
+	if poolSize < 1 {
+		poolSize = 5
 	}
-	address := crypto.PubkeyToAddress(*publicKeyECDSA)
 
 	clientPool, err := pool.New(rpcURL, poolSize)
 	if err != nil {
@@ -90,7 +454,7 @@ func NewManager(rpcURL string, privateKeyHex string, poolSize int) (*Manager, er
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	chainID, err := clientPool.Get().ChainID(ctx)
+	chainID, err := clientPool.VerifyChainID(ctx)
 	if err != nil {
 		clientPool.Close()
 		return nil, fmt.Errorf("failed to get chain ID: %w", err)
@@ -108,18 +472,86 @@ func NewManager(rpcURL string, privateKeyHex string, poolSize int) (*Manager, er
 		return nil, fmt.Errorf("failed to create receipt cache: %w", err)
 	}
 
-	return &Manager{
-		privateKey:   privateKey,
-		address:      address,
-		chainID:      chainID,
-		clientPool:   clientPool,
-		nonceManager: nonce.New(clientPool.Get()),
-		proofCache:   cache.NewProofCache(30 * time.Minute),
-		blockCache:   blockCache,
-		receiptCache: receiptCache,
-		treeCache:    &sync.Map{},
-		metrics:      &Metrics{},
-	}, nil
+	verifyCache, err := cache.NewVerificationCache(1000)
+	if err != nil {
+		clientPool.Close()
+		return nil, fmt.Errorf("failed to create verification cache: %w", err)
+	}
+
+	schemaBlooms, err := cache.NewBloomCache(10000)
+	if err != nil {
+		clientPool.Close()
+		return nil, fmt.Errorf("failed to create schema bloom cache: %w", err)
+	}
+
+	var address common.Address
+	if signer != nil {
+		address = signer.Address()
+	}
+
+	m := &Manager{
+		signer:          signer,
+		address:         address,
+		chainID:         chainID,
+		clientPool:      clientPool,
+		proofCache:      cache.NewProofCache(30 * time.Minute),
+		blockCache:      blockCache,
+		receiptCache:    receiptCache,
+		verifyCache:     verifyCache,
+		schemaBlooms:    schemaBlooms,
+		gasStrategy:     BaseFeeStrategy{},
+		sponsor:         DirectSponsor{},
+		pollInterval:    DefaultPollInterval,
+		treeCacheSize:   DefaultTreeCacheSize,
+		minBumpPercent:  DefaultMinBumpPercent,
+		watchBackoff:    DefaultWatchBackoff,
+		maxWatchBackoff: DefaultMaxWatchBackoff,
+		idempotent:      make(map[string]*idempotentSend),
+		metrics:         &Metrics{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.treeCache, err = cache.NewTreeCache(m.treeCacheSize)
+	if err != nil {
+		clientPool.Close()
+		return nil, fmt.Errorf("failed to create tree cache: %w", err)
+	}
+
+	m.nonceManager = nonce.New(clientPool.Get(), m.nonceOpts...)
+
+	if signer != nil && m.balanceCheck != BalanceCheckOff {
+		if err := m.checkBalance(ctx); err != nil {
+			m.Close()
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// checkBalance applies the WithBalanceCheck policy to the signing account
+func (m *Manager) checkBalance(ctx context.Context) error {
+	balance, err := m.clientPool.Get().BalanceAt(ctx, m.address, nil)
+	if err != nil {
+		if m.balanceCheck == BalanceCheckError {
+			return fmt.Errorf("failed to get balance: %w", err)
+		}
+		slog.Warn("could not check account balance", "address", m.address, "err", err)
+		return nil
+	}
+	if balance.Sign() > 0 {
+		return nil
+	}
+
+	if m.balanceCheck == BalanceCheckError {
+		return fmt.Errorf("%w: %s on chain %s", ErrZeroBalance, m.address.Hex(), m.chainID)
+	}
+	slog.Warn("account has zero balance and cannot pay for transactions",
+		"address", m.address, "chainID", m.chainID)
+	return nil
 }
 
 func (m *Manager) Send(
@@ -137,39 +569,211 @@ func (m *Manager) SendWithContext(
 	to common.Address,
 	value *big.Int,
 	customData, data []byte,
+	opts ...SendOption,
 ) (*types.Transaction, error) {
 	if value == nil {
 		value = big.NewInt(0)
 	}
+	cfg := m.newSendConfig(opts)
+	start := time.Now()
 
+	if m.maxInFlight > 0 {
+		if err := m.reserveInFlight(ctx, m.maxInFlight, m.waitInFlight, m.pollInterval); err != nil {
+			return nil, err
+		}
+	}
+
+	signedTx, err := m.sendNext(ctx, cfg, to, value, customData, data)
+	if err != nil {
+		if m.maxInFlight > 0 {
+			m.ledger.release()
+		}
+		return nil, err
+	}
+
+	if m.maxInFlight > 0 {
+		m.ledger.trackReserved(signedTx)
+	} else {
+		m.ledger.track(signedTx)
+	}
+	m.metrics.latency.record(time.Since(start))
+	return signedTx, nil
+}
+
+// sendNext signs and sends a custom transaction at the next managed nonce
+func (m *Manager) sendNext(
+	ctx context.Context,
+	cfg sendConfig,
+	to common.Address,
+	value *big.Int,
+	customData, data []byte,
+) (*types.Transaction, error) {
 	nonce, err := m.nonceManager.GetNext(m.address)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get nonce: %w", err)
 	}
 
-	client := m.clientPool.Get()
+	signedTx, err := m.signCustomTx(ctx, cfg.gasStrategy, nonce, to, value, customData, data)
+	if err != nil {
+		m.resetNonce(err)
+		return nil, err
+	}
 
-	gasTipCap, err := client.SuggestGasTipCap(ctx)
+	// Send transaction
+	err = m.broadcastFor(ctx, cfg, signedTx)
 	if err != nil {
-		m.nonceManager.Reset(m.address)
-		return nil, fmt.Errorf("failed to get gas tip: %w", err)
+		m.resetNonce(err)
+		m.metrics.IncrementTxFailed()
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
 	}
 
-	head, err := client.HeaderByNumber(ctx, nil)
+	m.metrics.IncrementTxSent()
+	return signedTx, nil
+}
+
+// resetNonce drops the cached nonce after a failed send and reports it to
+// the OnNonceReset hook
+func (m *Manager) resetNonce(reason error) {
+	m.nonceManager.Reset(m.address)
+	if m.onNonceReset != nil {
+		m.onNonceReset(m.address, reason)
+	}
+}
+
+// SendWithNonce sends a custom transaction at exactly nonce, e.g. to fill a
+// gap or replace a pending transaction. The nonce manager is neither
+// consulted nor updated, so the caller owns the consequences: a nonce at or
+// above the next managed nonce will collide with a later Send, and a nonce
+// already pending replaces that transaction only if it pays enough more.
+func (m *Manager) SendWithNonce(
+	ctx context.Context,
+	nonce uint64,
+	to common.Address,
+	value *big.Int,
+	customData, data []byte,
+	opts ...SendOption,
+) (*types.Transaction, error) {
+	if value == nil {
+		value = big.NewInt(0)
+	}
+	cfg := m.newSendConfig(opts)
+
+	signedTx, err := m.signCustomTx(ctx, cfg.gasStrategy, nonce, to, value, customData, data)
 	if err != nil {
-		m.nonceManager.Reset(m.address)
-		return nil, fmt.Errorf("failed to get block header: %w", err)
+		return nil, err
 	}
 
-	if head.BaseFee == nil {
-		m.nonceManager.Reset(m.address)
-		return nil, fmt.Errorf("base fee is nil, chain may not support EIP-1559")
+	if err := m.broadcastFor(ctx, cfg, signedTx); err != nil {
+		m.metrics.IncrementTxFailed()
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
 	}
 
-	gasFeeCap := new(big.Int).Add(
-		gasTipCap,
-		new(big.Int).Mul(head.BaseFee, big.NewInt(BaseFeeMultiplier)),
-	)
+	m.ledger.track(signedTx)
+	m.metrics.IncrementTxSent()
+	return signedTx, nil
+}
+
+// idempotentSend serialises the SendIdempotent calls for one key
+type idempotentSend struct {
+	mu sync.Mutex
+	// tx is the transaction broadcast for the key, nil until one succeeds
+	tx *types.Transaction
+}
+
+// SendIdempotent sends a custom transaction at most once per key. The first
+// call reserves a nonce for key and signs and broadcasts the transaction;
+// later calls with the same key rebroadcast that transaction if the node no
+// longer knows it, and return it with the same hash. If signing or the
+// broadcast fails the reservation is released, so a retry starts afresh.
+// Reservations are kept in memory unless WithReservationStore persists
+// them; a retry after a restart then reuses the reserved nonce, so at most
+// one of the key's transactions can be mined. A reservation is dropped once
+// the account's confirmed nonce passes it, after which only this Manager
+// still recognises the key.
+func (m *Manager) SendIdempotent(
+	ctx context.Context,
+	key string,
+	to common.Address,
+	value *big.Int,
+	customData, data []byte,
+) (*types.Transaction, error) {
+	if value == nil {
+		value = big.NewInt(0)
+	}
+
+	m.mu.Lock()
+	send, exists := m.idempotent[key]
+	if !exists {
+		send = &idempotentSend{}
+		m.idempotent[key] = send
+	}
+	m.mu.Unlock()
+
+	send.mu.Lock()
+	defer send.mu.Unlock()
+
+	if send.tx != nil {
+		if _, _, err := m.clientPool.Get().TransactionByHash(ctx, send.tx.Hash()); err == nil {
+			return send.tx, nil
+		}
+		if err := m.broadcast(ctx, send.tx); err != nil {
+			m.metrics.IncrementTxFailed()
+			return nil, fmt.Errorf("failed to resend transaction: %w", err)
+		}
+		return send.tx, nil
+	}
+
+	nonce, err := m.nonceManager.Reserve(m.address, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve nonce: %w", err)
+	}
+
+	signedTx, err := m.signCustomTx(ctx, m.gasStrategy, nonce, to, value, customData, data)
+	if err != nil {
+		return nil, m.releaseReservation(key, err)
+	}
+
+	if err := m.broadcast(ctx, signedTx); err != nil {
+		m.metrics.IncrementTxFailed()
+		if pending, pendingErr := m.clientPool.Get().PendingNonceAt(ctx, m.address); pendingErr == nil && pending > nonce {
+			return nil, fmt.Errorf("%w: nonce %d for key %q: %w", ErrReservedNonceUsed, nonce, key, err)
+		}
+		return nil, m.releaseReservation(key, fmt.Errorf("failed to send transaction: %w", err))
+	}
+
+	send.tx = signedTx
+	m.ledger.track(signedTx)
+	m.metrics.IncrementTxSent()
+	return signedTx, nil
+}
+
+// releaseReservation gives up key's nonce after its send failed with
+// reason, resetting the cached nonce as a failed Send does. It returns
+// reason, joined with any error releasing the reservation.
+func (m *Manager) releaseReservation(key string, reason error) error {
+	err := m.nonceManager.Release(m.address, key)
+	m.resetNonce(reason)
+	if err != nil {
+		return fmt.Errorf("%w (and failed to release nonce: %w)", reason, err)
+	}
+	return reason
+}
+
+// signCustomTx builds a custom transaction at nonce with the strategy's fee
+// caps and signs it with the manager's key
+func (m *Manager) signCustomTx(
+	ctx context.Context,
+	strategy GasStrategy,
+	nonce uint64,
+	to common.Address,
+	value *big.Int,
+	customData, data []byte,
+) (*types.Transaction, error) {
+	gasTipCap, gasFeeCap, err := strategy.FeeCaps(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	gasTipCap, gasFeeCap = m.clampTip(gasTipCap, gasFeeCap)
 
 	// Create custom transaction
 	tx := NewCustomTransaction(
@@ -177,29 +781,46 @@ func (m *Manager) SendWithContext(
 		nonce,
 		&to,
 		value,
-		DefaultGasLimit,
+		GasLimitFor(EncodeCustomData(data, customData)),
 		gasTipCap,
 		gasFeeCap,
 		data,
 		customData,
 	)
 
-	signedTx, err := types.SignTx(tx, types.NewLondonSigner(m.chainID), m.privateKey)
+	signedTx, err := m.signer.SignTx(tx, m.chainID)
 	if err != nil {
-		m.nonceManager.Reset(m.address)
 		return nil, fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
-	// Send transaction
-	err = client.SendTransaction(ctx, signedTx)
+	return signedTx, nil
+}
+
+// SendAndWait sends a custom transaction and waits for it to be mined. If the
+// transaction reverts, the receipt is returned along with an error wrapping
+// ErrReverted.
+func (m *Manager) SendAndWait(
+	ctx context.Context,
+	to common.Address,
+	value *big.Int,
+	customData, data []byte,
+	opts ...SendOption,
+) (*types.Transaction, *types.Receipt, error) {
+	tx, err := m.SendWithContext(ctx, to, value, customData, data, opts...)
 	if err != nil {
-		m.nonceManager.Reset(m.address)
-		m.metrics.IncrementTxFailed()
-		return nil, fmt.Errorf("failed to send transaction: %w", err)
+		return nil, nil, err
 	}
 
-	m.metrics.IncrementTxSent()
-	return signedTx, nil
+	receipt, err := m.AwaitMined(ctx, tx.Hash())
+	if err != nil {
+		return tx, nil, err
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return tx, receipt, fmt.Errorf("transaction %s: %w", tx.Hash().Hex(), ErrReverted)
+	}
+
+	return tx, receipt, nil
 }
 
 func (m *Manager) GenerateProof(txHash common.Hash) (*Proof, error) {
@@ -211,21 +832,43 @@ func (m *Manager) GenerateProof(txHash common.Hash) (*Proof, error) {
 func (m *Manager) GenerateProofWithContext(
 	ctx context.Context,
 	txHash common.Hash,
+) (*Proof, error) {
+	return m.GenerateProofWithOptions(ctx, txHash, ProofOptions{})
+}
+
+// GenerateProofNoCache generates a fresh proof, ignoring cached proofs,
+// receipts, blocks and trees (e.g. after a suspected reorg). The result is
+// not cached.
+func (m *Manager) GenerateProofNoCache(ctx context.Context, txHash common.Hash) (*Proof, error) {
+	return m.GenerateProofWithOptions(ctx, txHash, ProofOptions{BypassCache: true})
+}
+
+func (m *Manager) GenerateProofWithOptions(
+	ctx context.Context,
+	txHash common.Hash,
+	opts ProofOptions,
 ) (*Proof, error) {
 	// Check cache first
-	if cached, exists := m.proofCache.Get(txHash); exists {
-		m.metrics.IncrementCacheHits()
-		proof, ok := cached.(*Proof)
-		if !ok {
-			return nil, fmt.Errorf("invalid cached proof type")
+	if opts.useCache() {
+		if cached, exists := m.proofCache.Get(txHash); exists {
+			m.metrics.IncrementCacheHits()
+			proof, ok := cached.(*Proof)
+			if !ok {
+				return nil, fmt.Errorf("invalid cached proof type")
+			}
+			return proof, nil
 		}
-		return proof, nil
-	}
 
-	m.metrics.IncrementCacheMisses()
+		m.metrics.IncrementCacheMisses()
+	}
 
 	// Get receipt
-	receipt, err := m.getReceipt(ctx, txHash)
+	receipt, err := m.getReceipt(ctx, txHash, opts)
+	if errors.Is(err, ethereum.NotFound) {
+		if replaced, findErr := m.findReplacement(ctx, txHash); findErr == nil && replaced != nil {
+			return nil, replaced
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get receipt: %w", err)
 	}
@@ -240,11 +883,27 @@ func (m *Manager) GenerateProofWithContext(
 	}
 
 	// Get Merkle tree for this block
-	tree, err := m.getMerkleTree(ctx, receipt.BlockHash)
+	tree, err := m.getMerkleTree(ctx, receipt.BlockHash, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get merkle tree: %w", err)
 	}
 
+	proof, err := buildProof(tx, receipt, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.storeCache() {
+		m.cacheProof(proof)
+	}
+	m.metrics.IncrementProofsGenerated()
+
+	return proof, nil
+}
+
+// buildProof assembles the proof for a mined transaction from its receipt
+// and its block's Merkle tree
+func buildProof(tx *types.Transaction, receipt *types.Receipt, tree *merkle.Tree) (*Proof, error) {
 	// Generate proof path
 	proofPath := tree.GenerateProof(receipt.TransactionIndex)
 	if proofPath == nil {
@@ -257,7 +916,7 @@ func (m *Manager) GenerateProofWithContext(
 		return nil, fmt.Errorf("failed to extract custom data: %w", err)
 	}
 
-	proof := &Proof{
+	return &Proof{
 		Transaction:      tx,
 		BlockNumber:      receipt.BlockNumber,
 		BlockHash:        receipt.BlockHash,
@@ -265,12 +924,8 @@ func (m *Manager) GenerateProofWithContext(
 		Receipt:          receipt,
 		CustomData:       customData,
 		ProofPath:        proofPath,
-	}
-
-	m.proofCache.Set(txHash, proof)
-	m.metrics.IncrementProofsGenerated()
-
-	return proof, nil
+		Logs:             receipt.Logs,
+	}, nil
 }
 
 func (m *Manager) VerifyProof(proof *Proof) (bool, error) {
@@ -280,57 +935,323 @@ func (m *Manager) VerifyProof(proof *Proof) (bool, error) {
 }
 
 func (m *Manager) VerifyProofWithContext(ctx context.Context, proof *Proof) (bool, error) {
+	return m.VerifyProofWithOptions(ctx, proof, ProofOptions{})
+}
+
+func (m *Manager) VerifyProofWithOptions(ctx context.Context, proof *Proof, opts ProofOptions) (bool, error) {
+	result, err := m.verifyProof(ctx, proof, opts)
+	if err != nil {
+		return false, err
+	}
+	if result.failure != nil {
+		return false, result.failure
+	}
+	return true, nil
+}
+
+// VerifyResult reports each check VerifyProof makes
+type VerifyResult struct {
+	// MerkleValid is true if the proof path leads to the block's Merkle root
+	MerkleValid bool
+	// HashMatches is true if the block holds the transaction at the index
+	HashMatches bool
+	// ReceiptMatches is true if the receipt is for the transaction
+	ReceiptMatches bool
+	// CustomDataMatches is true if the custom data is the transaction's
+	CustomDataMatches bool
+
+	// failure describes the first failed check, in VerifyProof's order
+	failure error
+}
+
+// Valid reports whether every check passed
+func (r *VerifyResult) Valid() bool {
+	return r.MerkleValid && r.HashMatches && r.ReceiptMatches && r.CustomDataMatches
+}
+
+// VerifyProofDetailed runs every check VerifyProof makes and reports each
+// one. The error is only set when the proof cannot be checked at all, e.g.
+// the block cannot be fetched.
+func (m *Manager) VerifyProofDetailed(proof *Proof) (*VerifyResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	return m.VerifyProofDetailedWithContext(ctx, proof)
+}
+
+func (m *Manager) VerifyProofDetailedWithContext(ctx context.Context, proof *Proof) (*VerifyResult, error) {
+	return m.verifyProof(ctx, proof, ProofOptions{})
+}
+
+func (m *Manager) verifyProof(ctx context.Context, proof *Proof, opts ProofOptions) (*VerifyResult, error) {
 	if proof == nil {
-		return false, fmt.Errorf("proof is nil")
+		return nil, fmt.Errorf("proof is nil")
+	}
+	if proof.Transaction == nil || proof.Receipt == nil {
+		return nil, fmt.Errorf("proof is missing its transaction or receipt")
+	}
+
+	key := proofKey(proof)
+	if opts.useCache() && m.verifyCache.Contains(key) {
+		return &VerifyResult{MerkleValid: true, HashMatches: true, ReceiptMatches: true, CustomDataMatches: true}, nil
 	}
 
-	block, err := m.getBlock(ctx, proof.BlockHash)
+	block, err := m.getBlock(ctx, proof.BlockHash, opts)
 	if err != nil {
-		return false, fmt.Errorf("failed to get block: %w", err)
+		return nil, fmt.Errorf("failed to get block: %w", err)
 	}
 
-	if proof.TransactionIndex >= uint(len(block.Transactions())) {
-		return false, fmt.Errorf("transaction index %d out of range (block has %d transactions)",
-			proof.TransactionIndex, len(block.Transactions()))
+	tree, err := m.getMerkleTree(ctx, proof.BlockHash, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merkle tree: %w", err)
+	}
+
+	result := &VerifyResult{}
+	fail := func(err error) {
+		if result.failure == nil {
+			result.failure = err
+		}
 	}
 
-	tx := block.Transactions()[proof.TransactionIndex]
-	if tx.Hash() != proof.Transaction.Hash() {
-		return false, fmt.Errorf("transaction hash mismatch")
+	if proof.TransactionIndex >= uint(len(block.Transactions())) {
+		fail(fmt.Errorf("transaction index %d out of range (block has %d transactions)",
+			proof.TransactionIndex, len(block.Transactions())))
+	} else if block.Transactions()[proof.TransactionIndex].Hash() != proof.Transaction.Hash() {
+		fail(fmt.Errorf("transaction hash mismatch"))
+	} else {
+		result.HashMatches = true
 	}
 
 	if proof.Receipt.TxHash != proof.Transaction.Hash() {
-		return false, fmt.Errorf("receipt transaction hash mismatch")
+		fail(fmt.Errorf("receipt transaction hash mismatch"))
+	} else {
+		result.ReceiptMatches = true
 	}
 
-	tree, err := m.getMerkleTree(ctx, proof.BlockHash)
-	if err != nil {
-		return false, fmt.Errorf("failed to get merkle tree: %w", err)
+	// Bound to the index, so the path must also be the block's own path for
+	// the transaction the block holds there
+	if err := tree.CheckProofAt(tree.Leaf(proof.Transaction), proof.TransactionIndex, proof.ProofPath); err != nil {
+		fail(fmt.Errorf("merkle proof verification failed: %w", err))
+	} else {
+		result.MerkleValid = true
+	}
+
+	if err := checkCustomData(proof); err != nil {
+		fail(err)
+	} else {
+		result.CustomDataMatches = true
 	}
 
-	isValid := tree.VerifyProof(proof.Transaction.Hash(), proof.TransactionIndex, proof.ProofPath)
-	if !isValid {
-		return false, fmt.Errorf("merkle proof verification failed")
+	if result.Valid() && opts.storeCache() {
+		m.verifyCache.Add(key, proof.BlockHash)
 	}
 
+	return result, nil
+}
+
+// checkCustomData compares the proof's custom data with the transaction's
+func checkCustomData(proof *Proof) error {
 	extractedData, err := GetCustomData(proof.Transaction)
 	if err != nil {
-		return false, fmt.Errorf("failed to extract custom data: %w", err)
+		return fmt.Errorf("failed to extract custom data: %w", err)
 	}
 
 	if len(extractedData) != len(proof.CustomData) {
-		return false, fmt.Errorf("custom data length mismatch")
+		return fmt.Errorf("custom data length mismatch")
 	}
 
 	for i := range extractedData {
 		if extractedData[i] != proof.CustomData[i] {
-			return false, fmt.Errorf("custom data mismatch at byte %d", i)
+			return fmt.Errorf("custom data mismatch at byte %d", i)
 		}
 	}
 
+	return nil
+}
+
+// proofKey identifies a proof by the content VerifyProof checks
+func proofKey(proof *Proof) common.Hash {
+	buf := make([]byte, 0, 3*common.HashLength+8+len(proof.ProofPath)*common.HashLength+len(proof.CustomData))
+	if proof.Transaction != nil {
+		buf = append(buf, proof.Transaction.Hash().Bytes()...)
+	}
+	if proof.Receipt != nil {
+		buf = append(buf, proof.Receipt.TxHash.Bytes()...)
+	}
+	buf = append(buf, proof.BlockHash.Bytes()...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(proof.TransactionIndex))
+	for _, hash := range proof.ProofPath {
+		buf = append(buf, hash.Bytes()...)
+	}
+	buf = append(buf, proof.CustomData...)
+	return crypto.Keccak256Hash(buf)
+}
+
+// cacheProof caches proof by transaction hash and by block and index
+func (m *Manager) cacheProof(proof *Proof) {
+	m.proofCache.SetAt(proof.Transaction.Hash(), proof.BlockHash, proof.TransactionIndex, proof)
+}
+
+// GetCachedProofByIndex returns the cached proof for the transaction at
+// index in blockHash, without contacting the node
+func (m *Manager) GetCachedProofByIndex(blockHash common.Hash, index uint) (*Proof, bool) {
+	cached, ok := m.proofCache.GetByIndex(blockHash, index)
+	if !ok {
+		return nil, false
+	}
+	proof, ok := cached.(*Proof)
+	return proof, ok
+}
+
+// InvalidateBlock drops the cached block, Merkle tree, schema filter,
+// proofs, receipts and verification results for blockHash, e.g. after it
+// was reorganised out of the chain
+func (m *Manager) InvalidateBlock(blockHash common.Hash) {
+	m.blockCache.Delete(blockHash)
+	m.proofCache.InvalidateBlock(blockHash)
+	m.receiptCache.InvalidateBlock(blockHash)
+	m.treeCache.Delete(blockHash)
+	m.schemaBlooms.Delete(blockHash)
+	m.verifyCache.InvalidateBlock(blockHash)
+}
+
+// VerifyCachedBlock checks that the block cached under blockHash really
+// hashes to it and that its body matches its header. A block failing either
+// check is invalidated and false is returned. It errors if no block is
+// cached under blockHash.
+func (m *Manager) VerifyCachedBlock(blockHash common.Hash) (bool, error) {
+	block, ok := m.blockCache.Get(blockHash)
+	if !ok {
+		return false, fmt.Errorf("block %s is not cached", blockHash.Hex())
+	}
+
+	if block.Hash() != blockHash || checkBody(block) != nil {
+		m.InvalidateBlock(blockHash)
+		return false, nil
+	}
 	return true, nil
 }
 
+// WaitForNonce blocks until the confirmed nonce of addr reaches target,
+// polling NonceAt. It returns the context error if ctx expires first.
+func (m *Manager) WaitForNonce(ctx context.Context, addr common.Address, target uint64) error {
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		current, err := m.clientPool.Get().NonceAt(ctx, addr, nil)
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to get nonce: %w", err)
+		}
+		if err == nil && current >= target {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("nonce of %s did not reach %d: %w", addr.Hex(), target, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetCustomDataByHash returns the decoded custom data of the transaction
+// txHash. It returns an error wrapping ErrNotCustomData if the transaction
+// is not a custom transaction.
+func (m *Manager) GetCustomDataByHash(ctx context.Context, txHash common.Hash) ([]byte, error) {
+	tx, err := m.getTransaction(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	if !IsCustomTransaction(tx) {
+		return nil, fmt.Errorf("transaction %s: %w", txHash.Hex(), ErrNotCustomData)
+	}
+
+	return GetCustomData(tx)
+}
+
+// AssertCustomData fetches txHash and checks that its custom data equals
+// expected. A mismatch returns an error wrapping ErrCustomDataMismatch that
+// gives both lengths and the first differing byte. A transaction without
+// custom data matches only an empty expectation.
+func (m *Manager) AssertCustomData(ctx context.Context, txHash common.Hash, expected []byte) error {
+	tx, err := m.getTransaction(ctx, txHash)
+	if err != nil {
+		return fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	customData, err := GetCustomData(tx)
+	if err != nil {
+		return fmt.Errorf("failed to extract custom data: %w", err)
+	}
+
+	for i := 0; i < len(customData) && i < len(expected); i++ {
+		if customData[i] != expected[i] {
+			return fmt.Errorf("%w: transaction %s: byte %d is 0x%02x, want 0x%02x (length %d, want %d)",
+				ErrCustomDataMismatch, txHash.Hex(), i, customData[i], expected[i], len(customData), len(expected))
+		}
+	}
+	if len(customData) != len(expected) {
+		return fmt.Errorf("%w: transaction %s: length %d, want %d",
+			ErrCustomDataMismatch, txHash.Hex(), len(customData), len(expected))
+	}
+
+	return nil
+}
+
+// AwaitMined polls for the receipt of txHash until it is mined, ctx is done
+// or the WithReceiptMaxAttempts limit is reached. The receipt is returned
+// whatever its status.
+func (m *Manager) AwaitMined(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	interval := m.receiptPollInterval
+	if interval <= 0 {
+		interval = m.pollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for attempt := 1; ; attempt++ {
+		receipt, err := m.clientPool.Get().TransactionReceipt(ctx, txHash)
+		if err == nil {
+			m.receiptCache.Set(txHash, receipt)
+			m.ledger.observe(receipt)
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) && ctx.Err() == nil {
+			return nil, fmt.Errorf("failed to get receipt: %w", err)
+		}
+		if m.receiptMaxAttempts > 0 && attempt >= m.receiptMaxAttempts {
+			return nil, fmt.Errorf("transaction %s not mined after %d attempts: %w", txHash.Hex(), attempt, ErrReceiptTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("transaction %s not mined: %w", txHash.Hex(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// PreviewNonces returns the nonces the next n sends from the manager's
+// address would get, without taking them. Sends made in the meantime,
+// including concurrent ones, shift the assignment.
+func (m *Manager) PreviewNonces(n int) ([]uint64, error) {
+	nonces, err := m.nonceManager.Peek(m.address, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nonce: %w", err)
+	}
+	return nonces, nil
+}
+
+// SyncNonce overwrites the cached nonce with the node's pending nonce, so
+// sends recover after transactions from the same account were sent by
+// another tool. Sends made while the node is queried are not undone: the
+// cache then only moves forward. It returns the nonce the next send will
+// use.
+func (m *Manager) SyncNonce(ctx context.Context) (uint64, error) {
+	return m.nonceManager.Sync(ctx, m.address)
+}
+
 func (m *Manager) Address() common.Address {
 	return m.address
 }
@@ -344,54 +1265,151 @@ func (m *Manager) Metrics() map[string]uint64 {
 }
 
 func (m *Manager) Close() error {
+	m.nonceManager.Close()
 	return m.clientPool.Close()
 }
 
-func (m *Manager) getReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+func (m *Manager) getReceipt(ctx context.Context, txHash common.Hash, opts ProofOptions) (*types.Receipt, error) {
 	// Check cache
-	if cached, ok := m.receiptCache.Get(txHash); ok {
-		return cached, nil
+	if opts.useCache() {
+		if cached, ok := m.receiptCache.Get(txHash); ok {
+			return cached, nil
+		}
 	}
 
 	receipt, err := m.clientPool.Get().TransactionReceipt(ctx, txHash)
 	if err != nil {
 		return nil, err
 	}
+	m.ledger.observe(receipt)
 
-	m.receiptCache.Set(txHash, receipt)
+	if opts.storeCache() {
+		m.receiptCache.Set(txHash, receipt)
+	}
 	return receipt, nil
 }
 
-func (m *Manager) getBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
+// getTransaction looks txHash up in the proof, receipt and block caches
+// before asking the node
+func (m *Manager) getTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, error) {
+	if cached, ok := m.proofCache.Get(txHash); ok {
+		if proof, ok := cached.(*Proof); ok {
+			return proof.Transaction, nil
+		}
+	}
+
+	if receipt, ok := m.receiptCache.Get(txHash); ok {
+		if block, ok := m.blockCache.Get(receipt.BlockHash); ok {
+			if tx := block.Transaction(txHash); tx != nil {
+				return tx, nil
+			}
+		}
+	}
+
+	tx, _, err := m.clientPool.Get().TransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (m *Manager) getBlock(ctx context.Context, blockHash common.Hash, opts ProofOptions) (*types.Block, error) {
+	if !opts.useCache() {
+		return m.fetchBlock(ctx, blockHash, opts)
+	}
+
 	if cached, ok := m.blockCache.Get(blockHash); ok {
 		return cached, nil
 	}
+	block, err := shareCall(ctx, &m.blockFetches, blockHash.Hex(), func(ctx context.Context) (interface{}, error) {
+		return m.fetchBlock(ctx, blockHash, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return block.(*types.Block), nil
+}
 
+// fetchBlock fetches and checks a block, caching it if opts allow
+func (m *Manager) fetchBlock(ctx context.Context, blockHash common.Hash, opts ProofOptions) (*types.Block, error) {
 	block, err := m.clientPool.Get().BlockByHash(ctx, blockHash)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkBody(block); err != nil {
+		return nil, err
+	}
 
-	m.blockCache.Set(blockHash, block)
+	if opts.storeCache() {
+		m.blockCache.Set(blockHash, block)
+	}
 	return block, nil
 }
 
-func (m *Manager) getMerkleTree(ctx context.Context, blockHash common.Hash) (*merkle.Tree, error) {
-	if cached, ok := m.treeCache.Load(blockHash); ok {
-		tree, ok := cached.(*merkle.Tree)
-		if !ok {
-			return nil, fmt.Errorf("invalid cached tree type")
+// checkBody rejects blocks whose transactions do not hash to the header's
+// transaction root, so a partially populated body never yields a wrong tree
+func checkBody(block *types.Block) error {
+	txHash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil))
+	if txHash != block.TxHash() {
+		return fmt.Errorf("%w: block %s has %d transactions, root %s, header root %s",
+			ErrIncompleteBlock, block.Hash().Hex(), len(block.Transactions()), txHash.Hex(), block.TxHash().Hex())
+	}
+	return nil
+}
+
+func (m *Manager) getMerkleTree(ctx context.Context, blockHash common.Hash, opts ProofOptions) (*merkle.Tree, error) {
+	if opts.useCache() {
+		if cached, ok := m.treeCache.Get(blockHash); ok {
+			return cached, nil
 		}
-		return tree, nil
 	}
 
-	block, err := m.getBlock(ctx, blockHash)
+	if !opts.useCache() {
+		return m.buildMerkleTree(ctx, blockHash, opts)
+	}
+
+	// Concurrent callers missing the cache for the same block wait for one
+	// build instead of each hashing the whole block
+	tree, err := shareCall(ctx, &m.treeBuilds, blockHash.Hex(), func(ctx context.Context) (interface{}, error) {
+		return m.buildMerkleTree(ctx, blockHash, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tree.(*merkle.Tree), nil
+}
+
+// shareCall runs fn once for all concurrent callers with the same key. fn
+// runs detached from the caller that started it, bounded by DefaultTimeout,
+// so one caller giving up does not fail the others; each caller still
+// returns as soon as its own ctx is done.
+func shareCall(ctx context.Context, group *singleflight.Group, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	results := group.DoChan(key, func() (interface{}, error) {
+		sharedCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultTimeout)
+		defer cancel()
+		return fn(sharedCtx)
+	})
+
+	select {
+	case res := <-results:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// buildMerkleTree fetches the block and builds its tree, caching it if opts
+// allow
+func (m *Manager) buildMerkleTree(ctx context.Context, blockHash common.Hash, opts ProofOptions) (*merkle.Tree, error) {
+	block, err := m.getBlock(ctx, blockHash, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	tree := merkle.NewTree(block.Transactions())
-	m.treeCache.Store(blockHash, tree)
+	tree := merkle.NewTree(block.Transactions(), merkle.WithWorkers(m.treeWorkers))
+	if opts.storeCache() {
+		m.treeCache.Set(blockHash, tree)
+	}
 
 	return tree, nil
 }
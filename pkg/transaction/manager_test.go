@@ -0,0 +1,1176 @@
+package transaction_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/k4rz4/ethereum-custom-transactions/internal/ethtest"
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+func TestGenerateProofNoCache(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("payload"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	backend.Mine()
+
+	stale, err := mgr.GenerateProofWithContext(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+
+	// Reorg: the transaction is re-mined at another index in a new block
+	key, _ := crypto.GenerateKey()
+	backend.Rewind(0)
+	backend.AddBlock(signedTx(t, backend, key, 0, nil), tx)
+
+	if cached, _ := mgr.GenerateProofWithContext(ctx, tx.Hash()); cached != stale {
+		t.Fatal("expected the cached proof before bypassing")
+	}
+
+	fresh, err := mgr.GenerateProofNoCache(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("GenerateProofNoCache failed: %v", err)
+	}
+	if fresh.BlockHash == stale.BlockHash || fresh.TransactionIndex != 1 {
+		t.Fatalf("fresh proof points at block %s index %d, want the reorged block at index 1",
+			fresh.BlockHash.Hex(), fresh.TransactionIndex)
+	}
+
+	// Bypassed results must not replace the cached proof
+	if cached, _ := mgr.GenerateProofWithContext(ctx, tx.Hash()); cached != stale {
+		t.Error("GenerateProofNoCache populated the proof cache")
+	}
+
+	if valid, err := mgr.VerifyProofWithContext(ctx, stale); !valid || err != nil {
+		t.Errorf("cached verification = %v, %v; want the stale proof accepted from cache", valid, err)
+	}
+	bypass := transaction.ProofOptions{BypassCache: true}
+	if valid, err := mgr.VerifyProofWithOptions(ctx, stale, bypass); valid || err == nil {
+		t.Error("bypassed verification accepted a proof for a reorged-out block")
+	}
+	if valid, err := mgr.VerifyProofWithOptions(ctx, fresh, bypass); !valid || err != nil {
+		t.Errorf("bypassed verification of fresh proof = %v, %v", valid, err)
+	}
+}
+
+func TestWaitForNonce(t *testing.T) {
+	backend, mgr := newTestManager(t, transaction.WithPollInterval(10*time.Millisecond))
+	addr := mgr.Address()
+
+	// Each poll sees the nonce advance by one
+	backend.OnCall("eth_getTransactionCount", func(call int) {
+		backend.SetNonce(addr, uint64(call))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := mgr.WaitForNonce(ctx, addr, 3); err != nil {
+		t.Fatalf("WaitForNonce failed: %v", err)
+	}
+	if calls := backend.Calls("eth_getTransactionCount"); calls != 3 {
+		t.Errorf("polled %d times, want 3", calls)
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+
+	if err := mgr.WaitForNonce(short, addr, 1000); err == nil {
+		t.Error("WaitForNonce should fail when the context expires")
+	}
+}
+
+func TestSyncNonce(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	if _, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("ours"), nil); err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	backend.Mine()
+
+	// Another tool sends four transactions from the same account
+	backend.SetNonce(mgr.Address(), 5)
+
+	next, err := mgr.SyncNonce(ctx)
+	if err != nil {
+		t.Fatalf("SyncNonce failed: %v", err)
+	}
+	if next != 5 {
+		t.Fatalf("SyncNonce = %d, want 5", next)
+	}
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("after"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext after SyncNonce failed: %v", err)
+	}
+	if tx.Nonce() != 5 {
+		t.Errorf("sent with nonce %d, want 5", tx.Nonce())
+	}
+	if calls := backend.Calls("eth_sendRawTransaction"); calls != 2 {
+		t.Errorf("made %d send calls, want 2 with none rejected", calls)
+	}
+}
+
+func TestVerifyProofCachesResult(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("verify me"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	backend.Mine()
+
+	proof, err := mgr.GenerateProofWithContext(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+
+	// Start from an empty block cache so the first verification fetches
+	mgr.InvalidateBlock(proof.BlockHash)
+	before := backend.Calls("eth_getBlockByHash")
+
+	for i := 0; i < 2; i++ {
+		if valid, err := mgr.VerifyProofWithContext(ctx, proof); !valid || err != nil {
+			t.Fatalf("verification %d failed: %v", i, err)
+		}
+	}
+	if fetched := backend.Calls("eth_getBlockByHash") - before; fetched != 1 {
+		t.Errorf("block fetched %d times across two verifications, want 1", fetched)
+	}
+
+	// A tampered proof has a different key and is checked in full
+	tampered := *proof
+	tampered.CustomData = []byte("forged")
+	if valid, _ := mgr.VerifyProofWithContext(ctx, &tampered); valid {
+		t.Error("tampered proof verified")
+	}
+
+	mgr.InvalidateBlock(proof.BlockHash)
+	if valid, err := mgr.VerifyProofWithContext(ctx, proof); !valid || err != nil {
+		t.Fatalf("verification after invalidation failed: %v", err)
+	}
+	if fetched := backend.Calls("eth_getBlockByHash") - before; fetched != 2 {
+		t.Errorf("block fetched %d times, want a refetch after InvalidateBlock", fetched)
+	}
+}
+
+func TestSendIdempotent(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	first, err := mgr.SendIdempotent(ctx, "order-42", testRecipient, nil, []byte("payload"), nil)
+	if err != nil {
+		t.Fatalf("SendIdempotent failed: %v", err)
+	}
+
+	// The fee market moves between the original call and the retry
+	backend.SetTip(big.NewInt(5e9))
+
+	retry, err := mgr.SendIdempotent(ctx, "order-42", testRecipient, nil, []byte("payload"), nil)
+	if err != nil {
+		t.Fatalf("retried SendIdempotent failed: %v", err)
+	}
+	if retry.Hash() != first.Hash() {
+		t.Errorf("retry hash %s, want %s", retry.Hash().Hex(), first.Hash().Hex())
+	}
+	if pending := backend.Pending(); len(pending) != 1 {
+		t.Errorf("pool has %d transactions, want 1", len(pending))
+	}
+
+	other, err := mgr.SendIdempotent(ctx, "order-43", testRecipient, nil, []byte("payload"), nil)
+	if err != nil {
+		t.Fatalf("SendIdempotent with a new key failed: %v", err)
+	}
+	if other.Nonce() != first.Nonce()+1 {
+		t.Errorf("new key got nonce %d, want %d", other.Nonce(), first.Nonce()+1)
+	}
+}
+
+func TestSendIdempotentAcrossRestart(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	key, _ := crypto.GenerateKey()
+	store := transaction.NewFileReservationStore(filepath.Join(t.TempDir(), "reservations.json"))
+	ctx := context.Background()
+
+	start := func() *transaction.Manager {
+		mgr, err := transaction.NewManager(backend.URL, common.Bytes2Hex(crypto.FromECDSA(key)), 2,
+			transaction.WithReservationStore(store))
+		if err != nil {
+			t.Fatalf("NewManager failed: %v", err)
+		}
+		t.Cleanup(func() { mgr.Close() })
+		return mgr
+	}
+
+	// The first process reserves a nonce and stalls before its broadcast
+	// reaches the node, as if it crashed there
+	release := make(chan struct{})
+	backend.OnCall("eth_sendRawTransaction", func(call int) {
+		if call == 1 {
+			<-release
+		}
+	})
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	})
+
+	crashed := start()
+	done := make(chan error, 1)
+	go func() {
+		_, err := crashed.SendIdempotent(ctx, "order-42", testRecipient, nil, []byte("payload"), nil)
+		done <- err
+	}()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if reserved, _ := store.Load(addr); len(reserved) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reservation was never saved")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	restarted := start()
+
+	// Regular sends skip the nonce the key reserved
+	plain, err := restarted.SendWithContext(ctx, testRecipient, nil, []byte("plain"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	if plain.Nonce() != 1 {
+		t.Errorf("regular send got nonce %d, want 1 past the reservation", plain.Nonce())
+	}
+
+	retry, err := restarted.SendIdempotent(ctx, "order-42", testRecipient, nil, []byte("payload"), nil)
+	if err != nil {
+		t.Fatalf("SendIdempotent after restart failed: %v", err)
+	}
+	if retry.Nonce() != 0 {
+		t.Errorf("retry got nonce %d, want the reserved 0", retry.Nonce())
+	}
+
+	// The stalled broadcast now finds the nonce taken and keeps the
+	// reservation rather than giving the key another nonce
+	close(release)
+	if err := <-done; !errors.Is(err, transaction.ErrReservedNonceUsed) {
+		t.Errorf("stalled send returned %v, want ErrReservedNonceUsed", err)
+	}
+	if reserved, _ := store.Load(addr); reserved["order-42"] != 0 || len(reserved) != 1 {
+		t.Errorf("reservations = %v, want order-42 at nonce 0", reserved)
+	}
+	if pending := backend.Pending(); len(pending) != 2 {
+		t.Errorf("pool has %d transactions, want 2", len(pending))
+	}
+}
+
+func TestSendIdempotentReleasesFailedSend(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	backend.SetError("eth_sendRawTransaction", errors.New("insufficient funds"))
+	if _, err := mgr.SendIdempotent(ctx, "order-42", testRecipient, nil, []byte("payload"), nil); err == nil {
+		t.Fatal("SendIdempotent succeeded against a failing node")
+	}
+	backend.SetError("eth_sendRawTransaction", nil)
+
+	// The failed key's nonce is free for other sends
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("plain"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	if tx.Nonce() != 0 {
+		t.Errorf("regular send got nonce %d, want the released 0", tx.Nonce())
+	}
+
+	retry, err := mgr.SendIdempotent(ctx, "order-42", testRecipient, nil, []byte("payload"), nil)
+	if err != nil {
+		t.Fatalf("retried SendIdempotent failed: %v", err)
+	}
+	if retry.Nonce() != 1 {
+		t.Errorf("retry got nonce %d, want 1", retry.Nonce())
+	}
+}
+
+func TestGetCustomDataByHash(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	key, _ := crypto.GenerateKey()
+	custom := signedTx(t, backend, key, 0, []byte("payload"))
+	plain := signedTx(t, backend, key, 1, nil)
+	backend.AddBlock(custom, plain)
+
+	data, err := mgr.GetCustomDataByHash(ctx, custom.Hash())
+	if err != nil {
+		t.Fatalf("GetCustomDataByHash failed: %v", err)
+	}
+	if string(data) != "payload" {
+		t.Errorf("custom data = %q, want %q", data, "payload")
+	}
+
+	// A generated proof lets the lookup skip the node
+	if _, err := mgr.GenerateProofWithContext(ctx, custom.Hash()); err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+	before := backend.Calls("eth_getTransactionByHash")
+	if _, err := mgr.GetCustomDataByHash(ctx, custom.Hash()); err != nil {
+		t.Fatalf("cached GetCustomDataByHash failed: %v", err)
+	}
+	if calls := backend.Calls("eth_getTransactionByHash"); calls != before {
+		t.Errorf("cached lookup made %d node calls", calls-before)
+	}
+
+	if _, err := mgr.GetCustomDataByHash(ctx, plain.Hash()); !errors.Is(err, transaction.ErrNotCustomData) {
+		t.Errorf("GetCustomDataByHash(plain) error = %v, want ErrNotCustomData", err)
+	}
+}
+
+func TestSendAndWait(t *testing.T) {
+	backend, mgr := newTestManager(t, transaction.WithPollInterval(10*time.Millisecond))
+
+	// The first receipt poll finds nothing; the transaction is mined before the second
+	backend.OnCall("eth_getTransactionReceipt", func(call int) {
+		if call == 2 {
+			backend.Mine()
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, receipt, err := mgr.SendAndWait(ctx, testRecipient, nil, []byte("payload"), nil)
+	if err != nil {
+		t.Fatalf("SendAndWait failed: %v", err)
+	}
+	if receipt.TxHash != tx.Hash() || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Errorf("receipt for %s with status %d, want successful receipt for %s",
+			receipt.TxHash.Hex(), receipt.Status, tx.Hash().Hex())
+	}
+	if calls := backend.Calls("eth_getTransactionReceipt"); calls < 2 {
+		t.Errorf("polled %d times, want at least 2", calls)
+	}
+}
+
+func TestSendAndWaitReverted(t *testing.T) {
+	backend, mgr := newTestManager(t, transaction.WithPollInterval(10*time.Millisecond))
+
+	backend.OnCall("eth_getTransactionReceipt", func(call int) {
+		if call == 2 {
+			for _, tx := range backend.Mine().Transactions() {
+				backend.SetReceiptStatus(tx.Hash(), types.ReceiptStatusFailed)
+			}
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, receipt, err := mgr.SendAndWait(ctx, testRecipient, nil, []byte("payload"), nil)
+	if !errors.Is(err, transaction.ErrReverted) {
+		t.Fatalf("SendAndWait error = %v, want ErrReverted", err)
+	}
+	if receipt == nil || receipt.Status != types.ReceiptStatusFailed {
+		t.Errorf("expected the failed receipt, got %+v", receipt)
+	}
+}
+
+func TestIncompleteBlockBody(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	key, _ := crypto.GenerateKey()
+	first := signedTx(t, backend, key, 0, []byte("a"))
+	last := signedTx(t, backend, key, 1, []byte("b"))
+	block := backend.AddBlock(first, last, signedTx(t, backend, key, 2, nil))
+
+	// The node serves only part of the body; the receipt still points at index 1
+	backend.TruncateBody(block.Hash(), 1)
+	if _, err := mgr.GenerateProofWithContext(ctx, last.Hash()); !errors.Is(err, transaction.ErrIncompleteBlock) {
+		t.Errorf("GenerateProof error = %v, want ErrIncompleteBlock", err)
+	}
+	if _, err := mgr.GenerateProofWithContext(ctx, first.Hash()); !errors.Is(err, transaction.ErrIncompleteBlock) {
+		t.Errorf("GenerateProof error = %v, want ErrIncompleteBlock", err)
+	}
+
+	// An empty body is rejected too rather than building an empty tree
+	backend.TruncateBody(block.Hash(), 0)
+	if _, err := mgr.GenerateProofWithContext(ctx, first.Hash()); err == nil {
+		t.Error("GenerateProof succeeded against an empty body")
+	}
+
+	// Once the node serves the full body the block is usable
+	backend.TruncateBody(block.Hash(), 3)
+	if _, err := mgr.GenerateProofWithContext(ctx, first.Hash()); err != nil {
+		t.Errorf("GenerateProof failed with the full body: %v", err)
+	}
+}
+
+func TestTotalGasSpent(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	var sent []*types.Transaction
+	for i := 0; i < 2; i++ {
+		tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte{byte(i)}, nil)
+		if err != nil {
+			t.Fatalf("SendWithContext failed: %v", err)
+		}
+		sent = append(sent, tx)
+	}
+
+	if gas, wei := mgr.TotalGasSpent(); gas.Sign() != 0 || wei.Sign() != 0 {
+		t.Errorf("before mining: TotalGasSpent = %s, %s; want 0, 0", gas, wei)
+	}
+
+	backend.Mine()
+
+	wantGas, wantWei := new(big.Int), new(big.Int)
+	for _, tx := range sent {
+		receipt, err := mgr.AwaitMined(ctx, tx.Hash())
+		if err != nil {
+			t.Fatalf("AwaitMined failed: %v", err)
+		}
+		gasUsed := new(big.Int).SetUint64(receipt.GasUsed)
+		wantGas.Add(wantGas, gasUsed)
+		wantWei.Add(wantWei, new(big.Int).Mul(gasUsed, receipt.EffectiveGasPrice))
+	}
+
+	// Observing a receipt again must not count it twice
+	if _, err := mgr.GenerateProofWithContext(ctx, sent[0].Hash()); err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+
+	gas, wei := mgr.TotalGasSpent()
+	if gas.Cmp(wantGas) != 0 || wei.Cmp(wantWei) != 0 {
+		t.Errorf("TotalGasSpent = %s, %s; want %s, %s", gas, wei, wantGas, wantWei)
+	}
+	if wantGas.Uint64() != 2*transaction.DefaultGasLimit {
+		t.Errorf("expected each transaction to use its full gas limit, got %s", wantGas)
+	}
+}
+
+func TestVerifyProofDetailed(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	key, _ := crypto.GenerateKey()
+	block := backend.AddBlock(
+		signedTx(t, backend, key, 0, []byte("first")),
+		signedTx(t, backend, key, 1, []byte("second")),
+	)
+	proof, err := mgr.GenerateProofWithContext(ctx, block.Transactions()[1].Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+
+	result, err := mgr.VerifyProofDetailedWithContext(ctx, proof)
+	if err != nil || !result.Valid() {
+		t.Fatalf("untampered proof: %+v, %v", result, err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(p *transaction.Proof)
+		failed func(r *transaction.VerifyResult) bool
+	}{
+		{
+			"merkle path",
+			func(p *transaction.Proof) { p.ProofPath = []common.Hash{common.HexToHash("0xbad")} },
+			func(r *transaction.VerifyResult) bool { return !r.MerkleValid },
+		},
+		{
+			"transaction index",
+			func(p *transaction.Proof) { p.TransactionIndex = 0 },
+			func(r *transaction.VerifyResult) bool { return !r.HashMatches },
+		},
+		{
+			"receipt",
+			func(p *transaction.Proof) {
+				receipt := *p.Receipt
+				receipt.TxHash = common.HexToHash("0xbad")
+				p.Receipt = &receipt
+			},
+			func(r *transaction.VerifyResult) bool { return !r.ReceiptMatches },
+		},
+		{
+			"custom data",
+			func(p *transaction.Proof) { p.CustomData = []byte("forged") },
+			func(r *transaction.VerifyResult) bool { return !r.CustomDataMatches },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := *proof
+			tt.tamper(&tampered)
+
+			result, err := mgr.VerifyProofDetailedWithContext(ctx, &tampered)
+			if err != nil {
+				t.Fatalf("VerifyProofDetailed failed: %v", err)
+			}
+			if !tt.failed(result) || result.Valid() {
+				t.Errorf("tampered %s not reported: %+v", tt.name, result)
+			}
+
+			if valid, err := mgr.VerifyProofWithContext(ctx, &tampered); valid || err == nil {
+				t.Error("VerifyProof accepted the tampered proof")
+			}
+		})
+	}
+}
+
+func TestVerifyProofSwappedIndex(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	key, _ := crypto.GenerateKey()
+	var txs []*types.Transaction
+	for i := 0; i < 3; i++ {
+		txs = append(txs, signedTx(t, backend, key, uint64(i), []byte{byte(i)}))
+	}
+	backend.AddBlock(txs...)
+
+	proof, err := mgr.GenerateProofWithContext(ctx, txs[2].Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+
+	// The promoted last leaf's path must not verify under any other index
+	for index := uint(0); index < 2; index++ {
+		swapped := *proof
+		swapped.TransactionIndex = index
+
+		result, err := mgr.VerifyProofDetailedWithContext(ctx, &swapped)
+		if err != nil {
+			t.Fatalf("VerifyProofDetailed failed: %v", err)
+		}
+		if result.MerkleValid || result.Valid() {
+			t.Errorf("index %d: proof of index 2 accepted: %+v", index, result)
+		}
+		if valid, _ := mgr.VerifyProofWithContext(ctx, &swapped); valid {
+			t.Errorf("index %d: VerifyProof accepted the swapped index", index)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	if _, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("payload"), nil); err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	backend.Mine()
+
+	report := mgr.Health(ctx)
+	if !report.Healthy {
+		t.Fatalf("expected a healthy report, got client errors %v", report.ClientErrors)
+	}
+	if report.HealthyClients != 2 || report.PoolSize != 2 || report.PoolClosed {
+		t.Errorf("unexpected pool state: %+v", report)
+	}
+	if report.ChainID.Cmp(backend.ChainID()) != 0 {
+		t.Errorf("ChainID = %v, want %v", report.ChainID, backend.ChainID())
+	}
+	if report.BlockNumber != backend.Head().NumberU64() {
+		t.Errorf("BlockNumber = %d, want %d", report.BlockNumber, backend.Head().NumberU64())
+	}
+	if report.PendingTransactions != 1 {
+		t.Errorf("PendingTransactions = %d, want 1", report.PendingTransactions)
+	}
+}
+
+func TestSendWithNonce(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	for _, nonce := range []uint64{7, 4} {
+		tx, err := mgr.SendWithNonce(ctx, nonce, testRecipient, nil, []byte("explicit"), nil)
+		if err != nil {
+			t.Fatalf("SendWithNonce(%d) failed: %v", nonce, err)
+		}
+		if tx.Nonce() != nonce {
+			t.Errorf("transaction nonce = %d, want %d", tx.Nonce(), nonce)
+		}
+	}
+
+	pending := backend.Pending()
+	if len(pending) != 2 || pending[0].Nonce() != 7 || pending[1].Nonce() != 4 {
+		t.Fatalf("node received %d transactions, want nonces 7 and 4", len(pending))
+	}
+	backend.FlushPool()
+
+	// The managed nonce is untouched
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("managed"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	if tx.Nonce() != 0 {
+		t.Errorf("managed nonce = %d, want 0", tx.Nonce())
+	}
+}
+
+func TestAssertCustomData(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("expected"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	backend.Mine()
+
+	if err := mgr.AssertCustomData(ctx, tx.Hash(), []byte("expected")); err != nil {
+		t.Errorf("AssertCustomData on matching data failed: %v", err)
+	}
+
+	for _, expected := range []string{"expectex", "expect", "expected!"} {
+		err := mgr.AssertCustomData(ctx, tx.Hash(), []byte(expected))
+		if !errors.Is(err, transaction.ErrCustomDataMismatch) {
+			t.Errorf("AssertCustomData(%q) error = %v, want ErrCustomDataMismatch", expected, err)
+		}
+	}
+
+	err = mgr.AssertCustomData(ctx, tx.Hash(), []byte("expectex"))
+	if err == nil || !strings.Contains(err.Error(), "byte 7") {
+		t.Errorf("error %v should name the first differing byte", err)
+	}
+}
+
+func TestOnNonceReset(t *testing.T) {
+	var (
+		resetAddr   common.Address
+		resetReason error
+		resets      int
+	)
+	backend, mgr := newTestManager(t, transaction.WithOnNonceReset(func(addr common.Address, reason error) {
+		resetAddr, resetReason = addr, reason
+		resets++
+	}))
+	ctx := context.Background()
+
+	if _, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("ok"), nil); err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	if resets != 0 {
+		t.Fatalf("hook fired %d times on a successful send", resets)
+	}
+
+	backend.SetError("eth_sendRawTransaction", errors.New("node rejected transaction"))
+	if _, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("fails"), nil); err == nil {
+		t.Fatal("expected the send to fail")
+	}
+
+	if resets != 1 {
+		t.Fatalf("hook fired %d times, want 1", resets)
+	}
+	if resetAddr != mgr.Address() {
+		t.Errorf("hook address = %s, want %s", resetAddr.Hex(), mgr.Address().Hex())
+	}
+	if resetReason == nil || !strings.Contains(resetReason.Error(), "node rejected transaction") {
+		t.Errorf("hook reason = %v, want the send error", resetReason)
+	}
+}
+
+func TestGetCachedProofByIndex(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("indexed"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	block := backend.Mine()
+
+	if _, ok := mgr.GetCachedProofByIndex(block.Hash(), 0); ok {
+		t.Fatal("found a proof before one was generated")
+	}
+
+	proof, err := mgr.GenerateProofWithContext(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+
+	if cached, ok := mgr.GetCachedProofByIndex(block.Hash(), 0); !ok || cached != proof {
+		t.Errorf("GetCachedProofByIndex = %v, %v; want the generated proof", cached, ok)
+	}
+	if cached, _ := mgr.GenerateProofWithContext(ctx, tx.Hash()); cached != proof {
+		t.Error("proof is no longer cached by transaction hash")
+	}
+}
+
+func TestInvalidateBlockAfterReorg(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+	key, _ := crypto.GenerateKey()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("reorged"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	orphaned := backend.Mine()
+
+	stale, err := mgr.GenerateProofWithContext(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+
+	// The transaction is mined again at another index in a replacement block
+	backend.Rewind(0)
+	canonical := backend.AddBlock(signedTx(t, backend, key, 0, nil), tx)
+	mgr.InvalidateBlock(orphaned.Hash())
+
+	if _, ok := mgr.GetCachedProofByIndex(orphaned.Hash(), 0); ok {
+		t.Error("proof is still indexed under the orphaned block")
+	}
+
+	proof, err := mgr.GenerateProofWithContext(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof after reorg failed: %v", err)
+	}
+	if proof == stale || proof.BlockHash != canonical.Hash() || proof.TransactionIndex != 1 {
+		t.Errorf("proof is for block %s index %d, want %s index 1",
+			proof.BlockHash.Hex(), proof.TransactionIndex, canonical.Hash().Hex())
+	}
+	if valid, err := mgr.VerifyProofWithContext(ctx, proof); !valid || err != nil {
+		t.Errorf("proof after reorg did not verify: %v", err)
+	}
+	if cached, ok := mgr.GetCachedProofByIndex(canonical.Hash(), 1); !ok || cached != proof {
+		t.Error("new proof is not indexed under the canonical block")
+	}
+}
+
+func TestMaxInFlightWaits(t *testing.T) {
+	backend, mgr := newTestManager(t,
+		transaction.WithMaxInFlight(2, true),
+		transaction.WithPollInterval(10*time.Millisecond),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		if _, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("in flight"), nil); err != nil {
+			t.Fatalf("SendWithContext %d failed: %v", i, err)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("third"), nil)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("third send returned (%v) with two transactions in flight", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if pending := backend.Pending(); len(pending) != 2 {
+		t.Fatalf("node received %d transactions while at the limit, want 2", len(pending))
+	}
+
+	backend.Mine()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("third send failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("third send still waiting after the first two were mined")
+	}
+}
+
+func TestMaxInFlightFails(t *testing.T) {
+	_, mgr := newTestManager(t, transaction.WithMaxInFlight(1, false))
+	ctx := context.Background()
+
+	if _, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("in flight"), nil); err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	if _, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("over"), nil); !errors.Is(err, transaction.ErrTooManyInFlight) {
+		t.Errorf("SendWithContext at the limit error = %v, want ErrTooManyInFlight", err)
+	}
+}
+
+func TestMaxInFlightFreedByReplacement(t *testing.T) {
+	backend, mgr := newTestManager(t,
+		transaction.WithMaxInFlight(2, true),
+		transaction.WithPollInterval(10*time.Millisecond),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("slow"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	faster, err := mgr.SpeedUp(ctx, tx, transaction.DefaultMinBumpPercent)
+	if err != nil {
+		t.Fatalf("SpeedUp failed: %v", err)
+	}
+
+	// The replacement shares the original's slot
+	sendCtx, sendCancel := context.WithTimeout(ctx, time.Second)
+	defer sendCancel()
+	if _, err := mgr.SendWithContext(sendCtx, testRecipient, nil, []byte("second"), nil); err != nil {
+		t.Fatalf("send after SpeedUp failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("third"), nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("third send returned (%v) with two nonces in flight", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Mining the replacement frees the slot; the original never gets a
+	// receipt
+	backend.AddBlock(faster)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("third send failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("third send still waiting after the replacement was mined")
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	if stats := mgr.LatencyStats(); stats["p50"] != 0 || stats["p99"] != 0 {
+		t.Fatalf("LatencyStats before any send = %v, want zeros", stats)
+	}
+
+	// 18 sends take about 20ms and 2 take about 200ms
+	backend.OnCall("eth_sendRawTransaction", func(call int) {
+		if call > 18 {
+			time.Sleep(200 * time.Millisecond)
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+	for i := 0; i < 20; i++ {
+		if _, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("timed"), nil); err != nil {
+			t.Fatalf("SendWithContext failed: %v", err)
+		}
+	}
+
+	stats := mgr.LatencyStats()
+	within := func(name string, min, max time.Duration) {
+		t.Helper()
+		if got := stats[name]; got < min || got > max {
+			t.Errorf("%s = %v, want between %v and %v", name, got, min, max)
+		}
+	}
+	within("p50", 20*time.Millisecond, 100*time.Millisecond)
+	within("p95", 200*time.Millisecond, 400*time.Millisecond)
+	within("p99", 200*time.Millisecond, 400*time.Millisecond)
+}
+
+func TestMetricsCollector(t *testing.T) {
+	_, mgr := newTestManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	snapshots := mgr.StartMetricsCollector(ctx, 10*time.Millisecond)
+	first := <-snapshots
+
+	if _, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("counted"), nil); err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+
+	var second transaction.MetricsSnapshot
+	for second.Counters["tx_sent"] == 0 {
+		select {
+		case second = <-snapshots:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no snapshot reflected the send")
+		}
+	}
+
+	if !second.Time.After(first.Time) {
+		t.Errorf("second snapshot at %v, not after the first at %v", second.Time, first.Time)
+	}
+	for name, value := range first.Counters {
+		if second.Counters[name] < value {
+			t.Errorf("counter %s decreased from %d to %d", name, value, second.Counters[name])
+		}
+	}
+
+	cancel()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-snapshots:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("collector did not stop after cancellation")
+		}
+	}
+}
+
+func TestVerifyCachedBlock(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+	ctx := context.Background()
+
+	honest := backend.AddBlock(signedTx(t, backend, key, 0, []byte("honest")))
+	poisoned := backend.AddBlock(signedTx(t, backend, key, 1, []byte("poisoned")))
+	backend.ServeBlockAs(poisoned.Hash(), honest)
+
+	for _, block := range []*types.Block{honest, poisoned} {
+		// Fetching the proofs caches the block under the requested hash
+		mgr.GenerateBlockProofs(ctx, block.Hash(), 1)
+	}
+
+	if ok, err := mgr.VerifyCachedBlock(honest.Hash()); !ok || err != nil {
+		t.Errorf("VerifyCachedBlock(honest) = %v, %v; want true", ok, err)
+	}
+	if ok, err := mgr.VerifyCachedBlock(poisoned.Hash()); ok || err != nil {
+		t.Fatalf("VerifyCachedBlock(poisoned) = %v, %v; want false", ok, err)
+	}
+	if _, err := mgr.VerifyCachedBlock(poisoned.Hash()); err == nil {
+		t.Error("the poisoned block is still cached after failing verification")
+	}
+	if ok, err := mgr.VerifyCachedBlock(honest.Hash()); !ok || err != nil {
+		t.Errorf("VerifyCachedBlock(honest) after eviction = %v, %v; want true", ok, err)
+	}
+}
+
+func TestSendBatch(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+	ctx := context.Background()
+
+	unsigned := func(nonce uint64, customData string) *types.Transaction {
+		return transaction.NewCustomTransaction(backend.ChainID(), nonce, &testRecipient,
+			big.NewInt(0), 100000, big.NewInt(1e9), big.NewInt(3e9), nil, []byte(customData))
+	}
+	presigned := signedTx(t, backend, key, 0, []byte("presigned"))
+	txs := []*types.Transaction{unsigned(0, "first"), presigned, unsigned(1, "second"), presigned}
+
+	requests := backend.Requests()
+	hashes, errs := mgr.SendBatch(ctx, txs)
+
+	if got := backend.Requests() - requests; got != 1 {
+		t.Errorf("SendBatch made %d requests, want 1", got)
+	}
+	if calls := backend.Calls("eth_sendRawTransaction"); calls != len(txs) {
+		t.Errorf("eth_sendRawTransaction called %d times, want %d", calls, len(txs))
+	}
+
+	for i := 0; i < 3; i++ {
+		if errs[i] != nil {
+			t.Fatalf("transaction %d failed: %v", i, errs[i])
+		}
+	}
+	if errs[3] == nil || hashes[3] != (common.Hash{}) {
+		t.Errorf("duplicate transaction = %s, %v; want an error", hashes[3].Hex(), errs[3])
+	}
+
+	pending := backend.Pending()
+	if len(pending) != 3 {
+		t.Fatalf("node received %d transactions, want 3", len(pending))
+	}
+	for i, tx := range pending {
+		if tx.Hash() != hashes[i] {
+			t.Errorf("pending[%d] = %s, want %s", i, tx.Hash().Hex(), hashes[i].Hex())
+		}
+	}
+	if hashes[1] != presigned.Hash() {
+		t.Errorf("presigned transaction hash = %s, want it sent unchanged", hashes[1].Hex())
+	}
+	for _, i := range []int{0, 2} {
+		if from, err := types.Sender(backend.Signer(), pending[i]); err != nil || from != mgr.Address() {
+			t.Errorf("transaction %d sender = %s, %v; want the manager", i, from.Hex(), err)
+		}
+	}
+}
+
+func TestPrepareAndFlush(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	var prepared []*types.Transaction
+	for i := 0; i < 3; i++ {
+		tx, err := mgr.Prepare(ctx, testRecipient, nil, []byte{byte(i)}, nil)
+		if err != nil {
+			t.Fatalf("Prepare failed: %v", err)
+		}
+		if tx.Nonce() != uint64(i) {
+			t.Errorf("prepared transaction %d has nonce %d", i, tx.Nonce())
+		}
+		prepared = append(prepared, tx)
+	}
+	if calls := backend.Calls("eth_sendRawTransaction"); calls != 0 {
+		t.Fatalf("Prepare broadcast %d transactions", calls)
+	}
+
+	hashes, errs := mgr.Flush(ctx, prepared)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Flush of transaction %d failed: %v", i, err)
+		}
+	}
+	pending := backend.Pending()
+	if len(pending) != len(prepared) {
+		t.Fatalf("node received %d transactions, want %d", len(pending), len(prepared))
+	}
+	for i, tx := range pending {
+		if tx.Hash() != prepared[i].Hash() || hashes[i] != tx.Hash() {
+			t.Errorf("pending[%d] = %s, want %s", i, tx.Hash().Hex(), prepared[i].Hash().Hex())
+		}
+	}
+
+	// A later send continues after the prepared nonces
+	next, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("next"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	if next.Nonce() != 3 {
+		t.Errorf("send after Flush used nonce %d, want 3", next.Nonce())
+	}
+}
+
+func TestBalanceCheck(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	key, _ := crypto.GenerateKey()
+	backend.SetBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(0))
+	keyHex := common.Bytes2Hex(crypto.FromECDSA(key))
+
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	newManager := func(check transaction.BalanceCheck) (*transaction.Manager, error) {
+		mgr, err := transaction.NewManager(backend.URL, keyHex, 1, transaction.WithBalanceCheck(check))
+		if err == nil {
+			t.Cleanup(func() { mgr.Close() })
+		}
+		return mgr, err
+	}
+
+	if _, err := newManager(transaction.BalanceCheckOff); err != nil || logs.Len() != 0 {
+		t.Fatalf("NewManager with the check off = %v, logged %q", err, logs.String())
+	}
+
+	if _, err := newManager(transaction.BalanceCheckWarn); err != nil {
+		t.Fatalf("NewManager with BalanceCheckWarn failed: %v", err)
+	}
+	if !strings.Contains(logs.String(), "zero balance") {
+		t.Errorf("BalanceCheckWarn logged %q, want a zero balance warning", logs.String())
+	}
+
+	if _, err := newManager(transaction.BalanceCheckError); !errors.Is(err, transaction.ErrZeroBalance) {
+		t.Errorf("NewManager with BalanceCheckError error = %v, want ErrZeroBalance", err)
+	}
+
+	backend.SetBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1))
+	if _, err := newManager(transaction.BalanceCheckError); err != nil {
+		t.Errorf("NewManager with a funded account failed: %v", err)
+	}
+}
+
+// recordingSponsor records each transaction before forwarding it, or fails
+// with err if set
+type recordingSponsor struct {
+	seen []*types.Transaction
+	err  error
+}
+
+func (s *recordingSponsor) Broadcast(ctx context.Context, tx *types.Transaction, client *ethclient.Client) error {
+	s.seen = append(s.seen, tx)
+	if s.err != nil {
+		return s.err
+	}
+	return transaction.DirectSponsor{}.Broadcast(ctx, tx, client)
+}
+
+func TestSponsor(t *testing.T) {
+	sponsor := &recordingSponsor{}
+	backend, mgr := newTestManager(t, transaction.WithSponsor(sponsor))
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("sponsored"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	if len(sponsor.seen) != 1 || sponsor.seen[0].Hash() != tx.Hash() {
+		t.Fatalf("sponsor saw %d transactions, want only %s", len(sponsor.seen), tx.Hash().Hex())
+	}
+	if pending := backend.Pending(); len(pending) != 1 || pending[0].Hash() != tx.Hash() {
+		t.Fatalf("node received %d transactions, want the sponsored one", len(pending))
+	}
+
+	sponsor.err = errors.New("relayer unavailable")
+	if _, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("rejected"), nil); !errors.Is(err, sponsor.err) {
+		t.Errorf("SendWithContext error = %v, want the sponsor's error", err)
+	}
+	if len(sponsor.seen) != 2 || len(backend.Pending()) != 1 {
+		t.Errorf("sponsor saw %d transactions and node has %d, want 2 and 1", len(sponsor.seen), len(backend.Pending()))
+	}
+}
+
+func TestAwaitMinedMaxAttempts(t *testing.T) {
+	backend, mgr := newTestManager(t,
+		transaction.WithReceiptPollInterval(5*time.Millisecond),
+		transaction.WithReceiptMaxAttempts(3))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("unmined"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+
+	before := backend.Calls("eth_getTransactionReceipt")
+	start := time.Now()
+	if _, err := mgr.AwaitMined(ctx, tx.Hash()); !errors.Is(err, transaction.ErrReceiptTimeout) {
+		t.Fatalf("AwaitMined error = %v, want ErrReceiptTimeout", err)
+	}
+	if calls := backend.Calls("eth_getTransactionReceipt") - before; calls != 3 {
+		t.Errorf("AwaitMined polled %d times, want 3", calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("AwaitMined took %v with a 5ms poll interval", elapsed)
+	}
+
+	backend.Mine()
+	if _, err := mgr.AwaitMined(ctx, tx.Hash()); err != nil {
+		t.Errorf("AwaitMined after mining failed: %v", err)
+	}
+}
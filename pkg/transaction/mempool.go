@@ -0,0 +1,44 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// methodNotFoundCode is the JSON-RPC error code for an unknown method
+const methodNotFoundCode = -32601
+
+// MempoolTransactions returns the manager's transactions in the node's
+// mempool, pending first and then queued, each ordered by nonce. It uses the
+// txpool_content RPC; nodes without the txpool namespace yield an empty
+// slice.
+func (m *Manager) MempoolTransactions(ctx context.Context) ([]*types.Transaction, error) {
+	var content map[string]map[common.Address]map[string]*types.Transaction
+
+	err := m.clientPool.Get().Client().CallContext(ctx, &content, "txpool_content")
+	if err != nil {
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == methodNotFoundCode {
+			return []*types.Transaction{}, nil
+		}
+		return nil, fmt.Errorf("failed to get txpool content: %w", err)
+	}
+
+	txs := []*types.Transaction{}
+	for _, section := range []string{"pending", "queued"} {
+		var own []*types.Transaction
+		for _, tx := range content[section][m.address] {
+			own = append(own, tx)
+		}
+		sort.Slice(own, func(i, j int) bool { return own[i].Nonce() < own[j].Nonce() })
+		txs = append(txs, own...)
+	}
+
+	return txs, nil
+}
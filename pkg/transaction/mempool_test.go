@@ -0,0 +1,62 @@
+package transaction_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// methodNotFound mimics a node without the called method's namespace
+type methodNotFound struct{}
+
+func (methodNotFound) Error() string {
+	return "the method does not exist/is not available"
+}
+func (methodNotFound) ErrorCode() int { return -32601 }
+
+func TestMempoolTransactions(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	var sent []common.Hash
+	for i := 0; i < 3; i++ {
+		tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte{byte(i)}, nil)
+		if err != nil {
+			t.Fatalf("SendWithContext failed: %v", err)
+		}
+		sent = append(sent, tx.Hash())
+	}
+
+	// Another account's transaction must be filtered out
+	other, _ := crypto.GenerateKey()
+	client, err := ethclient.Dial(backend.URL)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+	if err := client.SendTransaction(ctx, signedTx(t, backend, other, 0, nil)); err != nil {
+		t.Fatalf("SendTransaction failed: %v", err)
+	}
+
+	txs, err := mgr.MempoolTransactions(ctx)
+	if err != nil {
+		t.Fatalf("MempoolTransactions failed: %v", err)
+	}
+	if len(txs) != len(sent) {
+		t.Fatalf("got %d mempool transactions, want %d", len(txs), len(sent))
+	}
+	for i, tx := range txs {
+		if tx.Hash() != sent[i] {
+			t.Errorf("mempool[%d] = %s, want %s", i, tx.Hash().Hex(), sent[i].Hex())
+		}
+	}
+
+	backend.SetError("txpool_content", methodNotFound{})
+	txs, err = mgr.MempoolTransactions(ctx)
+	if err != nil || txs == nil || len(txs) != 0 {
+		t.Errorf("without txpool namespace: got %v, %v; want an empty slice", txs, err)
+	}
+}
@@ -0,0 +1,43 @@
+package transaction
+
+import (
+	"context"
+	"time"
+)
+
+// MetricsSnapshot is the value of every manager counter at one moment
+type MetricsSnapshot struct {
+	Time     time.Time         `json:"time"`
+	Counters map[string]uint64 `json:"counters"`
+}
+
+// StartMetricsCollector emits a MetricsSnapshot every interval until ctx is
+// cancelled, then closes the returned channel. A snapshot waits for the
+// caller to receive it, so a slow reader delays the following ones rather
+// than piling them up.
+func (m *Manager) StartMetricsCollector(ctx context.Context, interval time.Duration) <-chan MetricsSnapshot {
+	snapshots := make(chan MetricsSnapshot)
+
+	go func() {
+		defer close(snapshots)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				snapshot := MetricsSnapshot{Time: now, Counters: m.Metrics()}
+				select {
+				case snapshots <- snapshot:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return snapshots
+}
@@ -0,0 +1,21 @@
+package transaction
+
+// EncodeCustomDataPadded encodes customData in FormatV1 zero-padded to a
+// multiple of blockSize bytes, so the calldata only reveals the payload
+// length to within a block. Empty custom data still takes one block. The
+// true length is recorded in the header and DecodeCustomData trims the
+// padding off. A blockSize of zero or less encodes without padding.
+func EncodeCustomDataPadded(standardData, customData []byte, blockSize int) []byte {
+	return EncodeCustomDataWithOptions(standardData, customData, EncodeOptions{PadTo: blockSize})
+}
+
+// pad returns data zero-padded to the next positive multiple of blockSize
+func pad(data []byte, blockSize int) []byte {
+	blocks := (len(data) + blockSize - 1) / blockSize
+	if blocks == 0 {
+		blocks = 1
+	}
+	padded := make([]byte, blocks*blockSize)
+	copy(padded, data)
+	return padded
+}
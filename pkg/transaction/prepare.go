@@ -0,0 +1,64 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Prepare builds and signs a custom transaction at the next managed nonce
+// without broadcasting it, for a later Flush. The nonce is taken even though
+// nothing is sent, so prepared transactions must be flushed in order, and
+// before anything sent with SendWithContext, for the node to accept the
+// nonces without a gap. Fees are fixed at preparation time.
+func (m *Manager) Prepare(
+	ctx context.Context,
+	to common.Address,
+	value *big.Int,
+	customData, data []byte,
+) (*types.Transaction, error) {
+	if value == nil {
+		value = big.NewInt(0)
+	}
+
+	nonce, err := m.nonceManager.GetNext(m.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nonce: %w", err)
+	}
+
+	signedTx, err := m.signCustomTx(ctx, m.gasStrategy, nonce, to, value, customData, data)
+	if err != nil {
+		m.resetNonce(err)
+		return nil, err
+	}
+	return signedTx, nil
+}
+
+// Flush broadcasts transactions from Prepare one at a time, in order.
+// hashes[i] and errs[i] report on txs[i]. Once a send fails the managed
+// nonce is reset and the remaining transactions are not sent, since their
+// nonces would follow a gap.
+func (m *Manager) Flush(ctx context.Context, txs []*types.Transaction) (hashes []common.Hash, errs []error) {
+	hashes = make([]common.Hash, len(txs))
+	errs = make([]error, len(txs))
+
+	for i, tx := range txs {
+		if err := m.broadcast(ctx, tx); err != nil {
+			m.resetNonce(err)
+			m.metrics.IncrementTxFailed()
+			errs[i] = fmt.Errorf("failed to send transaction %d: %w", i, err)
+			for j := i + 1; j < len(txs); j++ {
+				errs[j] = fmt.Errorf("transaction %d not sent after transaction %d failed", j, i)
+			}
+			return hashes, errs
+		}
+
+		hashes[i] = tx.Hash()
+		m.ledger.track(tx)
+		m.metrics.IncrementTxSent()
+	}
+	return hashes, errs
+}
@@ -0,0 +1,333 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/merkle"
+)
+
+// GenerateBlockProofs generates a proof for every transaction in a block,
+// building the block's Merkle tree once. Receipts are fetched with at most
+// concurrency requests in flight; values below 1 use the client pool size.
+// Proofs are returned in transaction index order.
+func (m *Manager) GenerateBlockProofs(
+	ctx context.Context,
+	blockHash common.Hash,
+	concurrency int,
+) ([]*Proof, error) {
+	if concurrency < 1 {
+		concurrency = m.clientPool.Size()
+	}
+
+	block, err := m.getBlock(ctx, blockHash, ProofOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block: %w", err)
+	}
+
+	tree, err := m.getMerkleTree(ctx, blockHash, ProofOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merkle tree: %w", err)
+	}
+
+	txs := block.Transactions()
+	proofs := make([]*Proof, len(txs))
+	errs := make([]error, len(txs))
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, tx := range txs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(i int, tx *types.Transaction) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			proofs[i], errs[i] = m.blockProof(ctx, blockHash, tx, tree)
+		}(i, tx)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to prove transaction %d: %w", i, err)
+		}
+	}
+
+	return proofs, nil
+}
+
+// blockProof proves tx against the already built tree of the block it was
+// mined in, caching the result
+func (m *Manager) blockProof(
+	ctx context.Context,
+	blockHash common.Hash,
+	tx *types.Transaction,
+	tree *merkle.Tree,
+) (*Proof, error) {
+	receipt, err := m.getReceipt(ctx, tx.Hash(), ProofOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipt: %w", err)
+	}
+	if receipt.BlockHash != blockHash {
+		return nil, fmt.Errorf("receipt references block %s, want %s", receipt.BlockHash.Hex(), blockHash.Hex())
+	}
+
+	proof, err := buildProof(tx, receipt, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	m.cacheProof(proof)
+	m.metrics.IncrementProofsGenerated()
+
+	return proof, nil
+}
+
+// VerifyProofs verifies many proofs with at most the client pool size
+// verifications in flight. Proofs are grouped by block so each block is
+// fetched and its Merkle tree built once. valid[i] and errs[i] report on
+// proofs[i].
+func (m *Manager) VerifyProofs(ctx context.Context, proofs []*Proof) (valid []bool, errs []error) {
+	valid = make([]bool, len(proofs))
+	errs = make([]error, len(proofs))
+
+	var blocks []common.Hash
+	byBlock := make(map[common.Hash][]int)
+	for i, proof := range proofs {
+		if proof == nil {
+			errs[i] = fmt.Errorf("proof is nil")
+			continue
+		}
+		if _, ok := byBlock[proof.BlockHash]; !ok {
+			blocks = append(blocks, proof.BlockHash)
+		}
+		byBlock[proof.BlockHash] = append(byBlock[proof.BlockHash], i)
+	}
+
+	sem := make(chan struct{}, m.clientPool.Size())
+	var wg sync.WaitGroup
+
+	for _, blockHash := range blocks {
+		wg.Add(1)
+		go func(indices []int) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				for _, i := range indices {
+					errs[i] = ctx.Err()
+				}
+				return
+			}
+			defer func() { <-sem }()
+
+			// The first proof builds the tree; the rest reuse it from the cache
+			for _, i := range indices {
+				valid[i], errs[i] = m.VerifyProofWithContext(ctx, proofs[i])
+			}
+		}(byBlock[blockHash])
+	}
+
+	wg.Wait()
+	return valid, errs
+}
+
+// VerifyProofAcrossBlocks verifies proof against each candidate block in
+// turn, e.g. the competing blocks of a reorg or an uncle a client tracked,
+// and returns the first candidate it verifies against. A candidate that
+// cannot be fetched is skipped; an error is returned only if none could be
+// checked.
+func (m *Manager) VerifyProofAcrossBlocks(
+	ctx context.Context,
+	proof *Proof,
+	candidateHashes []common.Hash,
+) (common.Hash, bool, error) {
+	if proof == nil {
+		return common.Hash{}, false, fmt.Errorf("proof is nil")
+	}
+
+	var errs []error
+	for _, blockHash := range candidateHashes {
+		candidate := *proof
+		candidate.BlockHash = blockHash
+
+		result, err := m.verifyProof(ctx, &candidate, ProofOptions{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("block %s: %w", blockHash.Hex(), err))
+			continue
+		}
+		if result.Valid() {
+			return blockHash, true, nil
+		}
+	}
+
+	if len(errs) > 0 && len(errs) == len(candidateHashes) {
+		return common.Hash{}, false, errors.Join(errs...)
+	}
+	return common.Hash{}, false, nil
+}
+
+// ConfirmInclusion asks the node, via eth_getTransactionByBlockHashAndIndex,
+// which transaction it has at index in blockHash and reports whether that is
+// expectedHash. It is an independent check alongside the Merkle path, which
+// is built from the block body. A missing block or index reports false.
+func (m *Manager) ConfirmInclusion(
+	ctx context.Context,
+	blockHash common.Hash,
+	index uint,
+	expectedHash common.Hash,
+) (bool, error) {
+	tx, err := m.clientPool.Get().TransactionInBlock(ctx, blockHash, index)
+	if errors.Is(err, ethereum.NotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get transaction %d of block %s: %w", index, blockHash.Hex(), err)
+	}
+	return tx.Hash() == expectedHash, nil
+}
+
+// ProveNonInclusion reports whether txHash is absent from the block
+// blockHash by rebuilding the block's Merkle tree and checking its leaves.
+// The result is only as trustworthy as the block returned by the node: a
+// malicious or out-of-sync node can omit transactions.
+func (m *Manager) ProveNonInclusion(ctx context.Context, blockHash, txHash common.Hash) (bool, error) {
+	tree, err := m.getMerkleTree(ctx, blockHash, ProofOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to get merkle tree: %w", err)
+	}
+
+	_, included := tree.IndexOf(txHash)
+	return !included, nil
+}
+
+// GenerateProofs generates a proof for each of hashes, which may come from
+// any number of blocks. Receipts are fetched first to group the hashes by
+// block, then each block is fetched and its Merkle tree built once.
+// proofs[i] and errs[i] report on hashes[i]. At most the client pool size
+// requests are in flight.
+func (m *Manager) GenerateProofs(ctx context.Context, hashes []common.Hash) (proofs []*Proof, errs []error) {
+	proofs = make([]*Proof, len(hashes))
+	errs = make([]error, len(hashes))
+	receipts := make([]*types.Receipt, len(hashes))
+
+	sem := make(chan struct{}, m.clientPool.Size())
+	var wg sync.WaitGroup
+
+	// run calls fn in a goroutine once a slot is free, or fails indices
+	// with the context's error
+	run := func(indices []int, fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				for _, i := range indices {
+					errs[i] = ctx.Err()
+				}
+				return
+			}
+			defer func() { <-sem }()
+
+			fn()
+		}()
+	}
+
+	for i, txHash := range hashes {
+		if cached, ok := m.proofCache.Get(txHash); ok {
+			if proof, ok := cached.(*Proof); ok {
+				m.metrics.IncrementCacheHits()
+				proofs[i] = proof
+				continue
+			}
+		}
+		m.metrics.IncrementCacheMisses()
+
+		i, txHash := i, txHash
+		run([]int{i}, func() {
+			receipt, err := m.getReceipt(ctx, txHash, ProofOptions{})
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to get receipt: %w", err)
+				return
+			}
+			receipts[i] = receipt
+		})
+	}
+	wg.Wait()
+
+	var blocks []common.Hash
+	byBlock := make(map[common.Hash][]int)
+	for i, receipt := range receipts {
+		if receipt == nil {
+			continue
+		}
+		if _, ok := byBlock[receipt.BlockHash]; !ok {
+			blocks = append(blocks, receipt.BlockHash)
+		}
+		byBlock[receipt.BlockHash] = append(byBlock[receipt.BlockHash], i)
+	}
+
+	for _, blockHash := range blocks {
+		blockHash, indices := blockHash, byBlock[blockHash]
+		run(indices, func() {
+			for _, i := range indices {
+				proofs[i], errs[i] = m.proofInBlock(ctx, blockHash, hashes[i], receipts[i])
+			}
+		})
+	}
+	wg.Wait()
+
+	return proofs, errs
+}
+
+// proofInBlock proves txHash from its receipt, taking the transaction from
+// the body of the block it was mined in rather than asking the node for it
+func (m *Manager) proofInBlock(
+	ctx context.Context,
+	blockHash, txHash common.Hash,
+	receipt *types.Receipt,
+) (*Proof, error) {
+	block, err := m.getBlock(ctx, blockHash, ProofOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block: %w", err)
+	}
+
+	txs := block.Transactions()
+	if receipt.TransactionIndex >= uint(len(txs)) || txs[receipt.TransactionIndex].Hash() != txHash {
+		return nil, fmt.Errorf("block %s has no transaction %s at index %d",
+			blockHash.Hex(), txHash.Hex(), receipt.TransactionIndex)
+	}
+
+	tree, err := m.getMerkleTree(ctx, blockHash, ProofOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merkle tree: %w", err)
+	}
+
+	proof, err := buildProof(txs[receipt.TransactionIndex], receipt, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	m.cacheProof(proof)
+	m.metrics.IncrementProofsGenerated()
+
+	return proof, nil
+}
@@ -0,0 +1,543 @@
+package transaction_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+func TestGenerateBlockProofsConcurrencyLimit(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+
+	var txs []*types.Transaction
+	for i := uint64(0); i < 8; i++ {
+		txs = append(txs, signedTx(t, backend, key, i, []byte{byte(i)}))
+	}
+	block := backend.AddBlock(txs...)
+
+	var inFlight, peak atomic.Int32
+	backend.OnCall("eth_getTransactionReceipt", func(int) {
+		current := inFlight.Add(1)
+		for {
+			old := peak.Load()
+			if current <= old || peak.CompareAndSwap(old, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+	})
+
+	ctx := context.Background()
+	proofs, err := mgr.GenerateBlockProofs(ctx, block.Hash(), 2)
+	if err != nil {
+		t.Fatalf("GenerateBlockProofs failed: %v", err)
+	}
+
+	if peak.Load() > 2 {
+		t.Errorf("%d receipt requests in flight, want at most 2", peak.Load())
+	}
+	if len(proofs) != len(txs) {
+		t.Fatalf("got %d proofs, want %d", len(proofs), len(txs))
+	}
+	for i, proof := range proofs {
+		if proof.Transaction.Hash() != txs[i].Hash() || proof.TransactionIndex != uint(i) {
+			t.Errorf("proof %d is for %s at index %d", i, proof.Transaction.Hash().Hex(), proof.TransactionIndex)
+		}
+		if valid, err := mgr.VerifyProofWithContext(ctx, proof); !valid || err != nil {
+			t.Errorf("proof %d did not verify: %v", i, err)
+		}
+	}
+}
+
+func TestVerifyProofs(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+	ctx := context.Background()
+
+	first := backend.AddBlock(
+		signedTx(t, backend, key, 0, []byte("a")),
+		signedTx(t, backend, key, 1, []byte("b")),
+	)
+	second := backend.AddBlock(
+		signedTx(t, backend, key, 2, []byte("c")),
+		signedTx(t, backend, key, 3, []byte("d")),
+	)
+
+	firstProofs, err := mgr.GenerateBlockProofs(ctx, first.Hash(), 0)
+	if err != nil {
+		t.Fatalf("GenerateBlockProofs failed: %v", err)
+	}
+	secondProofs, err := mgr.GenerateBlockProofs(ctx, second.Hash(), 0)
+	if err != nil {
+		t.Fatalf("GenerateBlockProofs failed: %v", err)
+	}
+
+	tampered := *secondProofs[1]
+	tampered.CustomData = []byte("forged")
+
+	proofs := []*transaction.Proof{firstProofs[0], &tampered, secondProofs[0], nil, firstProofs[1]}
+	want := []bool{true, false, true, false, true}
+
+	// Start cold so the blocks and trees are rebuilt during verification
+	mgr.InvalidateBlock(first.Hash())
+	mgr.InvalidateBlock(second.Hash())
+	before := backend.Calls("eth_getBlockByHash")
+
+	valid, errs := mgr.VerifyProofs(ctx, proofs)
+	if len(valid) != len(proofs) || len(errs) != len(proofs) {
+		t.Fatalf("got %d results and %d errors for %d proofs", len(valid), len(errs), len(proofs))
+	}
+	for i := range proofs {
+		if valid[i] != want[i] {
+			t.Errorf("proof %d: valid = %v, want %v", i, valid[i], want[i])
+		}
+		if (errs[i] == nil) != want[i] {
+			t.Errorf("proof %d: error = %v, want error only for invalid proofs", i, errs[i])
+		}
+	}
+
+	if fetched := backend.Calls("eth_getBlockByHash") - before; fetched != 2 {
+		t.Errorf("fetched blocks %d times, want once per block", fetched)
+	}
+}
+
+func TestProveNonInclusion(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+	ctx := context.Background()
+
+	included := signedTx(t, backend, key, 0, []byte("in"))
+	foreign := signedTx(t, backend, key, 1, []byte("out"))
+	block := backend.AddBlock(included, signedTx(t, backend, key, 2, nil))
+
+	absent, err := mgr.ProveNonInclusion(ctx, block.Hash(), foreign.Hash())
+	if err != nil {
+		t.Fatalf("ProveNonInclusion failed: %v", err)
+	}
+	if !absent {
+		t.Error("foreign transaction not proven absent")
+	}
+
+	if absent, err := mgr.ProveNonInclusion(ctx, block.Hash(), included.Hash()); absent || err != nil {
+		t.Errorf("ProveNonInclusion(included) = %v, %v; want false", absent, err)
+	}
+}
+
+func TestGenerateProofs(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+	ctx := context.Background()
+
+	first := backend.AddBlock(
+		signedTx(t, backend, key, 0, []byte("a")),
+		signedTx(t, backend, key, 1, []byte("b")),
+		signedTx(t, backend, key, 2, []byte("c")),
+	)
+	second := backend.AddBlock(
+		signedTx(t, backend, key, 3, []byte("d")),
+		signedTx(t, backend, key, 4, []byte("e")),
+	)
+
+	missing := common.HexToHash("0x01")
+	hashes := []common.Hash{
+		second.Transactions()[1].Hash(),
+		first.Transactions()[0].Hash(),
+		missing,
+		first.Transactions()[2].Hash(),
+		second.Transactions()[0].Hash(),
+		first.Transactions()[1].Hash(),
+	}
+
+	proofs, errs := mgr.GenerateProofs(ctx, hashes)
+
+	for i, txHash := range hashes {
+		if txHash == missing {
+			if errs[i] == nil || proofs[i] != nil {
+				t.Errorf("hash %d: expected an error for an unknown transaction", i)
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Fatalf("hash %d: GenerateProofs failed: %v", i, errs[i])
+		}
+		if proofs[i].Transaction.Hash() != txHash {
+			t.Errorf("proof %d is for %s, want %s", i, proofs[i].Transaction.Hash().Hex(), txHash.Hex())
+		}
+		if valid, err := mgr.VerifyProofWithContext(ctx, proofs[i]); !valid {
+			t.Errorf("proof %d does not verify: %v", i, err)
+		}
+	}
+
+	if calls := backend.Calls("eth_getBlockByHash"); calls != 2 {
+		t.Errorf("fetched blocks %d times, want once per block", calls)
+	}
+	if calls := backend.Calls("eth_getTransactionByHash"); calls != 0 {
+		t.Errorf("fetched transactions %d times, want them taken from the blocks", calls)
+	}
+}
+
+func TestVerifyProofAcrossBlocks(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+	ctx := context.Background()
+
+	tx := signedTx(t, backend, key, 0, []byte("reorged"))
+	including := backend.AddBlock(tx, signedTx(t, backend, key, 1, []byte("a")))
+	competing := backend.AddBlock(signedTx(t, backend, key, 2, []byte("b")), signedTx(t, backend, key, 3, []byte("c")))
+
+	proof, err := mgr.GenerateProofWithContext(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+
+	unknown := common.HexToHash("0x01")
+	blockHash, ok, err := mgr.VerifyProofAcrossBlocks(ctx, proof, []common.Hash{unknown, competing.Hash(), including.Hash()})
+	if err != nil {
+		t.Fatalf("VerifyProofAcrossBlocks failed: %v", err)
+	}
+	if !ok || blockHash != including.Hash() {
+		t.Errorf("VerifyProofAcrossBlocks = %s, %v; want the including block", blockHash.Hex(), ok)
+	}
+
+	if _, ok, err := mgr.VerifyProofAcrossBlocks(ctx, proof, []common.Hash{competing.Hash()}); ok || err != nil {
+		t.Errorf("VerifyProofAcrossBlocks against the competing block only = %v, %v; want false, nil", ok, err)
+	}
+	if _, _, err := mgr.VerifyProofAcrossBlocks(ctx, proof, []common.Hash{unknown}); err == nil {
+		t.Error("expected an error when no candidate can be fetched")
+	}
+}
+
+func TestConfirmInclusion(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+	ctx := context.Background()
+
+	first := signedTx(t, backend, key, 0, []byte("first"))
+	second := signedTx(t, backend, key, 1, []byte("second"))
+	block := backend.AddBlock(first, second)
+
+	tests := []struct {
+		name     string
+		index    uint
+		expected common.Hash
+		want     bool
+	}{
+		{"matching", 1, second.Hash(), true},
+		{"mismatching", 0, second.Hash(), false},
+		{"index out of range", 2, second.Hash(), false},
+	}
+	for _, tt := range tests {
+		included, err := mgr.ConfirmInclusion(ctx, block.Hash(), tt.index, tt.expected)
+		if err != nil {
+			t.Fatalf("%s: ConfirmInclusion failed: %v", tt.name, err)
+		}
+		if included != tt.want {
+			t.Errorf("%s: ConfirmInclusion = %v, want %v", tt.name, included, tt.want)
+		}
+	}
+
+	if calls := backend.Calls("eth_getTransactionByBlockHashAndIndex"); calls != len(tests) {
+		t.Errorf("node was asked %d times, want %d", calls, len(tests))
+	}
+}
+
+func TestProofBundle(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+	ctx := context.Background()
+
+	var txs []*types.Transaction
+	for i := uint64(0); i < 5; i++ {
+		txs = append(txs, signedTx(t, backend, key, i, []byte{byte(i)}))
+	}
+	block := backend.AddBlock(txs...)
+	other := backend.AddBlock(signedTx(t, backend, key, 5, []byte("other")))
+
+	bundle, err := mgr.GenerateProofBundle(ctx, txs[3].Hash())
+	if err != nil {
+		t.Fatalf("GenerateProofBundle failed: %v", err)
+	}
+	if bundle.Header.Hash() != block.Hash() {
+		t.Fatalf("bundle header %s, want block %s", bundle.Header.Hash(), block.Hash())
+	}
+
+	// Verification must not need the node
+	mgr.Close()
+	requests := backend.Requests()
+	if ok, err := transaction.VerifyProofBundle(bundle); !ok || err != nil {
+		t.Fatalf("VerifyProofBundle = %v, %v; want true", ok, err)
+	}
+	if got := backend.Requests(); got != requests {
+		t.Errorf("VerifyProofBundle made %d node requests, want 0", got-requests)
+	}
+
+	tamper := func(name string, modify func(b *transaction.ProofBundle)) {
+		t.Helper()
+		proof := *bundle.Proof
+		tampered := *bundle
+		tampered.Proof = &proof
+		modify(&tampered)
+		if ok, err := transaction.VerifyProofBundle(&tampered); ok || err == nil {
+			t.Errorf("%s: VerifyProofBundle = %v, %v; want false with an error", name, ok, err)
+		}
+	}
+	tamper("wrong header", func(b *transaction.ProofBundle) { b.Header = other.Header() })
+	tamper("wrong index", func(b *transaction.ProofBundle) { b.Proof.TransactionIndex = 2 })
+	tamper("missing trie nodes", func(b *transaction.ProofBundle) { b.TxProof = b.TxProof[:len(b.TxProof)-1] })
+	tamper("wrong custom data", func(b *transaction.ProofBundle) { b.Proof.CustomData = []byte("forged") })
+	tamper("wrong transaction", func(b *transaction.ProofBundle) { b.Proof.Transaction = txs[2] })
+}
+
+// BenchmarkVerifyLargeBlock verifies proofs from a 2000-transaction block
+// concurrently with a cold tree cache, building the tree sequentially and
+// with parallel workers
+func BenchmarkVerifyLargeBlock(b *testing.B) {
+	for _, workers := range []int{1, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			backend, mgr := newTestManager(b, transaction.WithTreeWorkers(workers))
+			key, _ := crypto.GenerateKey()
+			ctx := context.Background()
+
+			txs := make([]*types.Transaction, 2000)
+			for i := range txs {
+				txs[i] = signedTx(b, backend, key, uint64(i), []byte{byte(i)})
+			}
+			block := backend.AddBlock(txs...)
+
+			var hashes []common.Hash
+			for i := 0; i < len(txs); i += 64 {
+				hashes = append(hashes, txs[i].Hash())
+			}
+			proofs, errs := mgr.GenerateProofs(ctx, hashes)
+			for _, err := range errs {
+				if err != nil {
+					b.Fatalf("GenerateProofs failed: %v", err)
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				mgr.InvalidateBlock(block.Hash())
+
+				var wg sync.WaitGroup
+				for _, proof := range proofs {
+					wg.Add(1)
+					go func(proof *transaction.Proof) {
+						defer wg.Done()
+						if ok, err := mgr.VerifyProofWithContext(ctx, proof); !ok || err != nil {
+							b.Errorf("VerifyProof = %v, %v", ok, err)
+						}
+					}(proof)
+				}
+				wg.Wait()
+			}
+		})
+	}
+}
+
+func TestSharedBuildOutlivesCancelledCaller(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+
+	tx := signedTx(t, backend, key, 0, []byte("shared"))
+	block := backend.AddBlock(tx)
+	proof, err := mgr.GenerateProof(tx.Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+	mgr.InvalidateBlock(block.Hash())
+	fetchesBefore := backend.Calls("eth_getBlockByHash")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	backend.OnCall("eth_getBlockByHash", func(call int) {
+		if call == fetchesBefore+1 {
+			close(started)
+			<-release
+		}
+	})
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := mgr.VerifyProofWithContext(cancelledCtx, proof)
+		cancelled <- err
+	}()
+	<-started
+
+	type outcome struct {
+		valid bool
+		err   error
+	}
+	waiting := make(chan outcome, 1)
+	go func() {
+		valid, err := mgr.VerifyProofWithContext(context.Background(), proof)
+		waiting <- outcome{valid, err}
+	}()
+	// Let the second caller join the fetch before the first gives up
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("cancelled caller got %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancelled caller still waiting on the shared fetch")
+	}
+
+	close(release)
+	select {
+	case got := <-waiting:
+		if !got.valid || got.err != nil {
+			t.Errorf("other caller got %v, %v, want a valid proof", got.valid, got.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("other caller did not finish")
+	}
+	if fetches := backend.Calls("eth_getBlockByHash") - fetchesBefore; fetches != 1 {
+		t.Errorf("block fetched %d times, want 1", fetches)
+	}
+}
+
+func TestVerifyProofWithLog(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("emits"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	backend.Mine()
+
+	transfer := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	approval := crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
+	backend.SetReceiptLogs(tx.Hash(), &types.Log{
+		Address: testRecipient,
+		Topics:  []common.Hash{transfer, common.BytesToHash(mgr.Address().Bytes())},
+		Data:    []byte{1},
+	})
+
+	proof, err := mgr.GenerateProofWithContext(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+	if len(proof.Logs) != 1 || proof.Logs[0].Topics[0] != transfer {
+		t.Fatalf("proof logs = %v, want the receipt's Transfer log", proof.Logs)
+	}
+
+	if valid, err := mgr.VerifyProofWithLog(proof, transfer); !valid || err != nil {
+		t.Errorf("VerifyProofWithLog(Transfer) = %v, %v; want true", valid, err)
+	}
+	if valid, err := mgr.VerifyProofWithLog(proof, approval); valid || !errors.Is(err, transaction.ErrLogNotFound) {
+		t.Errorf("VerifyProofWithLog(Approval) = %v, %v; want ErrLogNotFound", valid, err)
+	}
+
+	// Logs added to the proof but not emitted on chain are rejected
+	forged := *proof
+	forged.Logs = append([]*types.Log{{Address: testRecipient, Topics: []common.Hash{approval}}}, proof.Logs...)
+	if valid, err := mgr.VerifyProofWithLog(&forged, approval); valid || err == nil {
+		t.Errorf("VerifyProofWithLog with a forged log = %v, %v; want an error", valid, err)
+	}
+}
+
+func TestWireProofRoundTrip(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("carried"), []byte{0xca, 0xfe})
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	backend.Mine()
+	topic := crypto.Keccak256Hash([]byte("Carried()"))
+	backend.SetReceiptLogs(tx.Hash(), &types.Log{Address: testRecipient, Topics: []common.Hash{topic}, Data: []byte{7}})
+
+	proof, err := mgr.GenerateProofWithContext(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+
+	wire, err := proof.ToWire()
+	if err != nil {
+		t.Fatalf("ToWire failed: %v", err)
+	}
+	encoded, err := json.Marshal(wire)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var decoded transaction.WireProof
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	got, err := transaction.FromWire(&decoded)
+	if err != nil {
+		t.Fatalf("FromWire failed: %v", err)
+	}
+
+	if got.Transaction.Hash() != proof.Transaction.Hash() || got.BlockHash != proof.BlockHash ||
+		got.BlockNumber.Cmp(proof.BlockNumber) != 0 || got.TransactionIndex != proof.TransactionIndex ||
+		!bytes.Equal(got.CustomData, proof.CustomData) || !slices.Equal(got.ProofPath, proof.ProofPath) {
+		t.Errorf("round-tripped proof = %+v, want %+v", got, proof)
+	}
+	// Compare the receipts as the node would serve them
+	gotReceipt, _ := json.Marshal(got.Receipt)
+	wantReceipt, _ := json.Marshal(proof.Receipt)
+	if !bytes.Equal(gotReceipt, wantReceipt) {
+		t.Errorf("round-tripped receipt = %s, want %s", gotReceipt, wantReceipt)
+	}
+	if !reflect.DeepEqual(got.Logs, proof.Logs) {
+		t.Errorf("round-tripped logs = %+v, want %+v", got.Logs, proof.Logs)
+	}
+
+	decoded.Version = transaction.WireFormatV1 + 1
+	if _, err := transaction.FromWire(&decoded); !errors.Is(err, transaction.ErrUnsupportedWireFormat) {
+		t.Errorf("FromWire of a newer version error = %v, want ErrUnsupportedWireFormat", err)
+	}
+}
+
+func TestVerifyWireProof(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	key, _ := crypto.GenerateKey()
+	block := backend.AddBlock(
+		signedTx(t, backend, key, 0, []byte("first")),
+		signedTx(t, backend, key, 1, []byte("second")),
+		signedTx(t, backend, key, 2, []byte("third")),
+	)
+	proof, err := mgr.GenerateProofWithContext(ctx, block.Transactions()[2].Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+	wire, err := proof.ToWire()
+	if err != nil {
+		t.Fatalf("ToWire failed: %v", err)
+	}
+
+	// Verify against the node rather than anything cached for the original
+	reconstructed, err := transaction.FromWire(wire)
+	if err != nil {
+		t.Fatalf("FromWire failed: %v", err)
+	}
+	if valid, err := mgr.VerifyProofWithOptions(ctx, reconstructed, transaction.ProofOptions{BypassCache: true}); !valid || err != nil {
+		t.Errorf("VerifyProof of the reconstructed proof = %v, %v; want true", valid, err)
+	}
+}
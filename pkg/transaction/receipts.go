@@ -0,0 +1,55 @@
+package transaction
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// BlockNumber returns the number of the node's latest block
+func (m *Manager) BlockNumber(ctx context.Context) (uint64, error) {
+	number, err := m.clientPool.Get().BlockNumber(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get block number: %w", err)
+	}
+	return number, nil
+}
+
+// BlockReceipts fetches every receipt of block number in one
+// eth_getBlockReceipts round-trip. The receipts are cached and count towards
+// TotalGasSpent like receipts fetched one by one.
+func (m *Manager) BlockReceipts(ctx context.Context, number uint64) (types.Receipts, error) {
+	receipts, err := m.clientPool.Get().BlockReceipts(ctx, rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(number)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipts of block %d: %w", number, err)
+	}
+
+	for _, receipt := range receipts {
+		m.ledger.observe(receipt)
+		m.receiptCache.Set(receipt.TxHash, receipt)
+	}
+	return receipts, nil
+}
+
+// CompareOrder reports whether hashA was mined before (-1), at the same
+// position as (0) or after (1) hashB, by block number and then by index
+// within the block. Both transactions must be mined.
+func (m *Manager) CompareOrder(ctx context.Context, hashA, hashB common.Hash) (int, error) {
+	a, err := m.getReceipt(ctx, hashA, ProofOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("transaction %s: failed to get receipt: %w", hashA.Hex(), err)
+	}
+	b, err := m.getReceipt(ctx, hashB, ProofOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("transaction %s: failed to get receipt: %w", hashB.Hex(), err)
+	}
+
+	if c := a.BlockNumber.Cmp(b.BlockNumber); c != 0 {
+		return c, nil
+	}
+	return cmp.Compare(a.TransactionIndex, b.TransactionIndex), nil
+}
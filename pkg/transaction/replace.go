@@ -0,0 +1,171 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// DefaultMinBumpPercent is the smallest fee bump accepted for
+	// replacements, matching the minimum most nodes require
+	DefaultMinBumpPercent = 10
+	// ReplacementSearchDepth is how many recent blocks are searched for the
+	// transaction that replaced one of the manager's
+	ReplacementSearchDepth = 256
+)
+
+// ErrBumpTooSmall is returned when a replacement's fee bump is below the
+// configured minimum, which nodes would reject as underpriced
+var ErrBumpTooSmall = errors.New("fee bump below the replacement minimum")
+
+// ErrReplaced is returned when a transaction was never mined because another
+// with the same sender and nonce was mined instead. The error is a
+// *ReplacedError carrying the replacement's hash.
+var ErrReplaced = errors.New("transaction replaced")
+
+// ReplacedError reports the transaction that replaced TxHash
+type ReplacedError struct {
+	TxHash      common.Hash
+	Replacement common.Hash
+}
+
+func (e *ReplacedError) Error() string {
+	return fmt.Sprintf("transaction %s replaced by %s", e.TxHash.Hex(), e.Replacement.Hex())
+}
+
+// Is makes errors.Is(err, ErrReplaced) match
+func (e *ReplacedError) Is(target error) bool {
+	return target == ErrReplaced
+}
+
+// SpeedUp replaces the pending transaction tx, sent by this manager, with a
+// copy paying bumpPercent more in both fee cap and tip. The recipient, value,
+// data and nonce are kept.
+func (m *Manager) SpeedUp(ctx context.Context, tx *types.Transaction, bumpPercent int) (*types.Transaction, error) {
+	return m.replace(ctx, tx, bumpPercent, &types.DynamicFeeTx{
+		To:    tx.To(),
+		Value: tx.Value(),
+		Gas:   tx.Gas(),
+		Data:  tx.Data(),
+	})
+}
+
+// Cancel replaces the pending transaction tx, sent by this manager, with a
+// zero-value transfer to the manager's own address at the same nonce, paying
+// bumpPercent more in both fee cap and tip
+func (m *Manager) Cancel(ctx context.Context, tx *types.Transaction, bumpPercent int) (*types.Transaction, error) {
+	return m.replace(ctx, tx, bumpPercent, &types.DynamicFeeTx{
+		To:    &m.address,
+		Value: new(big.Int),
+		Gas:   params.TxGas,
+	})
+}
+
+// replace fills in the chain ID, nonce and bumped fees of replacement, then
+// signs and sends it
+func (m *Manager) replace(
+	ctx context.Context,
+	tx *types.Transaction,
+	bumpPercent int,
+	replacement *types.DynamicFeeTx,
+) (*types.Transaction, error) {
+	if bumpPercent < m.minBumpPercent {
+		return nil, fmt.Errorf("%w: %d%%, minimum is %d%%", ErrBumpTooSmall, bumpPercent, m.minBumpPercent)
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(m.chainID), tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover sender: %w", err)
+	}
+	if from != m.address {
+		return nil, fmt.Errorf("transaction %s was sent by %s, not %s", tx.Hash().Hex(), from.Hex(), m.address.Hex())
+	}
+
+	replacement.ChainID = m.chainID
+	replacement.Nonce = tx.Nonce()
+	replacement.GasTipCap = bump(tx.GasTipCap(), bumpPercent)
+	replacement.GasFeeCap = bump(tx.GasFeeCap(), bumpPercent)
+	replacement.AccessList = types.AccessList{}
+
+	signedTx, err := m.signer.SignTx(types.NewTx(replacement), m.chainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+
+	if err := m.broadcast(ctx, signedTx); err != nil {
+		m.metrics.IncrementTxFailed()
+		return nil, fmt.Errorf("failed to send replacement: %w", err)
+	}
+
+	// Tracked under tx's nonce, so once either is mined the other is dropped
+	m.ledger.track(signedTx)
+	m.metrics.IncrementTxSent()
+	return signedTx, nil
+}
+
+// bump raises fee by percent, rounding up so the bump is never short
+func bump(fee *big.Int, percent int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(int64(100+percent)))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+// findReplacement looks for a mined transaction that took the nonce of
+// txHash, one of the manager's unmined transactions. It returns nil if
+// txHash was not sent by this manager, its nonce is still unused, or no
+// replacement is found within ReplacementSearchDepth blocks.
+func (m *Manager) findReplacement(ctx context.Context, txHash common.Hash) (*ReplacedError, error) {
+	nonce, ok := m.ledger.nonceOf(txHash)
+	if !ok {
+		return nil, nil
+	}
+	return m.searchReplacement(ctx, txHash, m.address, nonce)
+}
+
+// searchReplacement looks for a mined transaction from sender, other than
+// txHash, at nonce. It returns nil if the nonce is still unused or no
+// replacement is found within ReplacementSearchDepth blocks.
+func (m *Manager) searchReplacement(
+	ctx context.Context,
+	txHash common.Hash,
+	sender common.Address,
+	nonce uint64,
+) (*ReplacedError, error) {
+	client := m.clientPool.Get()
+	next, err := client.NonceAt(ctx, sender, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nonce: %w", err)
+	}
+	if next <= nonce {
+		return nil, nil
+	}
+
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block number: %w", err)
+	}
+
+	signer := types.LatestSignerForChainID(m.chainID)
+	for depth := uint64(0); depth < ReplacementSearchDepth && depth <= head; depth++ {
+		block, err := client.BlockByNumber(ctx, new(big.Int).SetUint64(head-depth))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get block %d: %w", head-depth, err)
+		}
+		for _, tx := range block.Transactions() {
+			if tx.Nonce() != nonce || tx.Hash() == txHash {
+				continue
+			}
+			if from, err := types.Sender(signer, tx); err == nil && from == sender {
+				m.ledger.forget(txHash)
+				return &ReplacedError{TxHash: txHash, Replacement: tx.Hash()}, nil
+			}
+		}
+	}
+	return nil, nil
+}
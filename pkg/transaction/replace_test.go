@@ -0,0 +1,235 @@
+package transaction_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+// bumped returns fee raised by percent, rounded up
+func bumped(fee *big.Int, percent int64) *big.Int {
+	b := new(big.Int).Mul(fee, big.NewInt(100+percent))
+	b.Add(b, big.NewInt(99))
+	return b.Div(b, big.NewInt(100))
+}
+
+func TestSpeedUp(t *testing.T) {
+	backend, mgr := newTestManager(t, transaction.WithMinBumpPercent(12))
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, big.NewInt(5), []byte("slow"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+
+	if _, err := mgr.SpeedUp(ctx, tx, 9); !errors.Is(err, transaction.ErrBumpTooSmall) {
+		t.Fatalf("SpeedUp with a 9%% bump error = %v, want ErrBumpTooSmall", err)
+	}
+	if pending := backend.Pending(); len(pending) != 1 {
+		t.Fatalf("node received %d transactions after a rejected bump, want 1", len(pending))
+	}
+
+	faster, err := mgr.SpeedUp(ctx, tx, 12)
+	if err != nil {
+		t.Fatalf("SpeedUp failed: %v", err)
+	}
+	if faster.Nonce() != tx.Nonce() || *faster.To() != *tx.To() || faster.Value().Cmp(tx.Value()) != 0 {
+		t.Error("SpeedUp changed the nonce, recipient or value")
+	}
+	if got, _ := transaction.GetCustomData(faster); string(got) != "slow" {
+		t.Errorf("custom data = %q, want it kept", got)
+	}
+	if want := bumped(tx.GasFeeCap(), 12); faster.GasFeeCap().Cmp(want) != 0 {
+		t.Errorf("fee cap = %v, want %v", faster.GasFeeCap(), want)
+	}
+	if want := bumped(tx.GasTipCap(), 12); faster.GasTipCap().Cmp(want) != 0 {
+		t.Errorf("tip cap = %v, want %v", faster.GasTipCap(), want)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	_, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, big.NewInt(5), []byte("unwanted"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+
+	if _, err := mgr.Cancel(ctx, tx, transaction.DefaultMinBumpPercent-1); !errors.Is(err, transaction.ErrBumpTooSmall) {
+		t.Fatalf("Cancel below the default floor error = %v, want ErrBumpTooSmall", err)
+	}
+
+	cancel, err := mgr.Cancel(ctx, tx, transaction.DefaultMinBumpPercent)
+	if err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if cancel.Nonce() != tx.Nonce() || *cancel.To() != mgr.Address() || cancel.Value().Sign() != 0 || len(cancel.Data()) != 0 {
+		t.Errorf("cancel is not an empty self-transfer at nonce %d", tx.Nonce())
+	}
+}
+
+func TestReplacementSettlesOriginal(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, big.NewInt(5), []byte("slow"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	faster, err := mgr.SpeedUp(ctx, tx, transaction.DefaultMinBumpPercent)
+	if err != nil {
+		t.Fatalf("SpeedUp failed: %v", err)
+	}
+	if pending := mgr.Health(ctx).PendingTransactions; pending != 1 {
+		t.Errorf("PendingTransactions after SpeedUp = %d, want 1", pending)
+	}
+
+	backend.AddBlock(faster)
+	if _, err := mgr.AwaitMined(ctx, faster.Hash()); err != nil {
+		t.Fatalf("AwaitMined failed: %v", err)
+	}
+	if pending := mgr.Health(ctx).PendingTransactions; pending != 0 {
+		t.Errorf("PendingTransactions after the replacement mined = %d, want 0", pending)
+	}
+}
+
+func TestGenerateProofReplaced(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, big.NewInt(5), []byte("slow"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	// The node evicts the original when the replacement arrives
+	backend.FlushPool()
+	faster, err := mgr.SpeedUp(ctx, tx, 20)
+	if err != nil {
+		t.Fatalf("SpeedUp failed: %v", err)
+	}
+	backend.Mine()
+	backend.Mine()
+
+	_, err = mgr.GenerateProofWithContext(ctx, tx.Hash())
+	if !errors.Is(err, transaction.ErrReplaced) {
+		t.Fatalf("GenerateProof error = %v, want ErrReplaced", err)
+	}
+	var replaced *transaction.ReplacedError
+	if !errors.As(err, &replaced) || replaced.Replacement != faster.Hash() || replaced.TxHash != tx.Hash() {
+		t.Fatalf("error = %v, want %s replaced by %s", err, tx.Hash(), faster.Hash())
+	}
+
+	if _, err := mgr.GenerateProofWithContext(ctx, faster.Hash()); err != nil {
+		t.Errorf("GenerateProof for the replacement failed: %v", err)
+	}
+}
+
+func TestGenerateProofUnknownNotReplaced(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, big.NewInt(5), []byte("dropped"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	backend.FlushPool()
+	backend.Mine()
+
+	// The nonce is still unused, so the transaction was dropped, not replaced
+	_, err = mgr.GenerateProofWithContext(ctx, tx.Hash())
+	if err == nil || errors.Is(err, transaction.ErrReplaced) {
+		t.Fatalf("GenerateProof error = %v, want a plain not-found error", err)
+	}
+}
+
+func TestResendAllPending(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	mined, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("mined"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	backend.Mine()
+
+	var pending []*types.Transaction
+	for i := 0; i < 2; i++ {
+		tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte{byte(i)}, nil)
+		if err != nil {
+			t.Fatalf("SendWithContext failed: %v", err)
+		}
+		pending = append(pending, tx)
+	}
+
+	// The node restarts with an empty mempool
+	backend.FlushPool()
+
+	hashes, errs := mgr.ResendAllPending(ctx)
+	if len(errs) != 0 {
+		t.Fatalf("ResendAllPending errors: %v", errs)
+	}
+	if len(hashes) != len(pending) || hashes[0] != pending[0].Hash() || hashes[1] != pending[1].Hash() {
+		t.Fatalf("ResendAllPending = %v, want the pending transactions in nonce order", hashes)
+	}
+	for _, txHash := range hashes {
+		if txHash == mined.Hash() {
+			t.Error("a mined transaction was rebroadcast")
+		}
+	}
+
+	pool := backend.Pending()
+	if len(pool) != len(pending) || pool[0].Hash() != pending[0].Hash() || pool[1].Hash() != pending[1].Hash() {
+		t.Fatalf("mempool holds %d transactions, want the %d resent ones", len(pool), len(pending))
+	}
+
+	// Transactions the node still holds are not sent again
+	if hashes, errs := mgr.ResendAllPending(ctx); len(hashes) != 0 || len(errs) != 0 {
+		t.Errorf("second ResendAllPending = %v, %v; want nothing resent", hashes, errs)
+	}
+}
+
+func TestResendAllPendingSkipsReplaced(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	ctx := context.Background()
+
+	send := func(data string) *types.Transaction {
+		t.Helper()
+		tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte(data), nil)
+		if err != nil {
+			t.Fatalf("SendWithContext failed: %v", err)
+		}
+		return tx
+	}
+	speedUp := func(tx *types.Transaction) *types.Transaction {
+		t.Helper()
+		faster, err := mgr.SpeedUp(ctx, tx, transaction.DefaultMinBumpPercent)
+		if err != nil {
+			t.Fatalf("SpeedUp failed: %v", err)
+		}
+		return faster
+	}
+
+	// The first nonce is mined through its replacement without the manager
+	// seeing a receipt
+	backend.AddBlock(speedUp(send("replaced and mined")))
+	plain := send("plain")
+	faster := speedUp(send("replaced"))
+
+	backend.FlushPool()
+	hashes, errs := mgr.ResendAllPending(ctx)
+	if len(errs) != 0 {
+		t.Fatalf("ResendAllPending errors: %v", errs)
+	}
+	if len(hashes) != 2 || hashes[0] != plain.Hash() || hashes[1] != faster.Hash() {
+		t.Fatalf("ResendAllPending = %v, want only %s and the replacement %s", hashes, plain.Hash().Hex(), faster.Hash().Hex())
+	}
+	if pending := mgr.Health(ctx).PendingTransactions; pending != 2 {
+		t.Errorf("PendingTransactions = %d, want the mined nonce forgotten", pending)
+	}
+}
@@ -0,0 +1,51 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ResendAllPending rebroadcasts, in nonce order, every transaction the
+// manager sent whose receipt it has not observed, e.g. after a node restart
+// emptied the mempool. Transactions below the account's confirmed nonce
+// are forgotten, and of a transaction and its replacements only the one
+// paying the most is sent. Transactions that turn out to be mined are
+// recorded as such and skipped, as are those the node still holds. It
+// returns the hashes that were rebroadcast and one error per transaction
+// that could not be checked or sent.
+func (m *Manager) ResendAllPending(ctx context.Context) ([]common.Hash, []error) {
+	var hashes []common.Hash
+	var errs []error
+
+	client := m.clientPool.Get()
+	confirmed, err := client.NonceAt(ctx, m.address, nil)
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to get nonce: %w", err)}
+	}
+	m.ledger.pruneBelow(confirmed)
+
+	for _, tx := range m.ledger.pendingTransactions() {
+		if _, err := m.getReceipt(ctx, tx.Hash(), ProofOptions{}); err == nil {
+			continue
+		} else if !errors.Is(err, ethereum.NotFound) {
+			errs = append(errs, fmt.Errorf("transaction %s: failed to get receipt: %w", tx.Hash().Hex(), err))
+			continue
+		}
+
+		if _, _, err := client.TransactionByHash(ctx, tx.Hash()); err == nil {
+			continue
+		}
+
+		if err := m.broadcast(ctx, tx); err != nil {
+			m.metrics.IncrementTxFailed()
+			errs = append(errs, fmt.Errorf("transaction %s: failed to resend: %w", tx.Hash().Hex(), err))
+			continue
+		}
+		hashes = append(hashes, tx.Hash())
+	}
+	return hashes, errs
+}
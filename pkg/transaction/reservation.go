@@ -0,0 +1,118 @@
+package transaction
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// FileReservationStore keeps the nonces SendIdempotent reserves per key in
+// a JSON file, for WithReservationStore. Every change rewrites the file
+// through a temporary file and a rename, so a crash leaves either the old
+// or the new contents.
+type FileReservationStore struct {
+	path string
+
+	mu sync.Mutex
+}
+
+// NewFileReservationStore returns a store kept at path. The file is created
+// on the first reservation.
+func NewFileReservationStore(path string) *FileReservationStore {
+	return &FileReservationStore{path: path}
+}
+
+func (s *FileReservationStore) Load(address common.Address) (map[string]uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return all[address], nil
+}
+
+func (s *FileReservationStore) Save(address common.Address, key string, nonce uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	if all[address] == nil {
+		all[address] = make(map[string]uint64)
+	}
+	all[address][key] = nonce
+	return s.write(all)
+}
+
+func (s *FileReservationStore) Delete(address common.Address, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, exists := all[address][key]; !exists {
+		return nil
+	}
+	delete(all[address], key)
+	if len(all[address]) == 0 {
+		delete(all, address)
+	}
+	return s.write(all)
+}
+
+func (s *FileReservationStore) read() (map[common.Address]map[string]uint64, error) {
+	all := make(map[common.Address]map[string]uint64)
+
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reservations: %w", err)
+	}
+	if err := json.Unmarshal(raw, &all); err != nil {
+		return nil, fmt.Errorf("failed to decode reservations: %w", err)
+	}
+	return all, nil
+}
+
+func (s *FileReservationStore) write(all map[common.Address]map[string]uint64) error {
+	raw, err := json.Marshal(all)
+	if err != nil {
+		return fmt.Errorf("failed to encode reservations: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write reservations: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write reservations: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write reservations: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write reservations: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write reservations: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package transaction
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// MaxReorgDepth bounds how many blocks StreamCustomTransactions rewinds on a reorg
+const MaxReorgDepth = 64
+
+// ScanBlocks returns the custom transactions mined in blocks [from, to]
+func (m *Manager) ScanBlocks(ctx context.Context, from, to *big.Int) ([]*types.Transaction, error) {
+	if from == nil || to == nil {
+		return nil, fmt.Errorf("block range bounds must not be nil")
+	}
+	if from.Cmp(to) > 0 {
+		return nil, fmt.Errorf("invalid block range: from %s is after to %s", from, to)
+	}
+
+	var found []*types.Transaction
+	for number := new(big.Int).Set(from); number.Cmp(to) <= 0; number.Add(number, big.NewInt(1)) {
+		block, err := m.getBlockByNumber(ctx, number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get block %s: %w", number, err)
+		}
+		custom := m.customTransactions(block)
+		m.schemaBlooms.Set(block.Hash(), schemaBloom(custom))
+		found = append(found, custom...)
+	}
+
+	return found, nil
+}
+
+// ScanBlocksBySchema returns the custom transactions mined in blocks
+// [from, to] whose envelope carries one of schemaIDs; pass NoSchemaID to
+// include payloads without one. Blocks already scanned by ScanBlocks or
+// ScanBlocksBySchema keep a bloom filter of their schema ids, so a block
+// that cannot hold a wanted id is skipped after fetching only its header.
+func (m *Manager) ScanBlocksBySchema(ctx context.Context, from, to *big.Int, schemaIDs ...uint16) ([]*types.Transaction, error) {
+	if from == nil || to == nil {
+		return nil, fmt.Errorf("block range bounds must not be nil")
+	}
+	if from.Cmp(to) > 0 {
+		return nil, fmt.Errorf("invalid block range: from %s is after to %s", from, to)
+	}
+	if len(schemaIDs) == 0 {
+		return nil, nil
+	}
+
+	var found []*types.Transaction
+	for number := new(big.Int).Set(from); number.Cmp(to) <= 0; number.Add(number, big.NewInt(1)) {
+		header, err := m.clientPool.Get().HeaderByNumber(ctx, number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get header %s: %w", number, err)
+		}
+		if bloom, ok := m.schemaBlooms.Get(header.Hash()); ok && !bloomHasAny(bloom, schemaIDs) {
+			continue
+		}
+
+		block, err := m.getBlock(ctx, header.Hash(), ProofOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get block %s: %w", number, err)
+		}
+		custom := m.customTransactions(block)
+		m.schemaBlooms.Set(block.Hash(), schemaBloom(custom))
+
+		for _, tx := range custom {
+			env, err := decodeEnvelope(tx.Data())
+			if err == nil && slices.Contains(schemaIDs, env.SchemaID) {
+				found = append(found, tx)
+			}
+		}
+	}
+
+	return found, nil
+}
+
+// schemaBloom builds a bloom filter over the schema ids of txs, skipping
+// malformed payloads
+func schemaBloom(txs []*types.Transaction) types.Bloom {
+	var bloom types.Bloom
+	for _, tx := range txs {
+		env, err := decodeEnvelope(tx.Data())
+		if err != nil {
+			continue
+		}
+		bloom.Add(binary.BigEndian.AppendUint16(nil, env.SchemaID))
+	}
+	return bloom
+}
+
+// bloomHasAny reports whether bloom may contain any of schemaIDs
+func bloomHasAny(bloom types.Bloom, schemaIDs []uint16) bool {
+	for _, id := range schemaIDs {
+		if bloom.Test(binary.BigEndian.AppendUint16(nil, id)) {
+			return true
+		}
+	}
+	return false
+}
+
+// StreamCustomTransactions scans from startBlock (or the current finalized
+// block if nil) and then follows the finalized block, emitting custom
+// transactions block by block as blocks finalize. With
+// WithStreamConfirmations it follows the head minus the confirmation depth
+// instead; if such a block is reorganised out, replaced blocks are
+// rescanned, so consumers may see a transaction more than once. Transient
+// RPC errors are reported on the error channel without stopping the
+// stream; both channels are closed once ctx is done.
+func (m *Manager) StreamCustomTransactions(
+	ctx context.Context,
+	startBlock *big.Int,
+) (<-chan *types.Transaction, <-chan error) {
+	txs := make(chan *types.Transaction)
+	errs := make(chan error, 1)
+
+	s := &blockStream{
+		manager: m,
+		seen:    make(map[uint64]common.Hash),
+		txs:     txs,
+	}
+	if startBlock != nil {
+		s.start = startBlock.Uint64()
+		s.next = s.start
+		s.started = true
+	}
+
+	go func() {
+		defer close(txs)
+		defer close(errs)
+
+		ticker := time.NewTicker(m.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.poll(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				select {
+				case errs <- err:
+				default:
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return txs, errs
+}
+
+// blockStream tracks the canonical blocks scanned by StreamCustomTransactions
+type blockStream struct {
+	manager *Manager
+	start   uint64
+	next    uint64
+	started bool
+	head    common.Hash            // hash of the last target block scanned up to
+	seen    map[uint64]common.Hash // block number -> hash of scanned block
+	txs     chan<- *types.Transaction
+}
+
+// target returns the newest block the stream may emit: the finalized block,
+// or the head minus the confirmation depth. ok is false while the chain is
+// shorter than the depth.
+func (s *blockStream) target(ctx context.Context, client *ethclient.Client) (*types.Header, bool, error) {
+	depth := s.manager.streamConfirmations
+	if depth == 0 {
+		header, err := client.HeaderByNumber(ctx, big.NewInt(int64(rpc.FinalizedBlockNumber)))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get finalized block: %w", err)
+		}
+		return header, true, nil
+	}
+
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get head: %w", err)
+	}
+	if head.Number.Uint64() < depth {
+		return nil, false, nil
+	}
+	header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(head.Number.Uint64()-depth))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get confirmed block: %w", err)
+	}
+	return header, true, nil
+}
+
+func (s *blockStream) poll(ctx context.Context) error {
+	client := s.manager.clientPool.Get()
+
+	tip, ok, err := s.target(ctx, client)
+	if err != nil || !ok {
+		return err
+	}
+	if tip.Hash() == s.head {
+		return nil
+	}
+	if !s.started {
+		s.start = tip.Number.Uint64()
+		s.next = s.start
+		s.started = true
+	}
+
+	// Rewind over scanned blocks that are no longer canonical
+	for depth := 0; s.next > s.start && depth < MaxReorgDepth; depth++ {
+		prev := s.next - 1
+		hash, ok := s.seen[prev]
+		if !ok {
+			break
+		}
+
+		header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(prev))
+		if err != nil {
+			return fmt.Errorf("failed to get header %d: %w", prev, err)
+		}
+		if header.Hash() == hash {
+			break
+		}
+
+		delete(s.seen, prev)
+		s.next = prev
+	}
+
+	for s.next <= tip.Number.Uint64() {
+		block, err := s.manager.getBlockByNumber(ctx, new(big.Int).SetUint64(s.next))
+		if err != nil {
+			return fmt.Errorf("failed to get block %d: %w", s.next, err)
+		}
+
+		// The chain moved while scanning; the next poll rewinds past the fork
+		if parent, ok := s.seen[s.next-1]; ok && block.ParentHash() != parent {
+			return nil
+		}
+
+		for _, tx := range s.manager.customTransactions(block) {
+			select {
+			case s.txs <- tx:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		s.seen[s.next] = block.Hash()
+		delete(s.seen, s.next-MaxReorgDepth)
+		s.next++
+	}
+
+	s.head = tip.Hash()
+	return nil
+}
+
+// SchemaHistogram counts the custom transactions in a block by schema id.
+// Custom transactions without a schema id are counted under NoSchemaID;
+// malformed payloads are skipped.
+func (m *Manager) SchemaHistogram(ctx context.Context, blockHash common.Hash) (map[uint16]int, error) {
+	block, err := m.getBlock(ctx, blockHash, ProofOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block: %w", err)
+	}
+
+	histogram := make(map[uint16]int)
+	for _, tx := range m.customTransactions(block) {
+		env, err := DecodeEnvelope(tx.Data())
+		if err != nil {
+			continue
+		}
+		histogram[env.SchemaID]++
+	}
+
+	return histogram, nil
+}
+
+func (m *Manager) getBlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	block, err := m.clientPool.Get().BlockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBody(block); err != nil {
+		return nil, err
+	}
+
+	m.blockCache.Set(block.Hash(), block)
+	return block, nil
+}
+
+// customTransactions returns block's custom transactions, matched strictly
+// under WithStrictScan
+func (m *Manager) customTransactions(block *types.Block) []*types.Transaction {
+	isCustom := IsCustomTransaction
+	if m.strictScan {
+		isCustom = IsCustomTransactionStrict
+	}
+
+	var found []*types.Transaction
+	for _, tx := range block.Transactions() {
+		if isCustom(tx) {
+			found = append(found, tx)
+		}
+	}
+	return found
+}
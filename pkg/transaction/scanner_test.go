@@ -0,0 +1,398 @@
+package transaction_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/k4rz4/ethereum-custom-transactions/internal/ethtest"
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+func TestScanBlocks(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+
+	custom := signedTx(t, backend, key, 0, []byte("first"))
+	backend.AddBlock(custom, signedTx(t, backend, key, 1, nil))
+	backend.AddBlock(signedTx(t, backend, key, 2, nil))
+
+	found, err := mgr.ScanBlocks(context.Background(), big.NewInt(0), big.NewInt(2))
+	if err != nil {
+		t.Fatalf("ScanBlocks failed: %v", err)
+	}
+	if len(found) != 1 || found[0].Hash() != custom.Hash() {
+		t.Fatalf("ScanBlocks found %d transactions, want only %s", len(found), custom.Hash().Hex())
+	}
+}
+
+func TestStreamCustomTransactions(t *testing.T) {
+	backend, mgr := newTestManager(t, transaction.WithPollInterval(20*time.Millisecond))
+	key, _ := crypto.GenerateKey()
+
+	first := signedTx(t, backend, key, 0, []byte("first"))
+	second := signedTx(t, backend, key, 1, []byte("second"))
+	backend.AddBlock(first)
+	backend.AddBlock(signedTx(t, backend, key, 2, nil))
+	backend.AddBlock(second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	txs, errs := mgr.StreamCustomTransactions(ctx, big.NewInt(1))
+
+	expectTx(t, txs, errs, first.Hash())
+	expectTx(t, txs, errs, second.Hash())
+
+	// Blocks mined after the stream started are picked up by polling
+	third := signedTx(t, backend, key, 3, []byte("third"))
+	backend.AddBlock(third)
+	expectTx(t, txs, errs, third.Hash())
+
+	// Replacing the head at the same height triggers a rescan
+	backend.Rewind(3)
+	replacement := signedTx(t, backend, key, 3, []byte("replacement"))
+	backend.AddBlock(replacement)
+	expectTx(t, txs, errs, replacement.Hash())
+
+	cancel()
+	for range txs {
+	}
+}
+
+func TestStreamFollowsFinality(t *testing.T) {
+	t.Run("finalized", func(t *testing.T) {
+		backend, mgr := newTestManager(t, transaction.WithPollInterval(20*time.Millisecond))
+		backend.SetFinalityLag(1)
+		testStreamLag(t, backend, mgr)
+	})
+	t.Run("confirmations", func(t *testing.T) {
+		backend, mgr := newTestManager(t,
+			transaction.WithPollInterval(20*time.Millisecond),
+			transaction.WithStreamConfirmations(1))
+		testStreamLag(t, backend, mgr)
+	})
+}
+
+// testStreamLag checks that a stream trailing the head by one block only
+// emits a block's transactions once another block is mined on top of it
+func testStreamLag(t *testing.T, backend *ethtest.Backend, mgr *transaction.Manager) {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+
+	first := signedTx(t, backend, key, 0, []byte("first"))
+	second := signedTx(t, backend, key, 1, []byte("second"))
+	backend.AddBlock(first)
+	backend.AddBlock(second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	txs, errs := mgr.StreamCustomTransactions(ctx, big.NewInt(1))
+	expectTx(t, txs, errs, first.Hash())
+
+	select {
+	case tx := <-txs:
+		t.Fatalf("got transaction %s from the unconfirmed head", tx.Hash().Hex())
+	case err := <-errs:
+		t.Fatalf("stream error: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	backend.AddBlock(signedTx(t, backend, key, 2, nil))
+	expectTx(t, txs, errs, second.Hash())
+
+	cancel()
+	for range txs {
+	}
+}
+
+func expectTx(t *testing.T, txs <-chan *types.Transaction, errs <-chan error, want common.Hash) {
+	t.Helper()
+
+	select {
+	case tx := <-txs:
+		if tx.Hash() != want {
+			t.Fatalf("got transaction %s, want %s", tx.Hash().Hex(), want.Hex())
+		}
+	case err := <-errs:
+		t.Fatalf("stream error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", want.Hex())
+	}
+}
+
+func TestSchemaHistogram(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+
+	withSchema := func(nonce uint64, schema uint16) *types.Transaction {
+		data := transaction.EncodeCustomDataWithOptions(nil, []byte("payload"), transaction.EncodeOptions{SchemaID: schema})
+		tx := types.NewTx(&types.DynamicFeeTx{
+			ChainID: backend.ChainID(), Nonce: nonce, To: &testRecipient,
+			Gas: 100000, GasTipCap: big.NewInt(1e9), GasFeeCap: big.NewInt(3e9), Data: data,
+		})
+		signed, err := types.SignTx(tx, backend.Signer(), key)
+		if err != nil {
+			t.Fatalf("SignTx failed: %v", err)
+		}
+		return signed
+	}
+
+	block := backend.AddBlock(
+		withSchema(0, 7),
+		withSchema(1, 9),
+		withSchema(2, 7),
+		signedTx(t, backend, key, 3, []byte("legacy")),
+		signedTx(t, backend, key, 4, nil),
+	)
+
+	histogram, err := mgr.SchemaHistogram(context.Background(), block.Hash())
+	if err != nil {
+		t.Fatalf("SchemaHistogram failed: %v", err)
+	}
+
+	want := map[uint16]int{7: 2, 9: 1, transaction.NoSchemaID: 1}
+	if len(histogram) != len(want) {
+		t.Fatalf("histogram = %v, want %v", histogram, want)
+	}
+	for id, count := range want {
+		if histogram[id] != count {
+			t.Errorf("schema %d counted %d times, want %d", id, histogram[id], count)
+		}
+	}
+}
+
+func TestScanBlocksBySchema(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+	ctx := context.Background()
+
+	withSchema := func(nonce uint64, schema uint16) *types.Transaction {
+		data := transaction.EncodeCustomDataWithOptions(nil, []byte("payload"), transaction.EncodeOptions{SchemaID: schema})
+		tx := types.NewTx(&types.DynamicFeeTx{
+			ChainID: backend.ChainID(), Nonce: nonce, To: &testRecipient,
+			Gas: 100000, GasTipCap: big.NewInt(1e9), GasFeeCap: big.NewInt(3e9), Data: data,
+		})
+		signed, err := types.SignTx(tx, backend.Signer(), key)
+		if err != nil {
+			t.Fatalf("SignTx failed: %v", err)
+		}
+		return signed
+	}
+
+	backend.AddBlock(withSchema(0, 7), signedTx(t, backend, key, 1, nil))
+	backend.AddBlock(withSchema(2, 9), signedTx(t, backend, key, 3, []byte("legacy")))
+	backend.AddBlock(signedTx(t, backend, key, 4, nil))
+	backend.AddBlock(withSchema(5, 7), withSchema(6, 9), withSchema(7, 11))
+	from, to := big.NewInt(1), big.NewInt(4)
+
+	bruteForce := func(ids ...uint16) []common.Hash {
+		txs, err := mgr.ScanBlocks(ctx, from, to)
+		if err != nil {
+			t.Fatalf("ScanBlocks failed: %v", err)
+		}
+		var hashes []common.Hash
+		for _, tx := range txs {
+			env, err := transaction.DecodeEnvelope(tx.Data())
+			if err == nil && slices.Contains(ids, env.SchemaID) {
+				hashes = append(hashes, tx.Hash())
+			}
+		}
+		return hashes
+	}
+	filtered := func(ids ...uint16) []common.Hash {
+		txs, err := mgr.ScanBlocksBySchema(ctx, from, to, ids...)
+		if err != nil {
+			t.Fatalf("ScanBlocksBySchema failed: %v", err)
+		}
+		var hashes []common.Hash
+		for _, tx := range txs {
+			hashes = append(hashes, tx.Hash())
+		}
+		return hashes
+	}
+
+	// The first filtered scan builds each block's filter; the one after
+	// ScanBlocks reuses them
+	for _, ids := range [][]uint16{{7}, {9, transaction.NoSchemaID}, {11}, {42}} {
+		cold := filtered(ids...)
+		want := bruteForce(ids...)
+		warm := filtered(ids...)
+		if !slices.Equal(cold, want) || !slices.Equal(warm, want) {
+			t.Errorf("schemas %v: filtered scans returned %v then %v, want %v", ids, cold, warm, want)
+		}
+	}
+}
+
+func TestAuditRange(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+	ctx := context.Background()
+
+	first := signedTx(t, backend, key, 0, []byte("first"))
+	backend.AddBlock(first, signedTx(t, backend, key, 1, nil))
+	broken := backend.AddBlock(signedTx(t, backend, key, 2, []byte("second")), signedTx(t, backend, key, 3, []byte("third")))
+	last := signedTx(t, backend, key, 4, []byte("last"))
+	backend.AddBlock(signedTx(t, backend, key, 5, nil), last)
+
+	proofs, err := mgr.AuditRange(ctx, big.NewInt(0), big.NewInt(3))
+	if err != nil {
+		t.Fatalf("AuditRange failed: %v", err)
+	}
+	if len(proofs) != 4 {
+		t.Fatalf("AuditRange returned %d proofs, want one per custom transaction (4)", len(proofs))
+	}
+	for _, proof := range proofs {
+		if valid, err := mgr.VerifyProofWithContext(ctx, proof); !valid {
+			t.Errorf("proof of %s does not verify: %v", proof.Transaction.Hash().Hex(), err)
+		}
+	}
+
+	// A block the node serves incompletely is reported; the others still are audited
+	mgr.InvalidateBlock(broken.Hash())
+	backend.TruncateBody(broken.Hash(), 1)
+	proofs, err = mgr.AuditRange(ctx, big.NewInt(0), big.NewInt(3))
+	if !errors.Is(err, transaction.ErrIncompleteBlock) {
+		t.Fatalf("AuditRange error = %v, want ErrIncompleteBlock", err)
+	}
+	if len(proofs) != 2 || proofs[0].Transaction.Hash() != first.Hash() || proofs[1].Transaction.Hash() != last.Hash() {
+		t.Errorf("AuditRange returned %d proofs, want those of the intact blocks", len(proofs))
+	}
+}
+
+func TestScanBlockTraces(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+	ctx := context.Background()
+
+	tx := signedTx(t, backend, key, 0, nil)
+	backend.AddBlock(tx)
+
+	forwarder := common.HexToAddress("0x00000000000000000000000000000000000000f0")
+	target := common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	inner := transaction.EncodeCustomData([]byte{0xab, 0xcd}, []byte("internal"))
+	// Anyone can call a contract with calldata that merely starts with
+	// MagicBytes; it must not fail the scan
+	malformed := append(append([]byte(nil), transaction.MagicBytes...), 0xff, 0xff, 0xff, 0xff, 0x01)
+	trace, err := json.Marshal([]map[string]interface{}{{
+		"txHash": tx.Hash(),
+		"result": map[string]interface{}{
+			"type":  "CALL",
+			"from":  crypto.PubkeyToAddress(key.PublicKey),
+			"to":    forwarder,
+			"input": hexutil.Bytes{0x01, 0x02, 0x03, 0x04},
+			"calls": []map[string]interface{}{{
+				"type":  "CALL",
+				"from":  forwarder,
+				"to":    target,
+				"input": hexutil.Bytes(malformed),
+			}, {
+				"type":  "CALL",
+				"from":  forwarder,
+				"to":    target,
+				"input": hexutil.Bytes(inner),
+			}},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	backend.SetBlockTrace(1, trace)
+
+	found, err := mgr.ScanBlockTraces(ctx, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("ScanBlockTraces failed: %v", err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("ScanBlockTraces found %d calls, want 1", len(found))
+	}
+	got := found[0]
+	if got.TxHash != tx.Hash() || got.From != forwarder || got.To != target || got.Depth != 1 {
+		t.Errorf("traced call = %+v, want %s -> %s at depth 1 in %s", got, forwarder.Hex(), target.Hex(), tx.Hash().Hex())
+	}
+	if string(got.CustomData) != "internal" || !bytes.Equal(got.Data, []byte{0xab, 0xcd}) {
+		t.Errorf("traced call data = %q, %x; want %q, abcd", got.CustomData, got.Data, "internal")
+	}
+
+	backend.SetError("debug_traceBlockByNumber", methodNotFound{})
+	if _, err := mgr.ScanBlockTraces(ctx, big.NewInt(1)); !errors.Is(err, transaction.ErrTracingUnavailable) {
+		t.Errorf("without debug namespace: error = %v, want ErrTracingUnavailable", err)
+	}
+}
+
+func TestCompareOrder(t *testing.T) {
+	backend, mgr := newTestManager(t)
+	key, _ := crypto.GenerateKey()
+	ctx := context.Background()
+
+	first := signedTx(t, backend, key, 0, []byte("A"))
+	second := signedTx(t, backend, key, 1, []byte("B"))
+	backend.AddBlock(first, second)
+	later := signedTx(t, backend, key, 2, []byte("C"))
+	backend.AddBlock(later)
+
+	tests := []struct {
+		name string
+		a, b common.Hash
+		want int
+	}{
+		{"same block, lower index first", first.Hash(), second.Hash(), -1},
+		{"same block, higher index first", second.Hash(), first.Hash(), 1},
+		{"same transaction", first.Hash(), first.Hash(), 0},
+		{"earlier block", second.Hash(), later.Hash(), -1},
+		{"later block", later.Hash(), first.Hash(), 1},
+	}
+	for _, tt := range tests {
+		got, err := mgr.CompareOrder(ctx, tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("%s: CompareOrder failed: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: CompareOrder = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := mgr.CompareOrder(ctx, first.Hash(), common.HexToHash("0x01")); err == nil {
+		t.Error("CompareOrder with an unknown transaction should fail")
+	}
+}
+
+func TestStrictScan(t *testing.T) {
+	backend, mgr := newTestManager(t, transaction.WithStrictScan(true))
+	key, _ := crypto.GenerateKey()
+
+	// A contract call whose selector happens to be MagicBytes
+	collision, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID:   backend.ChainID(),
+		Nonce:     1,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(3e9),
+		Gas:       100000,
+		To:        &testRecipient,
+		Data:      append(bytes.Clone(transaction.MagicBytes), bytes.Repeat([]byte{0x11}, 64)...),
+	}), backend.Signer(), key)
+	if err != nil {
+		t.Fatalf("SignTx failed: %v", err)
+	}
+	custom := signedTx(t, backend, key, 0, []byte("genuine"))
+	backend.AddBlock(custom, collision)
+
+	found, err := mgr.ScanBlocks(context.Background(), big.NewInt(1), big.NewInt(1))
+	if err != nil {
+		t.Fatalf("ScanBlocks failed: %v", err)
+	}
+	if len(found) != 1 || found[0].Hash() != custom.Hash() {
+		t.Fatalf("strict ScanBlocks found %d transactions, want only %s", len(found), custom.Hash().Hex())
+	}
+}
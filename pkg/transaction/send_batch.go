@@ -0,0 +1,86 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// SendBatch broadcasts txs in a single JSON-RPC batch of
+// eth_sendRawTransaction calls. Unsigned transactions are signed by the
+// manager's signer as they are, so their nonces and fees must already be
+// set, and the managed nonce used by SendWithContext is not advanced.
+// hashes[i] and errs[i] report on txs[i]; a transaction that could not be
+// signed or encoded is left out of the batch.
+func (m *Manager) SendBatch(ctx context.Context, txs []*types.Transaction) (hashes []common.Hash, errs []error) {
+	hashes = make([]common.Hash, len(txs))
+	errs = make([]error, len(txs))
+
+	signed := make([]*types.Transaction, len(txs))
+	var elems []rpc.BatchElem
+	var indices []int
+	for i, tx := range txs {
+		if !isSigned(tx) {
+			if m.signer == nil {
+				errs[i] = fmt.Errorf("transaction %d is unsigned and the manager has no signer", i)
+				continue
+			}
+			var err error
+			if tx, err = m.signer.SignTx(tx, m.chainID); err != nil {
+				errs[i] = fmt.Errorf("failed to sign transaction %d: %w", i, err)
+				continue
+			}
+		}
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to encode transaction %d: %w", i, err)
+			continue
+		}
+
+		signed[i] = tx
+		elems = append(elems, rpc.BatchElem{
+			Method: "eth_sendRawTransaction",
+			Args:   []interface{}{hexutil.Encode(raw)},
+			Result: new(common.Hash),
+		})
+		indices = append(indices, i)
+	}
+	if len(elems) == 0 {
+		return hashes, errs
+	}
+
+	if err := m.clientPool.Get().Client().BatchCallContext(ctx, elems); err != nil {
+		for _, i := range indices {
+			errs[i] = fmt.Errorf("failed to send batch: %w", err)
+			m.metrics.IncrementTxFailed()
+		}
+		return hashes, errs
+	}
+
+	sender := types.LatestSignerForChainID(m.chainID)
+	for j, elem := range elems {
+		i, tx := indices[j], signed[indices[j]]
+		if elem.Error != nil {
+			errs[i] = fmt.Errorf("failed to send transaction %d: %w", i, elem.Error)
+			m.metrics.IncrementTxFailed()
+			continue
+		}
+
+		hashes[i] = tx.Hash()
+		if from, err := types.Sender(sender, tx); err == nil && from == m.address {
+			m.ledger.track(tx)
+		}
+		m.metrics.IncrementTxSent()
+	}
+	return hashes, errs
+}
+
+// isSigned reports whether tx carries a signature
+func isSigned(tx *types.Transaction) bool {
+	_, r, s := tx.RawSignatureValues()
+	return r.Sign() != 0 || s.Sign() != 0
+}
@@ -0,0 +1,59 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SignedCustomDataOverhead is the size of the signature segment prepended by
+// EncodeSignedCustomData
+const SignedCustomDataOverhead = crypto.SignatureLength
+
+// EncodeSignedCustomData prepends a secp256k1 signature over
+// keccak256(customData) to customData, giving r(32) | s(32) | v(1) | data.
+// v is 27 or 28, so a contract can pass the segments straight to ecrecover.
+// The result is used as the custom data of a transaction.
+func EncodeSignedCustomData(priv *ecdsa.PrivateKey, customData []byte) ([]byte, error) {
+	if priv == nil {
+		return nil, fmt.Errorf("private key is nil")
+	}
+
+	sig, err := crypto.Sign(crypto.Keccak256(customData), priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign custom data: %w", err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+
+	result := make([]byte, 0, len(sig)+len(customData))
+	result = append(result, sig...)
+	result = append(result, customData...)
+	return result, nil
+}
+
+// VerifySignedCustomData checks data produced by EncodeSignedCustomData and
+// returns the signer's address and the payload. Any well-formed signature
+// recovers some address, so callers must compare it with the expected signer.
+func VerifySignedCustomData(data []byte) (common.Address, []byte, error) {
+	if len(data) < SignedCustomDataOverhead {
+		return common.Address{}, nil, fmt.Errorf("signed custom data too short: %d bytes", len(data))
+	}
+
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig, data[:crypto.SignatureLength])
+	payload := data[crypto.SignatureLength:]
+
+	if v := sig[crypto.RecoveryIDOffset]; v != 27 && v != 28 {
+		return common.Address{}, nil, fmt.Errorf("invalid signature recovery id %d", v)
+	}
+	sig[crypto.RecoveryIDOffset] -= 27
+
+	pub, err := crypto.SigToPub(crypto.Keccak256(payload), sig)
+	if err != nil {
+		return common.Address{}, nil, fmt.Errorf("failed to recover signer: %w", err)
+	}
+
+	return crypto.PubkeyToAddress(*pub), payload, nil
+}
@@ -0,0 +1,52 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Signer signs transactions on behalf of a single account, e.g. backed by a
+// KMS or HSM so the private key never enters the process
+type Signer interface {
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+	Address() common.Address
+}
+
+// KeySigner signs with an in-memory private key. It is the signer NewManager
+// uses.
+type KeySigner struct {
+	privateKey *ecdsa.PrivateKey
+	address    common.Address
+}
+
+// NewKeySigner creates a signer from a hex private key (without 0x prefix)
+func NewKeySigner(privateKeyHex string) (*KeySigner, error) {
+	privateKey, err := crypto.HexToECDSA(privateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	publicKey := privateKey.Public()
+	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to cast public key to ECDSA")
+	}
+
+	return &KeySigner{
+		privateKey: privateKey,
+		address:    crypto.PubkeyToAddress(*publicKeyECDSA),
+	}, nil
+}
+
+func (s *KeySigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.NewLondonSigner(chainID), s.privateKey)
+}
+
+func (s *KeySigner) Address() common.Address {
+	return s.address
+}
@@ -0,0 +1,65 @@
+package transaction_test
+
+import (
+	"context"
+	"math/big"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/k4rz4/ethereum-custom-transactions/internal/ethtest"
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+// countingSigner wraps a KeySigner and counts signatures, standing in for
+// a remote KMS
+type countingSigner struct {
+	inner *transaction.KeySigner
+	calls atomic.Int32
+}
+
+func (s *countingSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	s.calls.Add(1)
+	return s.inner.SignTx(tx, chainID)
+}
+
+func (s *countingSigner) Address() common.Address {
+	return s.inner.Address()
+}
+
+func TestNewManagerWithSigner(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+
+	key, _ := crypto.GenerateKey()
+	inner, err := transaction.NewKeySigner(common.Bytes2Hex(crypto.FromECDSA(key)))
+	if err != nil {
+		t.Fatalf("NewKeySigner failed: %v", err)
+	}
+	signer := &countingSigner{inner: inner}
+
+	mgr, err := transaction.NewManagerWithSigner(backend.URL, signer, 2)
+	if err != nil {
+		t.Fatalf("NewManagerWithSigner failed: %v", err)
+	}
+	defer mgr.Close()
+
+	if mgr.Address() != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Errorf("manager address = %s, want the signer's", mgr.Address().Hex())
+	}
+
+	tx, err := mgr.SendWithContext(context.Background(), testRecipient, nil, []byte("kms"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+	if calls := signer.calls.Load(); calls != 1 {
+		t.Errorf("signer called %d times, want 1", calls)
+	}
+
+	sender, err := types.Sender(backend.Signer(), tx)
+	if err != nil || sender != signer.Address() {
+		t.Errorf("transaction sender = %s, %v; want %s", sender.Hex(), err, signer.Address().Hex())
+	}
+}
@@ -0,0 +1,304 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// maxTrackedNonces bounds how many nonces the gas ledger tracks, so callers
+// that never look up receipts do not grow it forever. Beyond it the lowest
+// quarter is evicted: those transactions are by far the likeliest to be
+// mined already.
+const maxTrackedNonces = 4096
+
+// gasLedger totals the gas paid by transactions the manager sent, counting
+// each transaction once when its receipt is first observed
+type gasLedger struct {
+	mu sync.Mutex
+	// pending holds the signed transactions without an observed receipt,
+	// for rebroadcasting and for their nonces, which identify replacements
+	pending map[common.Hash]*types.Transaction
+	gas     big.Int
+	wei     big.Int
+
+	// nonces groups the pending transactions by nonce, so a replacement is
+	// recorded against the transaction it supersedes and whichever of them
+	// mines settles the rest
+	nonces map[uint64][]common.Hash
+
+	// reserved counts in-flight slots taken by sends not yet tracked
+	reserved int
+	// freed is closed when a receipt frees an in-flight slot; nil until a
+	// sender waits
+	freed chan struct{}
+}
+
+// track records a sent transaction whose receipt has not been seen yet
+func (l *gasLedger) track(tx *types.Transaction) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.add(tx)
+}
+
+// trackReserved records a sent transaction in the slot taken for it by
+// reserveInFlight
+func (l *gasLedger) trackReserved(tx *types.Transaction) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.add(tx)
+	l.reserved--
+}
+
+// add records tx as pending. The caller holds l.mu.
+func (l *gasLedger) add(tx *types.Transaction) {
+	if l.pending == nil {
+		l.pending = make(map[common.Hash]*types.Transaction)
+		l.nonces = make(map[uint64][]common.Hash)
+	}
+	if _, ok := l.pending[tx.Hash()]; ok {
+		return
+	}
+	l.pending[tx.Hash()] = tx
+	l.nonces[tx.Nonce()] = append(l.nonces[tx.Nonce()], tx.Hash())
+
+	if len(l.nonces) > maxTrackedNonces {
+		l.evictOldest(maxTrackedNonces / 4)
+	}
+}
+
+// evictOldest stops tracking the n lowest nonces. The caller holds l.mu.
+func (l *gasLedger) evictOldest(n int) {
+	nonces := make([]uint64, 0, len(l.nonces))
+	for nonce := range l.nonces {
+		nonces = append(nonces, nonce)
+	}
+	slices.Sort(nonces)
+	for _, nonce := range nonces[:n] {
+		l.settle(nonce)
+	}
+	l.signalFreed()
+}
+
+// remove stops tracking txHash, reporting whether it was tracked. The
+// caller holds l.mu.
+func (l *gasLedger) remove(txHash common.Hash) bool {
+	tx, ok := l.pending[txHash]
+	if !ok {
+		return false
+	}
+	delete(l.pending, txHash)
+
+	nonce := tx.Nonce()
+	hashes := slices.DeleteFunc(l.nonces[nonce], func(h common.Hash) bool { return h == txHash })
+	if len(hashes) == 0 {
+		delete(l.nonces, nonce)
+	} else {
+		l.nonces[nonce] = hashes
+	}
+	return true
+}
+
+// settle stops tracking every transaction at nonce, once one of them is
+// mined. The caller holds l.mu.
+func (l *gasLedger) settle(nonce uint64) {
+	for _, txHash := range l.nonces[nonce] {
+		delete(l.pending, txHash)
+	}
+	delete(l.nonces, nonce)
+}
+
+// release returns a slot taken by reserveInFlight for a send that failed
+func (l *gasLedger) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reserved--
+	l.signalFreed()
+}
+
+// reserveInFlight takes an in-flight slot if fewer than limit nonces are
+// pending or reserved. Otherwise it fails with ErrTooManyInFlight, or with
+// wait set polls the pending receipts every interval until one is mined.
+func (m *Manager) reserveInFlight(ctx context.Context, limit int, wait bool, interval time.Duration) error {
+	l := &m.ledger
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		l.mu.Lock()
+		if len(l.nonces)+l.reserved < limit {
+			l.reserved++
+			l.mu.Unlock()
+			return nil
+		}
+		if !wait {
+			l.mu.Unlock()
+			return fmt.Errorf("%w: %d unconfirmed transactions", ErrTooManyInFlight, limit)
+		}
+		if l.freed == nil {
+			l.freed = make(chan struct{})
+		}
+		freed := l.freed
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for an in-flight slot: %w", ctx.Err())
+		case <-freed:
+		case <-ticker.C:
+			m.refreshPending(ctx)
+		}
+	}
+}
+
+// refreshPending looks up the receipts of pending transactions so mined
+// ones free their in-flight slots, then drops those below the account's
+// confirmed nonce, which were replaced or dropped and will never be mined
+func (m *Manager) refreshPending(ctx context.Context) {
+	m.ledger.mu.Lock()
+	hashes := make([]common.Hash, 0, len(m.ledger.pending))
+	for txHash := range m.ledger.pending {
+		hashes = append(hashes, txHash)
+	}
+	m.ledger.mu.Unlock()
+
+	for _, txHash := range hashes {
+		if _, err := m.getReceipt(ctx, txHash, ProofOptions{}); err != nil && ctx.Err() != nil {
+			return
+		}
+	}
+
+	if confirmed, err := m.clientPool.Get().NonceAt(ctx, m.address, nil); err == nil {
+		m.ledger.pruneBelow(confirmed)
+	}
+}
+
+// nonceOf returns the nonce of a tracked transaction still waiting for
+// its receipt
+func (l *gasLedger) nonceOf(txHash common.Hash) (uint64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tx, ok := l.pending[txHash]
+	if !ok {
+		return 0, false
+	}
+	return tx.Nonce(), true
+}
+
+// pendingTransactions returns, for every nonce with tracked transactions
+// without an observed receipt, the one paying the highest fee cap (then
+// tip), ordered by nonce. The others at the nonce were replaced by it.
+func (l *gasLedger) pendingTransactions() []*types.Transaction {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	txs := make([]*types.Transaction, 0, len(l.nonces))
+	for _, hashes := range l.nonces {
+		best := l.pending[hashes[0]]
+		for _, txHash := range hashes[1:] {
+			if tx := l.pending[txHash]; paysMore(tx, best) {
+				best = tx
+			}
+		}
+		txs = append(txs, best)
+	}
+	sort.Slice(txs, func(i, j int) bool { return txs[i].Nonce() < txs[j].Nonce() })
+	return txs
+}
+
+// paysMore reports whether a offers a higher fee cap than b, comparing tips
+// on a tie
+func paysMore(a, b *types.Transaction) bool {
+	if c := a.GasFeeCap().Cmp(b.GasFeeCap()); c != 0 {
+		return c > 0
+	}
+	return a.GasTipCap().Cmp(b.GasTipCap()) > 0
+}
+
+// forget stops tracking a transaction that will never be mined, such as a
+// replaced one, freeing its in-flight slot
+func (l *gasLedger) forget(txHash common.Hash) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.remove(txHash) {
+		l.signalFreed()
+	}
+}
+
+// pruneBelow stops tracking the transactions below nonce confirmed. With
+// their nonces taken on chain they are either mined, with a receipt this
+// manager may never look up, or never will be.
+func (l *gasLedger) pruneBelow(confirmed uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pruned := false
+	for nonce := range l.nonces {
+		if nonce < confirmed {
+			l.settle(nonce)
+			pruned = true
+		}
+	}
+	if pruned {
+		l.signalFreed()
+	}
+}
+
+// signalFreed wakes senders waiting for an in-flight slot. The caller holds
+// l.mu.
+func (l *gasLedger) signalFreed() {
+	if l.freed != nil {
+		close(l.freed)
+		l.freed = nil
+	}
+}
+
+// observe adds a receipt's cost if it belongs to a tracked transaction.
+// The other transactions at its nonce, replaced by it or replacing it, can
+// no longer be mined and are dropped.
+func (l *gasLedger) observe(receipt *types.Receipt) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tx, ok := l.pending[receipt.TxHash]
+	if !ok {
+		return
+	}
+	l.settle(tx.Nonce())
+	l.signalFreed()
+
+	gasUsed := new(big.Int).SetUint64(receipt.GasUsed)
+	l.gas.Add(&l.gas, gasUsed)
+	if receipt.EffectiveGasPrice != nil {
+		l.wei.Add(&l.wei, gasUsed.Mul(gasUsed, receipt.EffectiveGasPrice))
+	}
+}
+
+// pendingCount is how many nonces have tracked transactions without an
+// observed receipt; a transaction and its replacements count once
+func (l *gasLedger) pendingCount() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.nonces)
+}
+
+// TotalGasSpent returns the gas units and wei paid by the manager's
+// transactions whose receipts it has observed, through AwaitMined or while
+// generating proofs. Transactions still pending are not included, nor are
+// those no longer tracked when their receipt is observed: the manager stops
+// tracking a transaction once the account's confirmed nonce is seen to pass
+// it, or once it is among the oldest of more than 4096 pending nonces.
+func (m *Manager) TotalGasSpent() (gas, wei *big.Int) {
+	m.ledger.mu.Lock()
+	defer m.ledger.mu.Unlock()
+	return new(big.Int).Set(&m.ledger.gas), new(big.Int).Set(&m.ledger.wei)
+}
@@ -0,0 +1,36 @@
+package transaction
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Sponsor broadcasts the transactions the manager signs, e.g. by bundling
+// them for a relayer or paymaster instead of sending them to the node. The
+// manager keeps tracking tx itself, so a sponsor that wraps it must still get
+// tx mined under its own hash for receipts and proofs to be found.
+type Sponsor interface {
+	Broadcast(ctx context.Context, tx *types.Transaction, client *ethclient.Client) error
+}
+
+// DirectSponsor sends transactions straight to the node. It is the default.
+type DirectSponsor struct{}
+
+func (DirectSponsor) Broadcast(ctx context.Context, tx *types.Transaction, client *ethclient.Client) error {
+	return client.SendTransaction(ctx, tx)
+}
+
+// broadcast hands tx to the manager's sponsor with a pooled client
+func (m *Manager) broadcast(ctx context.Context, tx *types.Transaction) error {
+	return m.sponsor.Broadcast(ctx, tx, m.clientPool.Get())
+}
+
+// broadcastFor is broadcast with the client selected by WithClient, if any
+func (m *Manager) broadcastFor(ctx context.Context, cfg sendConfig, tx *types.Transaction) error {
+	if cfg.clientIndex < 0 {
+		return m.broadcast(ctx, tx)
+	}
+	return m.sponsor.Broadcast(ctx, tx, m.clientPool.GetAt(cfg.clientIndex))
+}
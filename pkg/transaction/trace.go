@@ -0,0 +1,99 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ErrTracingUnavailable is returned by ScanBlockTraces when the node does not
+// serve the debug namespace
+var ErrTracingUnavailable = errors.New("node does not support debug_traceBlockByNumber")
+
+// TracedCustomData is custom data found in the input of an internal call,
+// e.g. a contract forwarding an encoded payload to another contract
+type TracedCustomData struct {
+	// TxHash is the transaction whose execution made the call
+	TxHash common.Hash
+	From   common.Address
+	To     common.Address
+	// Depth is the call's nesting level; calls made directly by the
+	// transaction are at depth 1
+	Depth      int
+	CustomData []byte
+	// Data is the standard calldata following the custom data
+	Data []byte
+}
+
+// callFrame is a call in the callTracer's output
+type callFrame struct {
+	Type  string         `json:"type"`
+	From  common.Address `json:"from"`
+	To    common.Address `json:"to"`
+	Input hexutil.Bytes  `json:"input"`
+	Calls []callFrame    `json:"calls"`
+}
+
+// txTrace is one transaction's entry in a debug_traceBlockByNumber result
+type txTrace struct {
+	TxHash common.Hash `json:"txHash"`
+	Result callFrame   `json:"result"`
+}
+
+// ScanBlockTraces traces blockNumber with the node's callTracer and returns
+// the custom data found in internal call inputs, in execution order.
+// Top-level calldata is not included, as ScanBlocks already finds it. Inputs
+// that start with MagicBytes but do not decode, dictionary-compressed ones
+// included, are skipped, since any caller can pass such calldata. Nodes
+// without the debug namespace yield ErrTracingUnavailable.
+func (m *Manager) ScanBlockTraces(ctx context.Context, blockNumber *big.Int) ([]TracedCustomData, error) {
+	if blockNumber == nil {
+		return nil, fmt.Errorf("block number must not be nil")
+	}
+
+	var traces []txTrace
+	err := m.clientPool.Get().Client().CallContext(ctx, &traces, "debug_traceBlockByNumber",
+		hexutil.EncodeBig(blockNumber), map[string]interface{}{"tracer": "callTracer"})
+	if err != nil {
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == methodNotFoundCode {
+			return nil, fmt.Errorf("block %s: %w", blockNumber, ErrTracingUnavailable)
+		}
+		return nil, fmt.Errorf("failed to trace block %s: %w", blockNumber, err)
+	}
+
+	found := []TracedCustomData{}
+	for _, trace := range traces {
+		for _, call := range trace.Result.Calls {
+			found = collectTracedCustomData(found, trace.TxHash, call, 1)
+		}
+	}
+	return found, nil
+}
+
+// collectTracedCustomData appends the custom data in call and its subcalls
+// to found, walking depth first and skipping inputs that do not decode
+func collectTracedCustomData(found []TracedCustomData, txHash common.Hash, call callFrame, depth int) []TracedCustomData {
+	if IsCustomData(call.Input) {
+		if customData, data, err := DecodeCustomData(call.Input); err == nil {
+			found = append(found, TracedCustomData{
+				TxHash:     txHash,
+				From:       call.From,
+				To:         call.To,
+				Depth:      depth,
+				CustomData: customData,
+				Data:       data,
+			})
+		}
+	}
+
+	for _, sub := range call.Calls {
+		found = collectTracedCustomData(found, txHash, sub, depth+1)
+	}
+	return found
+}
@@ -0,0 +1,184 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TxStatus is the state of a transaction reported by TrackTransaction
+type TxStatus int
+
+const (
+	// TxPending means the transaction is in the node's mempool
+	TxPending TxStatus = iota
+	// TxMined means the transaction has a receipt
+	TxMined
+	// TxReplaced means another transaction from the same sender was mined
+	// at its nonce
+	TxReplaced
+	// TxDropped means the node no longer knows the transaction and no
+	// replacement was mined
+	TxDropped
+)
+
+func (s TxStatus) String() string {
+	switch s {
+	case TxPending:
+		return "pending"
+	case TxMined:
+		return "mined"
+	case TxReplaced:
+		return "replaced"
+	case TxDropped:
+		return "dropped"
+	default:
+		return fmt.Sprintf("TxStatus(%d)", int(s))
+	}
+}
+
+// Terminal reports whether no further events follow s
+func (s TxStatus) Terminal() bool {
+	return s != TxPending
+}
+
+// TxEvent reports a change in the status of a tracked transaction
+type TxEvent struct {
+	TxHash common.Hash
+	Status TxStatus
+	// Receipt is set when Status is TxMined
+	Receipt *types.Receipt
+	// Replacement is the transaction mined in TxHash's place when Status is
+	// TxReplaced
+	Replacement common.Hash
+}
+
+// TrackTransaction emits an event with the current status of txHash and
+// another each time it changes, polling the node's mempool and receipts
+// every poll interval. The channel is closed after a terminal event
+// (mined, replaced or dropped) or once ctx is done. A transaction replaced
+// by one still in the mempool is reported as dropped, since the node
+// forgets it before the replacement is mined. An error is returned if the
+// node does not know txHash.
+func (m *Manager) TrackTransaction(ctx context.Context, txHash common.Hash) (<-chan TxEvent, error) {
+	t := &txTracker{manager: m, txHash: txHash}
+
+	first, ok, err := t.poll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("transaction %s: %w", txHash.Hex(), ethereum.NotFound)
+	}
+
+	events := make(chan TxEvent)
+	go t.run(ctx, first, events)
+	return events, nil
+}
+
+// txTracker polls the status of one transaction for TrackTransaction
+type txTracker struct {
+	manager *Manager
+	txHash  common.Hash
+	// tx and sender are set once the transaction is seen in the mempool
+	tx     *types.Transaction
+	sender common.Address
+}
+
+// run emits first and then every status change until a terminal event
+func (t *txTracker) run(ctx context.Context, first TxEvent, events chan<- TxEvent) {
+	defer close(events)
+
+	send := func(event TxEvent) bool {
+		select {
+		case events <- event:
+			return !event.Status.Terminal()
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	if !send(first) {
+		return
+	}
+
+	ticker := time.NewTicker(t.manager.pollInterval)
+	defer ticker.Stop()
+
+	last := first.Status
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			event, ok, err := t.poll(ctx)
+			if err != nil || !ok || event.Status == last {
+				continue
+			}
+			last = event.Status
+			if !send(event) {
+				return
+			}
+		}
+	}
+}
+
+// poll asks the node for the transaction's status. ok is false if the node
+// does not know a transaction that was never seen.
+func (t *txTracker) poll(ctx context.Context) (TxEvent, bool, error) {
+	m := t.manager
+	event := TxEvent{TxHash: t.txHash}
+	client := m.clientPool.Get()
+
+	receipt, err := client.TransactionReceipt(ctx, t.txHash)
+	if err == nil {
+		m.ledger.observe(receipt)
+		event.Status, event.Receipt = TxMined, receipt
+		return event, true, nil
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return event, false, fmt.Errorf("failed to get receipt: %w", err)
+	}
+
+	tx, _, err := client.TransactionByHash(ctx, t.txHash)
+	if err == nil {
+		if t.tx == nil {
+			sender, err := types.Sender(types.LatestSignerForChainID(m.chainID), tx)
+			if err != nil {
+				return event, false, fmt.Errorf("failed to recover sender: %w", err)
+			}
+			t.tx, t.sender = tx, sender
+		}
+		// A transaction mined since the receipt check shows as mined next poll
+		event.Status = TxPending
+		return event, true, nil
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return event, false, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	// The node has forgotten the transaction, or never saw it
+	var replaced *ReplacedError
+	if t.tx != nil {
+		replaced, err = m.searchReplacement(ctx, t.txHash, t.sender, t.tx.Nonce())
+	} else {
+		replaced, err = m.findReplacement(ctx, t.txHash)
+	}
+	if err != nil {
+		return event, false, err
+	}
+	switch {
+	case replaced != nil:
+		event.Status, event.Replacement = TxReplaced, replaced.Replacement
+	case t.tx != nil:
+		event.Status = TxDropped
+	default:
+		return event, false, nil
+	}
+	return event, true, nil
+}
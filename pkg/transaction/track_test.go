@@ -0,0 +1,89 @@
+package transaction_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+// trackUntilClosed tracks a transaction sent by mgr, calls after once the
+// first event arrives and returns every event up to the channel closing
+func trackUntilClosed(t *testing.T, mgr *transaction.Manager, txHash common.Hash, after func()) []transaction.TxEvent {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := mgr.TrackTransaction(ctx, txHash)
+	if err != nil {
+		t.Fatalf("TrackTransaction failed: %v", err)
+	}
+
+	var got []transaction.TxEvent
+	for event := range events {
+		if len(got) == 0 {
+			after()
+		}
+		got = append(got, event)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("timed out waiting for a terminal event")
+	}
+	return got
+}
+
+func statuses(events []transaction.TxEvent) []transaction.TxStatus {
+	var s []transaction.TxStatus
+	for _, event := range events {
+		s = append(s, event.Status)
+	}
+	return s
+}
+
+func TestTrackTransactionMined(t *testing.T) {
+	backend, mgr := newTestManager(t, transaction.WithPollInterval(10*time.Millisecond))
+
+	tx, err := mgr.SendWithContext(context.Background(), testRecipient, big.NewInt(5), []byte("tracked"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+
+	events := trackUntilClosed(t, mgr, tx.Hash(), func() { backend.Mine() })
+
+	want := []transaction.TxStatus{transaction.TxPending, transaction.TxMined}
+	if got := statuses(events); !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	mined := events[1]
+	if mined.TxHash != tx.Hash() || mined.Receipt == nil || mined.Receipt.TxHash != tx.Hash() {
+		t.Errorf("mined event = %+v, want the receipt of %s", mined, tx.Hash().Hex())
+	}
+}
+
+func TestTrackTransactionDropped(t *testing.T) {
+	backend, mgr := newTestManager(t, transaction.WithPollInterval(10*time.Millisecond))
+
+	tx, err := mgr.SendWithContext(context.Background(), testRecipient, big.NewInt(5), []byte("evicted"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+
+	events := trackUntilClosed(t, mgr, tx.Hash(), backend.FlushPool)
+
+	want := []transaction.TxStatus{transaction.TxPending, transaction.TxDropped}
+	if got := statuses(events); !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+
+	if _, err := mgr.TrackTransaction(context.Background(), tx.Hash()); !errors.Is(err, ethereum.NotFound) {
+		t.Errorf("TrackTransaction of a forgotten transaction error = %v, want NotFound", err)
+	}
+}
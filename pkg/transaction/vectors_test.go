@@ -0,0 +1,82 @@
+package transaction_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+// readGolden reads name/hex pairs from testdata/vectors.golden
+func readGolden(t *testing.T) map[string]string {
+	t.Helper()
+
+	f, err := os.Open("testdata/vectors.golden")
+	if err != nil {
+		t.Fatalf("failed to open golden file: %v", err)
+	}
+	defer f.Close()
+
+	golden := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			t.Fatalf("malformed golden line %q", line)
+		}
+		golden[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	return golden
+}
+
+func TestVectorsMatchGolden(t *testing.T) {
+	golden := readGolden(t)
+	if len(golden) != len(transaction.TestVectors) {
+		t.Errorf("golden file has %d vectors, want %d", len(golden), len(transaction.TestVectors))
+	}
+
+	for _, v := range transaction.TestVectors {
+		t.Run(v.Name, func(t *testing.T) {
+			want, ok := golden[v.Name]
+			if !ok {
+				t.Fatal("vector missing from the golden file")
+			}
+			if v.Encoded != want {
+				t.Fatalf("vector encoding %s differs from golden %s", v.Encoded, want)
+			}
+
+			if got := v.Encode(); !bytes.Equal(got, v.Expected()) {
+				t.Fatalf("encoding = %s, want %s", hex.EncodeToString(got), want)
+			}
+
+			env, err := transaction.DecodeEnvelope(v.Expected())
+			if err != nil {
+				t.Fatalf("DecodeEnvelope failed: %v", err)
+			}
+			if !bytes.Equal(env.CustomData, v.CustomData) || !bytes.Equal(env.StandardData, v.StandardData) {
+				t.Errorf("decoded custom %x, standard %x; want %x, %x",
+					env.CustomData, env.StandardData, v.CustomData, v.StandardData)
+			}
+		})
+	}
+}
+
+func TestFormatVersion(t *testing.T) {
+	if transaction.FormatVersion() != transaction.FormatV1 {
+		t.Errorf("FormatVersion = %d, want FormatV1", transaction.FormatVersion())
+	}
+	if !strings.Contains(transaction.FormatSpec(), "ca fe da 7a") {
+		t.Error("FormatSpec does not describe the magic bytes")
+	}
+}
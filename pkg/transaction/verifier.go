@@ -0,0 +1,84 @@
+package transaction
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Verifier generates and verifies proofs and reads custom data without a
+// signing key, for services that never send transactions. It shares the
+// Manager's client pool and caches.
+type Verifier struct {
+	m *Manager
+}
+
+// NewVerifier creates a Verifier connected to rpcURL with poolSize clients
+func NewVerifier(rpcURL string, poolSize int) (*Verifier, error) {
+	m, err := newManager(rpcURL, nil, poolSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{m: m}, nil
+}
+
+// ChainID returns the chain ID reported by the node
+func (v *Verifier) ChainID() *big.Int {
+	return new(big.Int).Set(v.m.chainID)
+}
+
+func (v *Verifier) GenerateProof(txHash common.Hash) (*Proof, error) {
+	return v.m.GenerateProof(txHash)
+}
+
+func (v *Verifier) GenerateProofWithContext(ctx context.Context, txHash common.Hash) (*Proof, error) {
+	return v.m.GenerateProofWithContext(ctx, txHash)
+}
+
+// GenerateProofs is Manager.GenerateProofs
+func (v *Verifier) GenerateProofs(ctx context.Context, hashes []common.Hash) ([]*Proof, []error) {
+	return v.m.GenerateProofs(ctx, hashes)
+}
+
+func (v *Verifier) VerifyProof(proof *Proof) (bool, error) {
+	return v.m.VerifyProof(proof)
+}
+
+func (v *Verifier) VerifyProofWithContext(ctx context.Context, proof *Proof) (bool, error) {
+	return v.m.VerifyProofWithContext(ctx, proof)
+}
+
+// VerifyProofDetailed is Manager.VerifyProofDetailed
+func (v *Verifier) VerifyProofDetailed(proof *Proof) (*VerifyResult, error) {
+	return v.m.VerifyProofDetailed(proof)
+}
+
+// VerifyProofDetailedWithContext is Manager.VerifyProofDetailedWithContext
+func (v *Verifier) VerifyProofDetailedWithContext(ctx context.Context, proof *Proof) (*VerifyResult, error) {
+	return v.m.VerifyProofDetailedWithContext(ctx, proof)
+}
+
+// VerifyProofs is Manager.VerifyProofs
+func (v *Verifier) VerifyProofs(ctx context.Context, proofs []*Proof) ([]bool, []error) {
+	return v.m.VerifyProofs(ctx, proofs)
+}
+
+// GetCustomDataByHash is Manager.GetCustomDataByHash
+func (v *Verifier) GetCustomDataByHash(ctx context.Context, txHash common.Hash) ([]byte, error) {
+	return v.m.GetCustomDataByHash(ctx, txHash)
+}
+
+// AssertCustomData is Manager.AssertCustomData
+func (v *Verifier) AssertCustomData(ctx context.Context, txHash common.Hash, expected []byte) error {
+	return v.m.AssertCustomData(ctx, txHash, expected)
+}
+
+// Health is Manager.Health
+func (v *Verifier) Health(ctx context.Context) HealthReport {
+	return v.m.Health(ctx)
+}
+
+func (v *Verifier) Close() error {
+	return v.m.Close()
+}
@@ -0,0 +1,42 @@
+package transaction_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/k4rz4/ethereum-custom-transactions/internal/ethtest"
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+func TestVerifier(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	key, _ := crypto.GenerateKey()
+	tx := signedTx(t, backend, key, 0, []byte("verify me"))
+	backend.AddBlock(tx)
+
+	verifier, err := transaction.NewVerifier(backend.URL, 2)
+	if err != nil {
+		t.Fatalf("NewVerifier failed: %v", err)
+	}
+	defer verifier.Close()
+
+	ctx := context.Background()
+	if verifier.ChainID().Int64() != ethtest.DefaultChainID {
+		t.Errorf("ChainID = %s, want %d", verifier.ChainID(), ethtest.DefaultChainID)
+	}
+
+	proof, err := verifier.GenerateProofWithContext(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("GenerateProof failed: %v", err)
+	}
+	if valid, err := verifier.VerifyProofWithContext(ctx, proof); !valid {
+		t.Errorf("proof does not verify: %v", err)
+	}
+
+	customData, err := verifier.GetCustomDataByHash(ctx, tx.Hash())
+	if err != nil || string(customData) != "verify me" {
+		t.Errorf("GetCustomDataByHash = %q, %v; want %q", customData, err, "verify me")
+	}
+}
@@ -0,0 +1,146 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// WatchCustomTransactions subscribes to new heads over the WebSocket
+// endpoint wsURL and emits the custom transactions of each new block,
+// fetching blocks through the manager's client pool. When the subscription
+// fails it reports the error on the error channel without closing the
+// stream, waits with exponential backoff (see WithWatchBackoff) and
+// resubscribes. Blocks mined while disconnected are scanned on the next
+// head. Unlike StreamCustomTransactions, reorgs are not rewound: a
+// replacement head is scanned again, so consumers may see a transaction
+// more than once. Both channels are closed once ctx is done.
+func (m *Manager) WatchCustomTransactions(
+	ctx context.Context,
+	wsURL string,
+) (<-chan *types.Transaction, <-chan error) {
+	txs := make(chan *types.Transaction)
+	errs := make(chan error, 1)
+
+	w := &headWatcher{manager: m, txs: txs}
+
+	go func() {
+		defer close(txs)
+		defer close(errs)
+
+		backoff := m.watchBackoff
+		for {
+			err := w.subscribe(ctx, wsURL)
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case errs <- err:
+			default:
+			}
+
+			if w.received {
+				backoff = m.watchBackoff
+			}
+			w.received = false
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > m.maxWatchBackoff {
+				backoff = m.maxWatchBackoff
+			}
+		}
+	}()
+
+	return txs, errs
+}
+
+// headWatcher tracks the blocks scanned by WatchCustomTransactions across
+// resubscriptions
+type headWatcher struct {
+	manager *Manager
+	next    uint64
+	started bool
+	// received is set once a head arrives on the current subscription
+	received bool
+	txs      chan<- *types.Transaction
+}
+
+// subscribe runs one subscription until it fails or ctx is done
+func (w *headWatcher) subscribe(ctx context.Context, wsURL string) error {
+	client, err := ethclient.DialContext(ctx, wsURL)
+	if err != nil {
+		return fmt.Errorf("failed to dial %s: %w", wsURL, err)
+	}
+	defer client.Close()
+
+	heads := make(chan *types.Header)
+	sub, err := client.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to new heads: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-sub.Err():
+			return fmt.Errorf("new heads subscription failed: %w", err)
+		case head := <-heads:
+			w.received = true
+			if err := w.scan(ctx, head); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// scan emits the custom transactions of every block up to head not yet
+// scanned, or of head itself if it replaces a scanned block
+func (w *headWatcher) scan(ctx context.Context, head *types.Header) error {
+	number := head.Number.Uint64()
+	if !w.started {
+		w.next = number
+		w.started = true
+	}
+
+	if number < w.next {
+		block, err := w.manager.getBlock(ctx, head.Hash(), ProofOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get block %s: %w", head.Hash().Hex(), err)
+		}
+		return w.emit(ctx, block)
+	}
+
+	for ; w.next <= number; w.next++ {
+		block, err := w.manager.getBlockByNumber(ctx, new(big.Int).SetUint64(w.next))
+		if err != nil {
+			return fmt.Errorf("failed to get block %d: %w", w.next, err)
+		}
+		if err := w.emit(ctx, block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *headWatcher) emit(ctx context.Context, block *types.Block) error {
+	for _, tx := range w.manager.customTransactions(block) {
+		select {
+		case w.txs <- tx:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package transaction_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/k4rz4/ethereum-custom-transactions/internal/ethtest"
+	"github.com/k4rz4/ethereum-custom-transactions/pkg/transaction"
+)
+
+func TestWatchCustomTransactionsReconnects(t *testing.T) {
+	backend, mgr := newTestManager(t, transaction.WithWatchBackoff(10*time.Millisecond, 50*time.Millisecond))
+	key, _ := crypto.GenerateKey()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	txs, errs := mgr.WatchCustomTransactions(ctx, backend.WSURL)
+
+	waitForSubscriptions := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for backend.Calls("eth_subscribe") < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for subscription %d", n)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitForSubscriptions(1)
+	first := signedTx(t, backend, key, 0, []byte("first"))
+	backend.AddBlock(first)
+	expectTx(t, txs, errs, first.Hash())
+
+	backend.DropSubscriptions()
+	select {
+	case err, ok := <-errs:
+		if !ok || err == nil {
+			t.Fatal("error channel closed instead of reporting the dropped subscription")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the dropped subscription to be reported")
+	}
+
+	// Mined around the reconnect; picked up by the next head either way
+	missed := signedTx(t, backend, key, 1, []byte("missed"))
+	backend.AddBlock(missed)
+
+	waitForSubscriptions(2)
+	after := signedTx(t, backend, key, 2, []byte("after"))
+	backend.AddBlock(after)
+
+	expectTx(t, txs, errs, missed.Hash())
+	expectTx(t, txs, errs, after.Hash())
+
+	cancel()
+	for range txs {
+	}
+}
+
+// collectConfirmations mines a block at a time and returns the counts
+// emitted until the channel closes
+func collectConfirmations(t *testing.T, mine func(), counts <-chan uint64) []uint64 {
+	t.Helper()
+
+	var got []uint64
+	timeout := time.After(5 * time.Second)
+	mine()
+	for {
+		select {
+		case count, ok := <-counts:
+			if !ok {
+				return got
+			}
+			got = append(got, count)
+			mine()
+		case <-timeout:
+			t.Fatalf("timed out after counts %v", got)
+		}
+	}
+}
+
+func TestWatchConfirmations(t *testing.T) {
+	backend := ethtest.NewBackend(t)
+	key, _ := crypto.GenerateKey()
+	mgr, err := transaction.NewManager(backend.WSURL, common.Bytes2Hex(crypto.FromECDSA(key)), 1,
+		transaction.WithPollInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	defer mgr.Close()
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("confirm me"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+
+	counts, err := mgr.WatchConfirmations(ctx, tx.Hash(), 3)
+	if err != nil {
+		t.Fatalf("WatchConfirmations failed: %v", err)
+	}
+	if subs := backend.Calls("eth_subscribe"); subs != 1 {
+		t.Fatalf("eth_subscribe called %d times, want 1", subs)
+	}
+
+	got := collectConfirmations(t, func() { backend.Mine() }, counts)
+	if want := []uint64{1, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+}
+
+func TestWatchConfirmationsPolls(t *testing.T) {
+	backend, mgr := newTestManager(t, transaction.WithPollInterval(10*time.Millisecond))
+	ctx := context.Background()
+
+	tx, err := mgr.SendWithContext(ctx, testRecipient, nil, []byte("confirm me"), nil)
+	if err != nil {
+		t.Fatalf("SendWithContext failed: %v", err)
+	}
+
+	// The HTTP mock cannot serve subscriptions
+	counts, err := mgr.WatchConfirmations(ctx, tx.Hash(), 2)
+	if err != nil {
+		t.Fatalf("WatchConfirmations failed: %v", err)
+	}
+
+	got := collectConfirmations(t, func() { backend.Mine() }, counts)
+	if want := []uint64{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+}
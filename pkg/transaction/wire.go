@@ -0,0 +1,129 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// WireFormatV1 is the WireProof layout written by ToWire
+const WireFormatV1 uint = 1
+
+// ErrUnsupportedWireFormat is returned by FromWire for a WireProof version
+// it cannot read
+var ErrUnsupportedWireFormat = errors.New("unsupported wire proof format")
+
+// WireProof is a Proof in a form other services can carry, e.g. as JSON:
+// the transaction and receipt are in their binary (RLP) encodings and every
+// other field is hex. The receipt encoding holds only its consensus fields;
+// those the node derives are carried alongside it.
+type WireProof struct {
+	Version          uint           `json:"version"`
+	Transaction      hexutil.Bytes  `json:"transaction"`
+	Receipt          hexutil.Bytes  `json:"receipt"`
+	BlockNumber      *hexutil.Big   `json:"blockNumber"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	TransactionIndex hexutil.Uint   `json:"transactionIndex"`
+	CustomData       hexutil.Bytes  `json:"customData"`
+	ProofPath        []common.Hash  `json:"proofPath"`
+	GasUsed          hexutil.Uint64 `json:"gasUsed"`
+	// EffectiveGasPrice is omitted if the receipt had none
+	EffectiveGasPrice *hexutil.Big `json:"effectiveGasPrice,omitempty"`
+	// FirstLogIndex is the block-wide index of the receipt's first log
+	FirstLogIndex hexutil.Uint `json:"firstLogIndex"`
+}
+
+// ToWire encodes p in WireFormatV1. Logs travel in the receipt, so p.Logs
+// is expected to be the receipt's, as GenerateProof sets it.
+func (p *Proof) ToWire() (*WireProof, error) {
+	if p.Transaction == nil || p.Receipt == nil {
+		return nil, fmt.Errorf("proof is missing its transaction or receipt")
+	}
+
+	tx, err := p.Transaction.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transaction: %w", err)
+	}
+	receipt, err := p.Receipt.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode receipt: %w", err)
+	}
+
+	w := &WireProof{
+		Version:          WireFormatV1,
+		Transaction:      tx,
+		Receipt:          receipt,
+		BlockNumber:      (*hexutil.Big)(p.BlockNumber),
+		BlockHash:        p.BlockHash,
+		TransactionIndex: hexutil.Uint(p.TransactionIndex),
+		CustomData:       p.CustomData,
+		ProofPath:        p.ProofPath,
+		GasUsed:          hexutil.Uint64(p.Receipt.GasUsed),
+	}
+	if p.Receipt.EffectiveGasPrice != nil {
+		w.EffectiveGasPrice = (*hexutil.Big)(p.Receipt.EffectiveGasPrice)
+	}
+	if len(p.Receipt.Logs) > 0 {
+		w.FirstLogIndex = hexutil.Uint(p.Receipt.Logs[0].Index)
+	}
+	return w, nil
+}
+
+// FromWire decodes a proof written by ToWire, restoring the receipt and log
+// fields that are not part of the receipt encoding from the proof's block
+// and transaction
+func FromWire(w *WireProof) (*Proof, error) {
+	if w == nil {
+		return nil, fmt.Errorf("wire proof is nil")
+	}
+	if w.Version != WireFormatV1 {
+		return nil, fmt.Errorf("%w: version %d", ErrUnsupportedWireFormat, w.Version)
+	}
+
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(w.Transaction); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	receipt := new(types.Receipt)
+	if err := receipt.UnmarshalBinary(w.Receipt); err != nil {
+		return nil, fmt.Errorf("failed to decode receipt: %w", err)
+	}
+
+	var blockNumber *big.Int
+	if w.BlockNumber != nil {
+		blockNumber = new(big.Int).Set(w.BlockNumber.ToInt())
+	}
+
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = uint64(w.GasUsed)
+	if w.EffectiveGasPrice != nil {
+		receipt.EffectiveGasPrice = new(big.Int).Set(w.EffectiveGasPrice.ToInt())
+	}
+	receipt.BlockHash = w.BlockHash
+	receipt.BlockNumber = blockNumber
+	receipt.TransactionIndex = uint(w.TransactionIndex)
+	for i, log := range receipt.Logs {
+		log.TxHash = receipt.TxHash
+		log.TxIndex = receipt.TransactionIndex
+		log.BlockHash = receipt.BlockHash
+		if blockNumber != nil {
+			log.BlockNumber = blockNumber.Uint64()
+		}
+		log.Index = uint(w.FirstLogIndex) + uint(i)
+	}
+
+	return &Proof{
+		Transaction:      tx,
+		BlockNumber:      blockNumber,
+		BlockHash:        w.BlockHash,
+		TransactionIndex: uint(w.TransactionIndex),
+		Receipt:          receipt,
+		CustomData:       append([]byte(nil), w.CustomData...),
+		ProofPath:        append([]common.Hash(nil), w.ProofPath...),
+		Logs:             receipt.Logs,
+	}, nil
+}